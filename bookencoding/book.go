@@ -0,0 +1,131 @@
+package bookencoding
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/jpeg"
+	"strings"
+	"time"
+
+	// readerImage decodes webp images to convert them
+	_ "golang.org/x/image/webp"
+)
+
+type (
+	Chapter = struct {
+		Title    string
+		Html     string
+		Markdown string
+		Depth    int
+	}
+
+	BookImage = struct {
+		Name        string
+		ContentType string
+		Data        []byte
+	}
+
+	BookMeta = struct {
+		Author    string
+		Subreddit string
+		Url       string
+		Created   time.Time
+		Edited    time.Time
+		Score     int
+		Flair     string
+	}
+
+	Book = struct {
+		Title    string
+		Meta     BookMeta
+		Html     string
+		Markdown string
+		Chapters []Chapter
+		Images   []BookImage
+		Cover    BookImage
+	}
+)
+
+// titlePageHtml renders a title page with the book metadata
+func titlePageHtml(book *Book) string {
+	meta := book.Meta
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<h1>%s</h1>", html.EscapeString(book.Title))
+	if meta.Author != "" {
+		fmt.Fprintf(&sb, "<p>by u/%s</p>", html.EscapeString(meta.Author))
+	}
+	if meta.Subreddit != "" {
+		fmt.Fprintf(&sb, "<p>in r/%s</p>", html.EscapeString(meta.Subreddit))
+	}
+	if meta.Flair != "" {
+		fmt.Fprintf(&sb, "<p>%s</p>", html.EscapeString(meta.Flair))
+	}
+	if !meta.Created.IsZero() {
+		fmt.Fprintf(&sb, "<p>posted %s", meta.Created.Format(time.DateOnly))
+		if !meta.Edited.IsZero() {
+			fmt.Fprintf(&sb, ", edited %s", meta.Edited.Format(time.DateOnly))
+		}
+		sb.WriteString("</p>")
+	}
+	if meta.Url != "" {
+		fmt.Fprintf(&sb, "<p>%d points</p>", meta.Score)
+		fmt.Fprintf(&sb, `<p><a href="%s">%s</a></p>`, html.EscapeString(meta.Url), html.EscapeString(meta.Url))
+	}
+	return sb.String()
+}
+
+// description is a one line summary of the book source
+func description(book *Book) string {
+	meta := book.Meta
+
+	parts := make([]string, 0, 4)
+	if meta.Author != "" {
+		parts = append(parts, "by u/"+meta.Author)
+	}
+	if meta.Subreddit != "" {
+		parts = append(parts, "in r/"+meta.Subreddit)
+	}
+	if !meta.Created.IsZero() {
+		parts = append(parts, "posted "+meta.Created.Format(time.DateOnly))
+	}
+	if meta.Url != "" {
+		parts = append(parts, fmt.Sprintf("%d points", meta.Score), meta.Url)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// subjects are used as book tags
+func subjects(book *Book) []string {
+	subjects := make([]string, 0, 2)
+	if book.Meta.Subreddit != "" {
+		subjects = append(subjects, "r/"+book.Meta.Subreddit)
+	}
+	if book.Meta.Flair != "" {
+		subjects = append(subjects, book.Meta.Flair)
+	}
+	return subjects
+}
+
+// readerImage converts images in formats that e-readers don't support (webp) to jpeg,
+// false is returned for broken images
+func readerImage(img BookImage) (BookImage, bool) {
+	switch img.ContentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return img, true
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return img, false
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := jpeg.Encode(buf, decoded, &jpeg.Options{Quality: 90}); err != nil {
+		return img, false
+	}
+	img.ContentType = "image/jpeg"
+	img.Data = buf.Bytes()
+	return img, true
+}
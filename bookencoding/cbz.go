@@ -0,0 +1,130 @@
+package bookencoding
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// CBZ packs book images into a comic book archive with ComicInfo.xml metadata,
+// pages are ordered as images appear in the book, text is not included
+type CBZ struct{}
+
+func NewCBZ() CBZ {
+	return CBZ{}
+}
+
+func (c CBZ) Format() string {
+	return "cbz"
+}
+
+type comicInfo struct {
+	XMLName     xml.Name    `xml:"ComicInfo"`
+	XmlnsXsi    string      `xml:"xmlns:xsi,attr"`
+	XmlnsXsd    string      `xml:"xmlns:xsd,attr"`
+	Title       string      `xml:"Title,omitempty"`
+	Summary     string      `xml:"Summary,omitempty"`
+	Year        int         `xml:"Year,omitempty"`
+	Month       int         `xml:"Month,omitempty"`
+	Day         int         `xml:"Day,omitempty"`
+	Writer      string      `xml:"Writer,omitempty"`
+	Publisher   string      `xml:"Publisher,omitempty"`
+	Imprint     string      `xml:"Imprint,omitempty"`
+	Genre       string      `xml:"Genre,omitempty"`
+	Tags        string      `xml:"Tags,omitempty"`
+	Web         string      `xml:"Web,omitempty"`
+	PageCount   int         `xml:"PageCount"`
+	LanguageISO string      `xml:"LanguageISO"`
+	Pages       []comicPage `xml:"Pages>Page"`
+}
+
+type comicPage struct {
+	Image int    `xml:"Image,attr"`
+	Type  string `xml:"Type,attr,omitempty"`
+}
+
+func (c CBZ) Encode(info *Book, out io.Writer) error {
+	pages := make([]BookImage, 0, len(info.Images)+1)
+	if info.Cover.Name != "" {
+		pages = append(pages, info.Cover)
+	}
+	pages = append(pages, info.Images...)
+	if len(pages) == 0 {
+		return fmt.Errorf("write cbz '%s': book has no images", info.Title)
+	}
+
+	archive := zip.NewWriter(out)
+	err := writeComicInfo(archive, info, pages)
+	if err != nil {
+		return fmt.Errorf("write cbz '%s' comic info: %w", info.Title, err)
+	}
+
+	for i, page := range pages {
+		// images are already compressed
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:   fmt.Sprintf("%03d%s", i+1, path.Ext(page.Name)),
+			Method: zip.Store,
+		})
+		if err != nil {
+			return fmt.Errorf("write cbz '%s' page %d: %w", info.Title, i+1, err)
+		}
+		_, err = file.Write(page.Data)
+		if err != nil {
+			return fmt.Errorf("write cbz '%s' page %d: %w", info.Title, i+1, err)
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return fmt.Errorf("write cbz '%s': %w", info.Title, err)
+	}
+	return nil
+}
+
+func writeComicInfo(archive *zip.Writer, info *Book, pages []BookImage) error {
+	meta := info.Meta
+	comic := comicInfo{
+		XmlnsXsi:    "http://www.w3.org/2001/XMLSchema-instance",
+		XmlnsXsd:    "http://www.w3.org/2001/XMLSchema",
+		Title:       info.Title,
+		Summary:     description(info),
+		Publisher:   "Reddit",
+		Genre:       meta.Flair,
+		Tags:        strings.Join(subjects(info), ", "),
+		Web:         meta.Url,
+		PageCount:   len(pages),
+		LanguageISO: "en",
+	}
+	if meta.Author != "" {
+		comic.Writer = "u/" + meta.Author
+	}
+	if meta.Subreddit != "" {
+		comic.Imprint = "r/" + meta.Subreddit
+	}
+	if !meta.Created.IsZero() {
+		comic.Year, comic.Month, comic.Day = meta.Created.Year(), int(meta.Created.Month()), meta.Created.Day()
+	}
+	for i := range pages {
+		page := comicPage{Image: i}
+		if i == 0 && info.Cover.Name != "" {
+			page.Type = "FrontCover"
+		}
+		comic.Pages = append(comic.Pages, page)
+	}
+
+	file, err := archive.Create("ComicInfo.xml")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(file, xml.Header)
+	if err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(file)
+	encoder.Indent("", "  ")
+	return errors.Join(encoder.Encode(comic), encoder.Close())
+}
@@ -0,0 +1,227 @@
+package bookencoding
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+)
+
+func pngImage(t *testing.T) []byte {
+	buf := bytes.NewBuffer(nil)
+	err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 4, 4)))
+	if err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// testBook has an embedded image, an image missing from the book and a reply chapter
+func testBook(t *testing.T) *Book {
+	return &Book{
+		Title: "Test book",
+		Meta: BookMeta{
+			Author:    "author",
+			Subreddit: "test",
+			Url:       "https://www.reddit.com/r/test/comments/abc/",
+			Created:   time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			Score:     10,
+		},
+		Html: `<p>Intro text</p><p><img src="image001.png" alt="picture"/></p><p><img src="missing.png" alt="lost"/></p>`,
+		Chapters: []Chapter{
+			{Title: "Reply", Html: "<p>reply text</p>", Depth: 1},
+		},
+		Images: []BookImage{
+			{Name: "image001.png", ContentType: "image/png", Data: pngImage(t)},
+		},
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		encoder interface {
+			Format() string
+			Encode(info *Book, out io.Writer) error
+		}
+		// check validates the structure of encoded data and that image is embedded
+		check func(t *testing.T, data, image []byte)
+	}{
+		{encoder: NewMobi(), check: checkMobi},
+		{encoder: NewPDF(PDFOptions{}), check: checkPDF},
+		{encoder: NewFB2(), check: checkFB2},
+		{encoder: NewCBZ(), check: checkCBZ},
+		{encoder: NewMarkdown(), check: checkMarkdown},
+		{encoder: NewHTML(), check: checkHTML},
+	}
+	for _, test := range tests {
+		t.Run(test.encoder.Format(), func(t *testing.T) {
+			book := testBook(t)
+			out := bytes.NewBuffer(nil)
+			err := test.encoder.Encode(book, out)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			test.check(t, out.Bytes(), book.Images[0].Data)
+		})
+	}
+}
+
+func checkMobi(t *testing.T, data, image []byte) {
+	records := palmDBRecords(t, data)
+	header := records[0]
+	if string(header[16:20]) != "MOBI" {
+		t.Fatalf("no mobi header in record 0")
+	}
+	firstImage := int(binary.BigEndian.Uint32(header[108:]))
+	if firstImage >= len(records) || !bytes.Equal(records[firstImage], image) {
+		t.Errorf("first image record %d is not the book image", firstImage)
+	}
+
+	text := string(mobiRecordsText(t, records))
+	for _, want := range []string{`recindex="00001"`, "[lost]", "reply text"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text has no %s", want)
+		}
+	}
+}
+
+func checkPDF(t *testing.T, data, image []byte) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) || !bytes.Contains(data[len(data)-16:], []byte("%%EOF")) {
+		t.Fatalf("no pdf header or trailer")
+	}
+	if n := bytes.Count(data, []byte("/Subtype /Image")); n != 1 {
+		t.Errorf("got %d images, want 1", n)
+	}
+}
+
+func checkFB2(t *testing.T, data, image []byte) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("fb2 is not valid xml: %v", err)
+		}
+	}
+
+	var book struct {
+		Binaries []struct {
+			Id          string `xml:"id,attr"`
+			ContentType string `xml:"content-type,attr"`
+			Data        string `xml:",chardata"`
+		} `xml:"binary"`
+	}
+	err := xml.Unmarshal(data, &book)
+	if err != nil {
+		t.Fatalf("unmarshal fb2: %v", err)
+	}
+	if len(book.Binaries) != 1 || book.Binaries[0].Id != "image001.png" || book.Binaries[0].ContentType != "image/png" {
+		t.Fatalf("got binaries %+v, want the book image", book.Binaries)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(book.Binaries[0].Data)
+	if err != nil || !bytes.Equal(decoded, image) {
+		t.Errorf("binary is not the book image: %v", err)
+	}
+
+	for _, want := range []string{`<image l:href="#image001.png"/>`, "[lost]", "reply text"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("fb2 has no %s", want)
+		}
+	}
+}
+
+func checkCBZ(t *testing.T, data, image []byte) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("open cbz: %v", err)
+	}
+	names := make([]string, 0, len(archive.File))
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	if strings.Join(names, ",") != "ComicInfo.xml,001.png" {
+		t.Fatalf("got files %v, want comic info and one page", names)
+	}
+
+	read := func(file *zip.File) []byte {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("read %s: %v", file.Name, err)
+		}
+		return data
+	}
+
+	var comic comicInfo
+	err = xml.Unmarshal(read(archive.File[0]), &comic)
+	if err != nil {
+		t.Fatalf("unmarshal comic info: %v", err)
+	}
+	if comic.Title != "Test book" || comic.PageCount != 1 || len(comic.Pages) != 1 {
+		t.Errorf("got comic info %+v, want title and one page", comic)
+	}
+	if !bytes.Equal(read(archive.File[1]), image) {
+		t.Errorf("page is not the book image")
+	}
+}
+
+func checkMarkdown(t *testing.T, data, image []byte) {
+	text := string(data)
+	if !strings.HasPrefix(text, "---\ntitle: \"Test book\"\n") {
+		t.Fatalf("no front matter with title")
+	}
+	// a single file can't embed images, they are replaced with alt text
+	for _, want := range []string{"# Test book\n", "[picture]", "[lost]", "### Reply\n", "reply text"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("markdown has no %s", want)
+		}
+	}
+	if strings.Contains(text, "<img") {
+		t.Errorf("markdown has an <img> tag")
+	}
+}
+
+func checkHTML(t *testing.T, data, image []byte) {
+	doc, err := xhtml.Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("parse html: %v", err)
+	}
+
+	var srcs []string
+	var walk func(node *xhtml.Node)
+	walk = func(node *xhtml.Node) {
+		if node.Type == xhtml.ElementNode && node.Data == "img" {
+			srcs = append(srcs, attr(node, "src"))
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+
+	want := "data:image/png;base64," + base64.StdEncoding.EncodeToString(image)
+	if len(srcs) != 1 || srcs[0] != want {
+		t.Errorf("got %d images, want one with the book image data url", len(srcs))
+	}
+	for _, want := range []string{"<title>Test book</title>", "[lost]", "reply text"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("html has no %s", want)
+		}
+	}
+}
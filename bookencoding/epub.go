@@ -1,17 +1,15 @@
 package bookencoding
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io"
+	"path"
+	"strings"
 
+	"github.com/awryme/reddit-exporter/pkg/bufpool"
 	"github.com/go-shiori/go-epub"
-)
-
-type (
-	Book = struct {
-		Title string
-		Html  string
-	}
+	xhtml "golang.org/x/net/html"
 )
 
 type Epub struct{}
@@ -29,13 +27,123 @@ func (e Epub) Encode(info *Book, out io.Writer) error {
 	if err != nil {
 		return fmt.Errorf("create epub '%s': %w", info.Title, err)
 	}
-	_, err = book.AddSection(info.Html, info.Title, "main.xhtml", "")
+	setMeta(book, info)
+	err = setCover(book, info.Cover)
+	if err != nil {
+		return fmt.Errorf("set cover of epub '%s': %w", info.Title, err)
+	}
+	_, err = book.AddSection(titlePageHtml(info), "", "title.xhtml", "")
 	if err != nil {
-		return fmt.Errorf("add section to epub '%s': %w", info.Title, err)
+		return fmt.Errorf("add title page to epub '%s': %w", info.Title, err)
 	}
-	_, err = book.WriteTo(out)
+	images, err := addImages(book, info.Images)
+	if err != nil {
+		return fmt.Errorf("add images to epub '%s': %w", info.Title, err)
+	}
+	if info.Html != "" || len(info.Chapters) == 0 {
+		_, err = book.AddSection(sanitizeHtml(info.Html, images), info.Title, "main.xhtml", "")
+		if err != nil {
+			return fmt.Errorf("add section to epub '%s': %w", info.Title, err)
+		}
+	}
+	err = addChapters(book, images, info.Chapters)
+	if err != nil {
+		return fmt.Errorf("add chapters to epub '%s': %w", info.Title, err)
+	}
+
+	buf := bufpool.Get()
+	defer buf.Close()
+
+	_, err = book.WriteTo(buf)
 	if err != nil {
 		return fmt.Errorf("write epub '%s' to file: %w", info.Title, err)
 	}
+	err = writeExtraMeta(buf.Bytes(), out, info)
+	if err != nil {
+		return fmt.Errorf("write epub '%s' metadata: %w", info.Title, err)
+	}
+	return nil
+}
+
+func setMeta(book *epub.Epub, info *Book) {
+	if info.Meta.Author != "" {
+		book.SetAuthor("u/" + info.Meta.Author)
+	}
+	if info.Meta.Url != "" {
+		book.SetIdentifier(info.Meta.Url)
+	}
+	book.SetDescription(description(info))
+}
+
+func setCover(book *epub.Epub, cover BookImage) error {
+	if cover.Name == "" {
+		return nil
+	}
+
+	cover = epubImage(cover)
+	path, err := book.AddImage(dataURL(cover), cover.Name)
+	if err != nil {
+		return fmt.Errorf("add cover image: %w", err)
+	}
+	return book.SetCover(path, "")
+}
+
+// addImages adds book images to epub,
+// returned imageAttrs point image names in src attributes to internal epub paths
+func addImages(book *epub.Epub, images []BookImage) (imageAttrs, error) {
+	paths := make(map[string]string, len(images))
+	for _, image := range images {
+		// src attributes keep the original name
+		converted := epubImage(image)
+		path, err := book.AddImage(dataURL(converted), converted.Name)
+		if err != nil {
+			return nil, fmt.Errorf("add image '%s': %w", image.Name, err)
+		}
+		paths[image.Name] = path
+	}
+	return func(src string) []xhtml.Attribute {
+		path, ok := paths[src]
+		if !ok {
+			return nil
+		}
+		return []xhtml.Attribute{{Key: "src", Val: path}}
+	}, nil
+}
+
+// epubImage converts images that are not epub core media types (webp) to jpeg with a matching file name,
+// broken images are added as is
+func epubImage(image BookImage) BookImage {
+	converted, ok := readerImage(image)
+	if !ok || converted.ContentType == image.ContentType {
+		return image
+	}
+	converted.Name = strings.TrimSuffix(image.Name, path.Ext(image.Name)) + ".jpg"
+	return converted
+}
+
+func dataURL(image BookImage) string {
+	return fmt.Sprintf("data:%s;base64,%s", image.ContentType, base64.StdEncoding.EncodeToString(image.Data))
+}
+
+func addChapters(book *epub.Epub, images imageAttrs, chapters []Chapter) error {
+	// parents[depth] is the filename of the last added chapter on that depth
+	parents := make([]string, 0)
+	for i, chapter := range chapters {
+		filename := fmt.Sprintf("chapter%04d.xhtml", i+1)
+
+		body := sanitizeHtml(chapter.Html, images)
+		depth := min(chapter.Depth, len(parents))
+		var err error
+		if depth == 0 {
+			_, err = book.AddSection(body, chapter.Title, filename, "")
+		} else {
+			_, err = book.AddSubSection(parents[depth-1], body, chapter.Title, filename, "")
+		}
+		if err != nil {
+			return fmt.Errorf("add chapter '%s': %w", chapter.Title, err)
+		}
+
+		parents = append(parents[:depth], filename)
+	}
 	return nil
 }
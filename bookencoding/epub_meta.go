@@ -0,0 +1,99 @@
+package bookencoding
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// go-epub has no setters for dc:date and dc:subject
+const epubPackageFile = "EPUB/package.opf"
+
+// writeExtraMeta copies epub zip to out, adding date and subjects to the package file metadata
+func writeExtraMeta(epubData []byte, out io.Writer, info *Book) error {
+	extra := extraMetaXml(info)
+	if extra == "" {
+		_, err := out.Write(epubData)
+		return err
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(epubData), int64(len(epubData)))
+	if err != nil {
+		return fmt.Errorf("open epub zip: %w", err)
+	}
+
+	writer := zip.NewWriter(out)
+	for _, file := range reader.File {
+		if file.Name != epubPackageFile {
+			// raw copy keeps mimetype stored uncompressed, as epub requires
+			err := copyZipFile(writer, file)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		content, err := readZipFile(file)
+		if err != nil {
+			return err
+		}
+		content = strings.Replace(content, "</metadata>", extra+"</metadata>", 1)
+
+		header := file.FileHeader
+		w, err := writer.CreateHeader(&header)
+		if err != nil {
+			return fmt.Errorf("create zip file '%s': %w", file.Name, err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			return fmt.Errorf("write zip file '%s': %w", file.Name, err)
+		}
+	}
+
+	return writer.Close()
+}
+
+func extraMetaXml(info *Book) string {
+	var sb strings.Builder
+	if !info.Meta.Created.IsZero() {
+		fmt.Fprintf(&sb, "<dc:date>%s</dc:date>", info.Meta.Created.Format(time.DateOnly))
+	}
+	for _, subject := range subjects(info) {
+		sb.WriteString("<dc:subject>")
+		xml.EscapeText(&sb, []byte(subject))
+		sb.WriteString("</dc:subject>")
+	}
+	return sb.String()
+}
+
+func copyZipFile(writer *zip.Writer, file *zip.File) error {
+	r, err := file.OpenRaw()
+	if err != nil {
+		return fmt.Errorf("open zip file '%s': %w", file.Name, err)
+	}
+	w, err := writer.CreateRaw(&file.FileHeader)
+	if err != nil {
+		return fmt.Errorf("create zip file '%s': %w", file.Name, err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("copy zip file '%s': %w", file.Name, err)
+	}
+	return nil
+}
+
+func readZipFile(file *zip.File) (string, error) {
+	r, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("open zip file '%s': %w", file.Name, err)
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("read zip file '%s': %w", file.Name, err)
+	}
+	return string(content), nil
+}
@@ -0,0 +1,67 @@
+package bookencoding
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEpubWebpImage(t *testing.T) {
+	webp, err := os.ReadFile("testdata/image.webp")
+	if err != nil {
+		t.Fatalf("read webp: %v", err)
+	}
+	book := &Book{
+		Title:  "Webp book",
+		Html:   `<p><img src="image001.webp" alt="picture"/></p>`,
+		Images: []BookImage{{Name: "image001.webp", ContentType: "image/webp", Data: webp}},
+	}
+
+	out := bytes.NewBuffer(nil)
+	err = NewEpub().Encode(book, out)
+	if err != nil {
+		t.Fatalf("encode epub: %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("open epub: %v", err)
+	}
+
+	files := make(map[string]string, len(archive.File))
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("read %s: %v", file.Name, err)
+		}
+		files[file.Name] = string(data)
+	}
+
+	// webp is not an epub core media type, the image is converted to jpeg
+	image001, ok := files["EPUB/images/image001.jpg"]
+	if !ok {
+		t.Fatalf("no converted image in files %v", slices.Collect(maps.Keys(files)))
+	}
+	_, format, err := image.Decode(strings.NewReader(image001))
+	if err != nil || format != "jpeg" {
+		t.Errorf("got image format %s, want jpeg: %v", format, err)
+	}
+	for name, data := range files {
+		if strings.HasSuffix(name, ".webp") || strings.Contains(data, "image/webp") {
+			t.Errorf("%s has a webp image", name)
+		}
+	}
+	if !strings.Contains(files["EPUB/xhtml/main.xhtml"], `src="../images/image001.jpg"`) {
+		t.Errorf("main section does not reference the converted image")
+	}
+}
@@ -0,0 +1,425 @@
+package bookencoding
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// FB2 writes books as FictionBook 2 xml files
+type FB2 struct{}
+
+func NewFB2() FB2 {
+	return FB2{}
+}
+
+func (f FB2) Format() string {
+	return "fb2"
+}
+
+func (f FB2) Encode(info *Book, out io.Writer) error {
+	images := make([]BookImage, 0, len(info.Images)+1)
+	for _, image := range info.Images {
+		if image, ok := readerImage(image); ok {
+			images = append(images, image)
+		}
+	}
+	cover, hasCover := readerImage(info.Cover)
+	hasCover = hasCover && info.Cover.Name != ""
+	if hasCover {
+		images = append(images, cover)
+	}
+
+	known := make(map[string]bool, len(images))
+	for _, image := range images {
+		known[image.Name] = true
+	}
+
+	w := bufio.NewWriter(out)
+	w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	w.WriteString(`<FictionBook xmlns="http://www.gribuser.ru/xml/fictionbook/2.0" xmlns:l="http://www.w3.org/1999/xlink">`)
+	writeFB2Description(w, info, cover.Name, hasCover)
+
+	w.WriteString("<body><title>")
+	fmt.Fprintf(w, "<p>%s</p>", html.EscapeString(info.Title))
+	if info.Meta.Author != "" {
+		fmt.Fprintf(w, "<p>u/%s</p>", html.EscapeString(info.Meta.Author))
+	}
+	w.WriteString("</title>")
+	writeFB2Sections(w, info, known)
+	w.WriteString("</body>")
+
+	for _, image := range images {
+		fmt.Fprintf(w, `<binary id="%s" content-type="%s">`, html.EscapeString(image.Name), image.ContentType)
+		encoder := base64.NewEncoder(base64.StdEncoding, w)
+		encoder.Write(image.Data)
+		encoder.Close()
+		w.WriteString("</binary>")
+	}
+	w.WriteString("</FictionBook>\n")
+
+	err := w.Flush()
+	if err != nil {
+		return fmt.Errorf("write fb2 '%s': %w", info.Title, err)
+	}
+	return nil
+}
+
+func writeFB2Description(w *bufio.Writer, info *Book, coverName string, hasCover bool) {
+	meta := info.Meta
+	author := "<author><nickname>unknown</nickname></author>"
+	if meta.Author != "" {
+		author = fmt.Sprintf("<author><nickname>u/%s</nickname><home-page>https://www.reddit.com/user/%s</home-page></author>",
+			html.EscapeString(meta.Author), html.EscapeString(meta.Author))
+	}
+	date := meta.Created
+	if date.IsZero() {
+		date = time.Now()
+	}
+	dateTag := fmt.Sprintf(`<date value="%s">%s</date>`, date.Format(time.DateOnly), date.Format(time.DateOnly))
+
+	w.WriteString("<description><title-info>")
+	w.WriteString("<genre>network_literature</genre>")
+	w.WriteString(author)
+	fmt.Fprintf(w, "<book-title>%s</book-title>", html.EscapeString(info.Title))
+	fmt.Fprintf(w, "<annotation><p>%s</p></annotation>", html.EscapeString(description(info)))
+	if keywords := subjects(info); len(keywords) > 0 {
+		fmt.Fprintf(w, "<keywords>%s</keywords>", html.EscapeString(strings.Join(keywords, ", ")))
+	}
+	w.WriteString(dateTag)
+	if hasCover {
+		fmt.Fprintf(w, `<coverpage><image l:href="#%s"/></coverpage>`, html.EscapeString(coverName))
+	}
+	w.WriteString("<lang>en</lang>")
+	w.WriteString("</title-info>")
+
+	w.WriteString("<document-info>")
+	w.WriteString(author)
+	w.WriteString("<program-used>reddit-exporter</program-used>")
+	w.WriteString(dateTag)
+	if meta.Url != "" {
+		fmt.Fprintf(w, "<src-url>%s</src-url>", html.EscapeString(meta.Url))
+	}
+	fmt.Fprintf(w, "<id>%s</id>", html.EscapeString(cmp.Or(meta.Url, info.Title)))
+	w.WriteString("<version>1.0</version>")
+	w.WriteString("</document-info></description>")
+}
+
+// writeFB2Sections writes chapters as nested sections.
+// Fb2 sections contain either paragraphs or subsections,
+// so text of a chapter with subchapters goes to an untitled first subsection.
+func writeFB2Sections(w *bufio.Writer, info *Book, images map[string]bool) {
+	chapters := info.Chapters
+	if info.Html != "" || len(chapters) == 0 {
+		chapters = append([]Chapter{{Title: info.Title, Html: info.Html}}, chapters...)
+	}
+
+	open := 0
+	for i, chapter := range chapters {
+		depth := min(chapter.Depth, open)
+		for ; open > depth; open-- {
+			w.WriteString("</section>")
+		}
+
+		w.WriteString("<section>")
+		open++
+		fmt.Fprintf(w, "<title><p>%s</p></title>", html.EscapeString(chapter.Title))
+
+		body := fb2Body(chapter.Html, images)
+		hasChildren := i+1 < len(chapters) && chapters[i+1].Depth > depth
+		switch {
+		case hasChildren && body != "":
+			fmt.Fprintf(w, "<section>%s</section>", body)
+		case !hasChildren && body == "":
+			// sections can't be empty
+			w.WriteString("<empty-line/>")
+		case !hasChildren:
+			w.WriteString(body)
+		}
+	}
+	for ; open > 0; open-- {
+		w.WriteString("</section>")
+	}
+}
+
+// fb2Body converts html to fb2 section content
+func fb2Body(src string, images map[string]bool) string {
+	nodes, err := xhtml.ParseFragment(strings.NewReader(src), &xhtml.Node{
+		Type:     xhtml.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return fmt.Sprintf("<p>%s</p>", html.EscapeString(src))
+	}
+
+	c := &fb2Converter{images: images}
+	for _, node := range nodes {
+		c.node(node)
+	}
+	c.flush()
+	c.endQuote()
+	return c.out.String()
+}
+
+type fb2Inline struct {
+	open  string
+	close string
+}
+
+// fb2Converter writes block elements to out and collects inline content of the current paragraph
+type fb2Converter struct {
+	images map[string]bool
+
+	out bytes.Buffer
+
+	para     strings.Builder
+	paraTag  string
+	inPara   bool
+	hasText  bool
+	inlines  []fb2Inline
+	quote    int
+	pre      bool
+	listItem []int
+}
+
+func (c *fb2Converter) startPara(tag string) {
+	c.flush()
+	c.paraTag = tag
+}
+
+func (c *fb2Converter) ensurePara() {
+	if c.inPara {
+		return
+	}
+	c.inPara = true
+	c.hasText = false
+	c.para.Reset()
+	for _, inline := range c.inlines {
+		c.para.WriteString(inline.open)
+	}
+}
+
+// flush writes the current paragraph, empty paragraphs are dropped
+func (c *fb2Converter) flush() {
+	if !c.inPara {
+		c.paraTag = ""
+		return
+	}
+	for i := len(c.inlines) - 1; i >= 0; i-- {
+		c.para.WriteString(c.inlines[i].close)
+	}
+	if c.hasText {
+		tag := cmp.Or(c.paraTag, "p")
+		fmt.Fprintf(&c.out, "<%s>%s</%s>", tag, c.para.String(), tag)
+	}
+	c.inPara = false
+	c.paraTag = ""
+}
+
+func (c *fb2Converter) startQuote() {
+	c.flush()
+	// quotes can't be nested, cite is opened only for the outermost quote
+	if c.quote == 0 {
+		c.out.WriteString("<cite>")
+	}
+	c.quote++
+}
+
+func (c *fb2Converter) endQuote() {
+	c.flush()
+	if c.quote == 0 {
+		return
+	}
+	c.quote--
+	if c.quote > 0 {
+		return
+	}
+	// empty cite is not allowed
+	if bytes.HasSuffix(c.out.Bytes(), []byte("<cite>")) {
+		c.out.Truncate(c.out.Len() - len("<cite>"))
+		return
+	}
+	c.out.WriteString("</cite>")
+}
+
+func (c *fb2Converter) inline(open, close string, node *xhtml.Node) {
+	c.ensurePara()
+	c.para.WriteString(open)
+	c.inlines = append(c.inlines, fb2Inline{open, close})
+	c.children(node)
+	c.inlines = c.inlines[:len(c.inlines)-1]
+	if c.inPara {
+		c.para.WriteString(close)
+	}
+}
+
+func (c *fb2Converter) text(text string) {
+	if strings.TrimSpace(text) == "" && !c.inPara {
+		return
+	}
+	c.ensurePara()
+	c.para.WriteString(html.EscapeString(text))
+	if strings.TrimSpace(text) != "" {
+		c.hasText = true
+	}
+}
+
+func (c *fb2Converter) children(node *xhtml.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		c.node(child)
+	}
+}
+
+func (c *fb2Converter) node(node *xhtml.Node) {
+	switch node.Type {
+	case xhtml.TextNode:
+		c.textNode(node.Data)
+		return
+	case xhtml.ElementNode:
+	default:
+		c.children(node)
+		return
+	}
+
+	switch node.Data {
+	case "script", "style", "iframe", "object", "embed", "form", "noscript", "svg":
+	case "p", "div", "table", "tr":
+		c.flush()
+		c.children(node)
+		c.flush()
+	case "td", "th":
+		c.text(" ")
+		c.children(node)
+		c.text(" ")
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		c.startPara("subtitle")
+		c.children(node)
+		c.flush()
+	case "br":
+		c.flush()
+	case "hr":
+		c.flush()
+		c.out.WriteString("<empty-line/>")
+	case "blockquote":
+		c.startQuote()
+		c.children(node)
+		c.endQuote()
+	case "pre":
+		c.flush()
+		c.pre = true
+		c.inline("<code>", "</code>", node)
+		c.pre = false
+		c.flush()
+	case "ul", "ol":
+		c.flush()
+		c.listItem = append(c.listItem, 0)
+		if node.Data == "ul" {
+			c.listItem[len(c.listItem)-1] = -1
+		}
+		c.children(node)
+		c.listItem = c.listItem[:len(c.listItem)-1]
+		c.flush()
+	case "li":
+		c.flush()
+		c.ensurePara()
+		c.para.WriteString(c.listMarker())
+		c.children(node)
+		c.flush()
+	case "b", "strong":
+		c.inline("<strong>", "</strong>", node)
+	case "i", "em", "cite":
+		c.inline("<emphasis>", "</emphasis>", node)
+	case "s", "del", "strike":
+		c.inline("<strikethrough>", "</strikethrough>", node)
+	case "code":
+		if c.pre {
+			// pre is already written as code
+			c.children(node)
+			return
+		}
+		c.inline("<code>", "</code>", node)
+	case "sup", "sub":
+		c.inline("<"+node.Data+">", "</"+node.Data+">", node)
+	case "a":
+		href := attr(node, "href")
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			c.children(node)
+			return
+		}
+		c.inline(fmt.Sprintf(`<a l:href="%s">`, html.EscapeString(href)), "</a>", node)
+	case "img":
+		c.image(node)
+	default:
+		c.children(node)
+	}
+}
+
+func (c *fb2Converter) textNode(text string) {
+	if !c.pre {
+		c.text(collapseSpace(text))
+		return
+	}
+
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			c.flush()
+		}
+		c.text(line)
+	}
+}
+
+// collapseSpace replaces whitespace runs with a single space like html rendering does
+func collapseSpace(text string) string {
+	collapsed := strings.Join(strings.Fields(text), " ")
+	if collapsed == "" {
+		return " "
+	}
+	if strings.TrimLeftFunc(text, unicode.IsSpace) != text {
+		collapsed = " " + collapsed
+	}
+	if strings.TrimRightFunc(text, unicode.IsSpace) != text {
+		collapsed += " "
+	}
+	return collapsed
+}
+
+func (c *fb2Converter) listMarker() string {
+	depth := len(c.listItem)
+	if depth == 0 {
+		return "• "
+	}
+	indent := strings.Repeat("  ", depth-1)
+	if c.listItem[depth-1] < 0 {
+		return indent + "• "
+	}
+	c.listItem[depth-1]++
+	return fmt.Sprintf("%s%d. ", indent, c.listItem[depth-1])
+}
+
+func (c *fb2Converter) image(node *xhtml.Node) {
+	src := attr(node, "src")
+	if !c.images[src] {
+		c.text(fmt.Sprintf("[%s]", cmp.Or(attr(node, "alt"), "image")))
+		return
+	}
+
+	c.flush()
+	image := fmt.Sprintf(`<image l:href="#%s"/>`, html.EscapeString(src))
+	if c.quote > 0 {
+		// block images are not allowed in quotes, inline image in a paragraph is
+		fmt.Fprintf(&c.out, "<p>%s</p>", image)
+		return
+	}
+	c.out.WriteString(image)
+}
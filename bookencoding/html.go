@@ -0,0 +1,101 @@
+package bookencoding
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+const htmlStyle = `
+body { margin: 0; background: #fafafa; color: #1a1a1b; font: 18px/1.6 Georgia, serif; }
+article { max-width: 40em; margin: 0 auto; padding: 2em 1.5em; background: #fff; }
+h1, h2, h3, h4, h5, h6 { font-family: system-ui, sans-serif; line-height: 1.25; }
+a { color: #0079d3; }
+img { max-width: 100%; height: auto; }
+blockquote { margin: 1em 0; padding-left: 1em; border-left: 3px solid #ccc; color: #444; }
+pre { overflow-x: auto; padding: 0.5em; background: #f4f4f4; }
+code { font-size: 0.9em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.25em 0.5em; border: 1px solid #ccc; }
+.cover { display: block; margin: 0 auto 2em; max-height: 90vh; }
+.title-page { margin-bottom: 2em; color: #555; }
+.title-page h1 { color: #1a1a1b; }
+nav ul { list-style: none; padding-left: 0; }
+section { margin-top: 3em; }
+`
+
+// HTML writes books as a single self-contained html page,
+// images are embedded as data urls
+type HTML struct{}
+
+func NewHTML() HTML {
+	return HTML{}
+}
+
+func (h HTML) Format() string {
+	return "html"
+}
+
+func (h HTML) Encode(info *Book, out io.Writer) error {
+	urls := make(map[string]string, len(info.Images))
+	for _, image := range info.Images {
+		urls[image.Name] = dataURL(image)
+	}
+	images := func(src string) []xhtml.Attribute {
+		url, ok := urls[src]
+		if !ok {
+			return nil
+		}
+		return []xhtml.Attribute{{Key: "src", Val: url}}
+	}
+
+	chapters := info.Chapters
+	if info.Html != "" || len(chapters) == 0 {
+		chapters = append([]Chapter{{Title: info.Title, Html: info.Html}}, chapters...)
+	}
+
+	w := bufio.NewWriter(out)
+	w.WriteString(`<!DOCTYPE html><html lang="en"><head><meta charset="utf-8">`)
+	w.WriteString(`<meta name="viewport" content="width=device-width, initial-scale=1">`)
+	fmt.Fprintf(w, "<title>%s</title>", html.EscapeString(info.Title))
+	if info.Meta.Author != "" {
+		fmt.Fprintf(w, `<meta name="author" content="u/%s">`, html.EscapeString(info.Meta.Author))
+	}
+	fmt.Fprintf(w, `<meta name="description" content="%s">`, html.EscapeString(description(info)))
+	if keywords := subjects(info); len(keywords) > 0 {
+		fmt.Fprintf(w, `<meta name="keywords" content="%s">`, html.EscapeString(strings.Join(keywords, ", ")))
+	}
+	fmt.Fprintf(w, "<style>%s</style></head><body><article>", htmlStyle)
+
+	if info.Cover.Name != "" {
+		fmt.Fprintf(w, `<img class="cover" src="%s" alt="cover">`, dataURL(info.Cover))
+	}
+	fmt.Fprintf(w, `<header class="title-page">%s</header>`, titlePageHtml(info))
+
+	if len(chapters) > 1 {
+		w.WriteString("<nav><h2>Contents</h2><ul>")
+		for i, chapter := range chapters {
+			fmt.Fprintf(w, `<li style="margin-left:%dem"><a href="#chapter-%d">%s</a></li>`,
+				chapter.Depth*2, i+1, html.EscapeString(chapter.Title))
+		}
+		w.WriteString("</ul></nav>")
+	}
+
+	for i, chapter := range chapters {
+		level := min(chapter.Depth+2, 6)
+		fmt.Fprintf(w, `<section id="chapter-%d"><h%d>%s</h%d>`, i+1, level, html.EscapeString(chapter.Title), level)
+		w.WriteString(sanitizeHtml(chapter.Html, images))
+		w.WriteString("</section>")
+	}
+	w.WriteString("</article></body></html>\n")
+
+	err := w.Flush()
+	if err != nil {
+		return fmt.Errorf("write html '%s': %w", info.Title, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package bookencoding
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// Markdown writes books as a markdown document with yaml front matter,
+// sections without markdown source are written as html
+type Markdown struct{}
+
+func NewMarkdown() Markdown {
+	return Markdown{}
+}
+
+func (m Markdown) Format() string {
+	return "md"
+}
+
+func (m Markdown) Encode(info *Book, out io.Writer) error {
+	w := bufio.NewWriter(out)
+	writeFrontMatter(w, info)
+
+	fmt.Fprintf(w, "# %s\n\n", info.Title)
+	if info.Markdown != "" || info.Html != "" {
+		w.WriteString(markdownSection(info.Markdown, info.Html))
+	}
+	for _, chapter := range info.Chapters {
+		level := min(chapter.Depth+2, 6)
+		fmt.Fprintf(w, "%s %s\n\n", strings.Repeat("#", level), chapter.Title)
+		w.WriteString(markdownSection(chapter.Markdown, chapter.Html))
+	}
+
+	err := w.Flush()
+	if err != nil {
+		return fmt.Errorf("write markdown '%s': %w", info.Title, err)
+	}
+	return nil
+}
+
+func writeFrontMatter(w *bufio.Writer, info *Book) {
+	meta := info.Meta
+	field := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "%s: %s\n", key, strconv.Quote(value))
+		}
+	}
+
+	w.WriteString("---\n")
+	field("title", info.Title)
+	if meta.Author != "" {
+		field("author", "u/"+meta.Author)
+	}
+	if meta.Subreddit != "" {
+		field("subreddit", "r/"+meta.Subreddit)
+	}
+	field("url", meta.Url)
+	if !meta.Created.IsZero() {
+		field("created", meta.Created.Format(time.RFC3339))
+	}
+	if !meta.Edited.IsZero() {
+		field("edited", meta.Edited.Format(time.RFC3339))
+	}
+	if meta.Url != "" {
+		fmt.Fprintf(w, "score: %d\n", meta.Score)
+	}
+	field("flair", meta.Flair)
+	if tags := subjects(info); len(tags) > 0 {
+		quoted := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			quoted = append(quoted, strconv.Quote(tag))
+		}
+		fmt.Fprintf(w, "tags: [%s]\n", strings.Join(quoted, ", "))
+	}
+	w.WriteString("---\n\n")
+}
+
+// markdownSection returns markdown source if it is known, sanitized html otherwise,
+// embedded images can't be referenced from a single file and are replaced with alt text
+func markdownSection(markdown, html string) string {
+	text := strings.TrimSpace(markdown)
+	if text == "" {
+		text = strings.TrimSpace(sanitizeHtml(html, func(string) []xhtml.Attribute { return nil }))
+	}
+	if text == "" {
+		return ""
+	}
+	return text + "\n\n"
+}
@@ -0,0 +1,407 @@
+package bookencoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	xhtml "golang.org/x/net/html"
+)
+
+const (
+	mobiRecordSize = 4096
+	mobiHeaderSize = 232
+	// unset record indexes in mobi header
+	mobiNoIndex = 0xFFFFFFFF
+
+	mobiCompressionPalmDoc = 2
+	mobiTypeBook           = 2
+	mobiEncodingUTF8       = 65001
+	mobiVersion            = 6
+	mobiLocaleEnglish      = 9
+	// exth flags bit that tells the exth header is present
+	mobiExthFlag = 0x40
+	// extra data flags bit that tells text records end with a multibyte trailing entry
+	mobiMultibyteFlag = 0x1
+)
+
+// exth record types
+const (
+	exthAuthor      = 100
+	exthDescription = 103
+	exthSubject     = 105
+	exthPublished   = 106
+	exthSource      = 112
+	exthCoverOffset = 201
+	exthThumbOffset = 202
+	exthDocType     = 501
+	exthTitle       = 503
+	exthLanguage    = 524
+)
+
+// width of filepos values, positions are written as placeholders and patched when targets are known
+const mobiFileposWidth = 10
+
+// Mobi writes books as MOBI (version 6) files, readable by all Kindle devices and apps
+type Mobi struct{}
+
+func NewMobi() Mobi {
+	return Mobi{}
+}
+
+func (m Mobi) Format() string {
+	return "mobi"
+}
+
+func (m Mobi) Encode(info *Book, out io.Writer) error {
+	images, coverIndex := mobiImages(info)
+	text := mobiText(info, images)
+	textRecords := splitTextRecords(text)
+
+	// record 0 is the header, text records follow it, then images and trailing records
+	firstImage := 1 + len(textRecords)
+	flis := firstImage + len(images)
+	fcis := flis + 1
+
+	records := make([][]byte, 0, fcis+2)
+	records = append(records, mobiHeader(info, mobiHeaderInfo{
+		TextLength:  len(text),
+		TextRecords: len(textRecords),
+		ImageCount:  len(images),
+		FirstImage:  firstImage,
+		CoverIndex:  coverIndex,
+		FLIS:        flis,
+		FCIS:        fcis,
+	}))
+	for _, record := range textRecords {
+		records = append(records, record.encode())
+	}
+	for _, image := range images {
+		records = append(records, image.Data)
+	}
+	records = append(records, mobiFLIS(), mobiFCIS(len(text)), mobiEOF())
+
+	err := writePalmDB(out, info.Title, info.Meta.Created, records)
+	if err != nil {
+		return fmt.Errorf("write mobi '%s': %w", info.Title, err)
+	}
+	return nil
+}
+
+type mobiImage struct {
+	Name string
+	Data []byte
+}
+
+// mobiImages returns images in record order and the index of the cover image, -1 if there is no cover.
+// Broken images are skipped.
+func mobiImages(info *Book) ([]mobiImage, int) {
+	images := make([]mobiImage, 0, len(info.Images)+1)
+	for _, image := range info.Images {
+		image, ok := readerImage(image)
+		if ok {
+			images = append(images, mobiImage{Name: image.Name, Data: image.Data})
+		}
+	}
+
+	coverIndex := -1
+	if info.Cover.Name != "" {
+		cover, ok := readerImage(info.Cover)
+		if ok {
+			coverIndex = len(images)
+			images = append(images, mobiImage{Name: cover.Name, Data: cover.Data})
+		}
+	}
+	return images, coverIndex
+}
+
+// mobiText renders the whole book as one html document,
+// chapters are separated by page breaks and linked from a table of contents by file positions
+func mobiText(info *Book, images []mobiImage) []byte {
+	// images are referenced by 1-based record index relative to the first image record
+	recindex := make(map[string]string, len(images))
+	for i, image := range images {
+		recindex[image.Name] = fmt.Sprintf("%05d", i+1)
+	}
+	imageAttrs := func(src string) []xhtml.Attribute {
+		index, ok := recindex[src]
+		if !ok {
+			return nil
+		}
+		return []xhtml.Attribute{{Key: "recindex", Val: index}}
+	}
+
+	chapters := info.Chapters
+	if info.Html != "" || len(chapters) == 0 {
+		chapters = append([]Chapter{{Title: info.Title, Html: info.Html}}, chapters...)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	// filepos placeholders and the chapter index they point to, -1 points to table of contents
+	placeholders := make(map[int]int)
+	filepos := func(target int) {
+		placeholders[buf.Len()] = target
+		buf.WriteString(strings.Repeat("0", mobiFileposWidth))
+	}
+
+	buf.WriteString(`<html><head><guide><reference type="toc" title="Table of Contents" filepos=`)
+	filepos(-1)
+	buf.WriteString(` /></guide></head><body>`)
+	buf.WriteString(sanitizeHtml(titlePageHtml(info), imageAttrs))
+	buf.WriteString("<mbp:pagebreak/>")
+
+	tocPos := buf.Len()
+	buf.WriteString("<h2>Contents</h2>")
+	for i, chapter := range chapters {
+		fmt.Fprintf(buf, `<p style="margin-left:%dem"><a filepos=`, chapter.Depth*2)
+		filepos(i)
+		fmt.Fprintf(buf, ">%s</a></p>", html.EscapeString(chapter.Title))
+	}
+
+	chapterPos := make([]int, len(chapters))
+	for i, chapter := range chapters {
+		if chapter.Depth == 0 {
+			buf.WriteString("<mbp:pagebreak/>")
+		}
+		chapterPos[i] = buf.Len()
+		level := min(chapter.Depth+2, 6)
+		fmt.Fprintf(buf, "<h%d>%s</h%d>", level, html.EscapeString(chapter.Title), level)
+		buf.WriteString(sanitizeHtml(chapter.Html, imageAttrs))
+	}
+	buf.WriteString("</body></html>")
+
+	text := buf.Bytes()
+	for offset, target := range placeholders {
+		pos := tocPos
+		if target >= 0 {
+			pos = chapterPos[target]
+		}
+		copy(text[offset:], fmt.Sprintf("%0*d", mobiFileposWidth, pos))
+	}
+	return text
+}
+
+// mobiTextRecord is a full record of text, Overlap are the bytes of its last character that continue in the next record
+type mobiTextRecord struct {
+	Text    []byte
+	Overlap []byte
+}
+
+// splitTextRecords splits text into records of mobiRecordSize bytes, only the last record is shorter.
+// Readers expect full records, characters cut by a record end are completed in its multibyte trailing entry
+func splitTextRecords(text []byte) []mobiTextRecord {
+	records := make([]mobiTextRecord, 0, len(text)/mobiRecordSize+1)
+	for start := 0; start < len(text); start += mobiRecordSize {
+		end := min(start+mobiRecordSize, len(text))
+		next := end
+		for next < len(text) && next-end < utf8.UTFMax-1 && !utf8.RuneStart(text[next]) {
+			next++
+		}
+		records = append(records, mobiTextRecord{
+			Text:    text[start:end],
+			Overlap: text[end:next],
+		})
+	}
+	return records
+}
+
+// encode compresses the text and adds the multibyte trailing entry: overlap bytes followed by their count
+func (record mobiTextRecord) encode() []byte {
+	data := palmDocCompress(record.Text)
+	data = append(data, record.Overlap...)
+	return append(data, byte(len(record.Overlap)))
+}
+
+type mobiHeaderInfo struct {
+	TextLength  int
+	TextRecords int
+	ImageCount  int
+	FirstImage  int
+	CoverIndex  int
+	FLIS        int
+	FCIS        int
+}
+
+// mobiHeader builds record 0: palmdoc header, mobi header, exth metadata and the full title
+func mobiHeader(info *Book, hi mobiHeaderInfo) []byte {
+	exth := mobiEXTH(info, hi.CoverIndex)
+	title := []byte(info.Title)
+
+	firstImage := uint32(mobiNoIndex)
+	lastContent := hi.TextRecords
+	if hi.ImageCount > 0 {
+		firstImage = uint32(hi.FirstImage)
+		lastContent = hi.FirstImage + hi.ImageCount - 1
+	}
+
+	buf := bytes.NewBuffer(nil)
+	put := func(values ...any) {
+		for _, v := range values {
+			binary.Write(buf, binary.BigEndian, v)
+		}
+	}
+
+	// palmdoc header
+	put(uint16(mobiCompressionPalmDoc), uint16(0), uint32(hi.TextLength), uint16(hi.TextRecords), uint16(mobiRecordSize), uint16(0), uint16(0))
+
+	// mobi header
+	buf.WriteString("MOBI")
+	put(uint32(mobiHeaderSize), uint32(mobiTypeBook), uint32(mobiEncodingUTF8), rand.Uint32(), uint32(mobiVersion))
+	// orthographic, inflection, index names, index keys and 6 extra indexes
+	for range 10 {
+		put(uint32(mobiNoIndex))
+	}
+	put(uint32(hi.TextRecords + 1)) // first non-book record
+	put(uint32(16+mobiHeaderSize+len(exth)), uint32(len(title)))
+	put(uint32(mobiLocaleEnglish), uint32(0), uint32(0), uint32(mobiVersion))
+	put(firstImage)
+	put(uint32(0), uint32(0), uint32(0), uint32(0)) // huffman records
+	put(uint32(mobiExthFlag))
+	buf.Write(make([]byte, 32))
+	put(uint32(mobiNoIndex))
+	put(uint32(mobiNoIndex), uint32(0), uint32(0), uint32(0)) // drm
+	buf.Write(make([]byte, 8))
+	put(uint16(1), uint16(lastContent))
+	put(uint32(1), uint32(hi.FCIS), uint32(1), uint32(hi.FLIS), uint32(1))
+	buf.Write(make([]byte, 8))
+	put(uint32(mobiNoIndex), uint32(0), uint32(mobiNoIndex), uint32(mobiNoIndex))
+	put(uint32(mobiMultibyteFlag)) // text records end with a multibyte trailing entry
+	put(uint32(mobiNoIndex))       // no ncx index
+
+	buf.Write(exth)
+	buf.Write(title)
+	// title is followed by at least two zero bytes, record is padded to 4 bytes
+	buf.Write(make([]byte, 2+(4-(len(title)+2)%4)%4))
+	return buf.Bytes()
+}
+
+func mobiEXTH(info *Book, coverIndex int) []byte {
+	records := bytes.NewBuffer(nil)
+	count := 0
+	add := func(recordType uint32, data []byte) {
+		binary.Write(records, binary.BigEndian, recordType)
+		binary.Write(records, binary.BigEndian, uint32(8+len(data)))
+		records.Write(data)
+		count++
+	}
+	addUint := func(recordType uint32, value uint32) {
+		add(recordType, binary.BigEndian.AppendUint32(nil, value))
+	}
+
+	if info.Meta.Author != "" {
+		add(exthAuthor, []byte("u/"+info.Meta.Author))
+	}
+	add(exthDescription, []byte(description(info)))
+	for _, subject := range subjects(info) {
+		add(exthSubject, []byte(subject))
+	}
+	if !info.Meta.Created.IsZero() {
+		add(exthPublished, []byte(info.Meta.Created.Format(time.RFC3339)))
+	}
+	if info.Meta.Url != "" {
+		add(exthSource, []byte(info.Meta.Url))
+	}
+	if coverIndex >= 0 {
+		addUint(exthCoverOffset, uint32(coverIndex))
+		addUint(exthThumbOffset, uint32(coverIndex))
+	}
+	add(exthDocType, []byte("EBOK"))
+	add(exthTitle, []byte(info.Title))
+	add(exthLanguage, []byte("en"))
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("EXTH")
+	binary.Write(buf, binary.BigEndian, uint32(12+records.Len()))
+	binary.Write(buf, binary.BigEndian, uint32(count))
+	buf.Write(records.Bytes())
+	// padding is not included in exth length
+	buf.Write(make([]byte, (4-records.Len()%4)%4))
+	return buf.Bytes()
+}
+
+func mobiFLIS() []byte {
+	return []byte("FLIS\x00\x00\x00\x08\x00\x41\x00\x00\x00\x00\x00\x00\xff\xff\xff\xff\x00\x01\x00\x03\x00\x00\x00\x03\x00\x00\x00\x01\xff\xff\xff\xff")
+}
+
+func mobiFCIS(textLength int) []byte {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString("FCIS\x00\x00\x00\x14\x00\x00\x00\x10\x00\x00\x00\x01\x00\x00\x00\x00")
+	binary.Write(buf, binary.BigEndian, uint32(textLength))
+	buf.WriteString("\x00\x00\x00\x00\x00\x00\x00\x20\x00\x00\x00\x08\x00\x01\x00\x01\x00\x00\x00\x00")
+	return buf.Bytes()
+}
+
+func mobiEOF() []byte {
+	return []byte{0xE9, 0x8E, 0x0D, 0x0A}
+}
+
+// writePalmDB writes records in palm database container used by mobi files
+func writePalmDB(out io.Writer, title string, created time.Time, records [][]byte) error {
+	const headerSize = 78
+	const recordInfoSize = 8
+
+	if created.IsZero() {
+		created = time.Now()
+	}
+
+	buf := bytes.NewBuffer(nil)
+	put := func(values ...any) {
+		for _, v := range values {
+			binary.Write(buf, binary.BigEndian, v)
+		}
+	}
+
+	name := make([]byte, 32)
+	copy(name[:31], palmDBName(title))
+	buf.Write(name)
+	put(uint16(0), uint16(0))                                      // attributes, version
+	put(uint32(created.Unix()), uint32(created.Unix()), uint32(0)) // created, modified, backed up
+	put(uint32(0), uint32(0), uint32(0))                           // modification number, app info, sort info
+	buf.WriteString("BOOKMOBI")
+	put(uint32(2*len(records)-1), uint32(0), uint16(len(records)))
+
+	offset := headerSize + recordInfoSize*len(records) + 2
+	for i, record := range records {
+		put(uint32(offset), uint32(2*i)) // attributes byte is zero, unique id fits in 3 bytes
+		offset += len(record)
+	}
+	put(uint16(0)) // gap before the first record
+
+	_, err := buf.WriteTo(out)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		_, err := out.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// palmDBName is the database name, limited to 31 ascii characters
+func palmDBName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == ' ' || r == '-' || r == '_':
+			return '_'
+		}
+		return -1
+	}, title)
+	if len(name) > 31 {
+		name = name[:31]
+	}
+	if name == "" {
+		name = "book"
+	}
+	return name
+}
@@ -0,0 +1,92 @@
+package bookencoding
+
+const (
+	palmDocMaxDistance = 2047
+	palmDocMinMatch    = 3
+	palmDocMaxMatch    = 10
+	// limits how many earlier positions are compared for each match
+	palmDocMaxCandidates = 32
+)
+
+// palmDocCompress compresses one text record with palmdoc lz77 variant
+func palmDocCompress(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	// positions of 3 byte sequences seen so far
+	seen := make(map[[3]byte][]int)
+	remember := func(pos int) {
+		if pos+palmDocMinMatch <= len(data) {
+			key := [3]byte(data[pos : pos+palmDocMinMatch])
+			seen[key] = append(seen[key], pos)
+		}
+	}
+
+	for i := 0; i < len(data); {
+		if distance, length := palmDocMatch(data, i, seen); length > 0 {
+			code := 0x8000 | distance<<3 | (length - palmDocMinMatch)
+			out = append(out, byte(code>>8), byte(code))
+			for j := range length {
+				remember(i + j)
+			}
+			i += length
+			continue
+		}
+
+		c := data[i]
+		switch {
+		case c == ' ' && i+1 < len(data) && data[i+1] >= 0x40 && data[i+1] <= 0x7F:
+			// space followed by a printable character is packed into one byte
+			out = append(out, data[i+1]^0x80)
+			remember(i)
+			remember(i + 1)
+			i += 2
+		case c == 0 || (c >= 0x09 && c <= 0x7F):
+			out = append(out, c)
+			remember(i)
+			i++
+		default:
+			// other bytes are written literally in runs of up to 8 bytes prefixed with the run length
+			n := 0
+			for n < 8 && i+n < len(data) && palmDocNeedsEscape(data[i+n]) {
+				n++
+			}
+			out = append(out, byte(n))
+			out = append(out, data[i:i+n]...)
+			for j := range n {
+				remember(i + j)
+			}
+			i += n
+		}
+	}
+	return out
+}
+
+func palmDocNeedsEscape(c byte) bool {
+	return (c >= 0x01 && c <= 0x08) || c >= 0x80
+}
+
+// palmDocMatch finds the longest earlier match of data at pos, length is 0 if there is none
+func palmDocMatch(data []byte, pos int, seen map[[3]byte][]int) (distance int, length int) {
+	if pos+palmDocMinMatch > len(data) {
+		return 0, 0
+	}
+
+	candidates := seen[[3]byte(data[pos:pos+palmDocMinMatch])]
+	for i := len(candidates) - 1; i >= 0 && i >= len(candidates)-palmDocMaxCandidates; i-- {
+		start := candidates[i]
+		if pos-start > palmDocMaxDistance {
+			break
+		}
+
+		n := palmDocMinMatch
+		for n < palmDocMaxMatch && pos+n < len(data) && data[start+n] == data[pos+n] {
+			n++
+		}
+		if n > length {
+			distance, length = pos-start, n
+		}
+		if length == palmDocMaxMatch {
+			break
+		}
+	}
+	return distance, length
+}
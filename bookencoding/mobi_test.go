@@ -0,0 +1,148 @@
+package bookencoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// palmDBRecords reads records of a palm database
+func palmDBRecords(t *testing.T, data []byte) [][]byte {
+	if len(data) < 78 || string(data[60:68]) != "BOOKMOBI" {
+		t.Fatalf("not a mobi palm database")
+	}
+	count := int(binary.BigEndian.Uint16(data[76:]))
+	offsets := make([]int, count+1)
+	for i := range count {
+		offsets[i] = int(binary.BigEndian.Uint32(data[78+8*i:]))
+	}
+	offsets[count] = len(data)
+
+	records := make([][]byte, count)
+	for i := range count {
+		if offsets[i] > offsets[i+1] {
+			t.Fatalf("record #%d starts after the next one", i)
+		}
+		records[i] = data[offsets[i]:offsets[i+1]]
+	}
+	return records
+}
+
+// palmDocDecompress is the reader side of palmDocCompress
+func palmDocDecompress(t *testing.T, data []byte) []byte {
+	out := make([]byte, 0, mobiRecordSize)
+	for i := 0; i < len(data); {
+		c := data[i]
+		i++
+		switch {
+		case c == 0 || (c >= 0x09 && c <= 0x7F):
+			out = append(out, c)
+		case c <= 0x08:
+			out = append(out, data[i:i+int(c)]...)
+			i += int(c)
+		case c >= 0xC0:
+			out = append(out, ' ', c^0x80)
+		default:
+			code := int(c)<<8 | int(data[i])
+			i++
+			distance, length := code>>3&0x7FF, code&7+palmDocMinMatch
+			if distance == 0 || distance > len(out) {
+				t.Fatalf("bad palmdoc distance %d at %d", distance, len(out))
+			}
+			for range length {
+				out = append(out, out[len(out)-distance])
+			}
+		}
+	}
+	return out
+}
+
+// mobiRecordsText decodes text records of a mobi file, checking their sizes and trailing entries
+func mobiRecordsText(t *testing.T, records [][]byte) []byte {
+	header := records[0]
+	textLength := int(binary.BigEndian.Uint32(header[4:]))
+	textRecords := int(binary.BigEndian.Uint16(header[8:]))
+	// extra data flags are the low half of the uint32 at 0xF0 of record 0
+	flags := binary.BigEndian.Uint16(header[0xF2:])
+	if flags&mobiMultibyteFlag == 0 {
+		t.Fatalf("got extra data flags %b, want multibyte flag", flags)
+	}
+
+	var text []byte
+	for i, record := range records[1 : 1+textRecords] {
+		// the last byte counts overlap bytes before it
+		n := int(record[len(record)-1] & 0x3)
+		data := palmDocDecompress(t, record[:len(record)-1-n])
+		if i < textRecords-1 && len(data) != mobiRecordSize {
+			t.Errorf("text record #%d has %d bytes, want %d", i, len(data), mobiRecordSize)
+		}
+		text = append(text, data...)
+	}
+	if len(text) != textLength {
+		t.Errorf("got %d bytes of text, header has %d", len(text), textLength)
+	}
+	return text
+}
+
+func TestSplitTextRecords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		// wantOverlaps are lengths of multibyte trailing entries of records
+		wantOverlaps []int
+	}{
+		{name: "ascii", text: strings.Repeat("a", 5000), wantOverlaps: []int{0, 0}},
+		{name: "full record", text: strings.Repeat("a", mobiRecordSize), wantOverlaps: []int{0}},
+		{name: "two byte character on the boundary", text: strings.Repeat("a", mobiRecordSize-1) + "ж" + "b", wantOverlaps: []int{1, 0}},
+		{name: "four byte character on the boundary", text: strings.Repeat("a", mobiRecordSize-1) + "😀" + "b", wantOverlaps: []int{3, 0}},
+		{name: "character ends at the boundary", text: strings.Repeat("a", mobiRecordSize-3) + "€" + "b", wantOverlaps: []int{0, 0}},
+		{name: "only multibyte", text: strings.Repeat("€", 3000), wantOverlaps: []int{2, 1, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records := splitTextRecords([]byte(test.text))
+			if len(records) != len(test.wantOverlaps) {
+				t.Fatalf("got %d records, want %d", len(records), len(test.wantOverlaps))
+			}
+
+			var joined []byte
+			for i, record := range records {
+				if i < len(records)-1 && len(record.Text) != mobiRecordSize {
+					t.Errorf("record #%d has %d bytes, want %d", i, len(record.Text), mobiRecordSize)
+				}
+				if len(record.Overlap) != test.wantOverlaps[i] {
+					t.Errorf("record #%d has overlap %q, want %d bytes", i, record.Overlap, test.wantOverlaps[i])
+				}
+				// the last character of a record is complete with its overlap
+				last, _ := utf8.DecodeLastRune(append(bytes.Clone(record.Text), record.Overlap...))
+				if last == utf8.RuneError {
+					t.Errorf("record #%d ends with a cut character", i)
+				}
+				joined = append(joined, record.Text...)
+			}
+			if string(joined) != test.text {
+				t.Errorf("joined records differ from text")
+			}
+		})
+	}
+}
+
+func TestMobiTextRecords(t *testing.T) {
+	book := &Book{
+		Title: "Мульти",
+		Html:  "<p>" + strings.Repeat("Текст с € и 😀, ", 2000) + "</p>",
+	}
+	out := bytes.NewBuffer(nil)
+	err := NewMobi().Encode(book, out)
+	if err != nil {
+		t.Fatalf("encode mobi: %v", err)
+	}
+
+	records := palmDBRecords(t, out.Bytes())
+	text := mobiRecordsText(t, records)
+	if !bytes.Equal(text, mobiText(book, nil)) {
+		t.Errorf("decoded text differs from book text")
+	}
+}
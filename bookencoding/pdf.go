@@ -0,0 +1,469 @@
+package bookencoding
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	pdfTextFont = "text"
+	pdfMonoFont = "mono"
+
+	pdfMargin = 15.0
+	// converts font points to millimeters
+	pdfPointSize = 25.4 / 72
+)
+
+type PDFOptions struct {
+	// PageSize is a page format name: A4, A5, A6, Letter, Legal
+	PageSize string
+	// FontSize in points
+	FontSize float64
+	// FontFile is a path to ttf font used for all text styles, embedded Go fonts are used if empty
+	FontFile string
+}
+
+type PDF struct {
+	opts PDFOptions
+}
+
+func NewPDF(opts PDFOptions) PDF {
+	if opts.PageSize == "" {
+		opts.PageSize = "A5"
+	}
+	if opts.FontSize <= 0 {
+		opts.FontSize = 11
+	}
+	return PDF{opts}
+}
+
+func (p PDF) Format() string {
+	return "pdf"
+}
+
+func (p PDF) Encode(info *Book, out io.Writer) error {
+	pdf := fpdf.New("P", "mm", p.opts.PageSize, "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin)
+
+	err := p.addFonts(pdf)
+	if err != nil {
+		return fmt.Errorf("add fonts to pdf '%s': %w", info.Title, err)
+	}
+
+	pdf.SetTitle(info.Title, true)
+	if info.Meta.Author != "" {
+		pdf.SetAuthor("u/"+info.Meta.Author, true)
+	}
+	pdf.SetSubject(description(info), true)
+	pdf.SetKeywords(strings.Join(subjects(info), ", "), true)
+	pdf.SetCreator("reddit-exporter", true)
+	if !info.Meta.Created.IsZero() {
+		pdf.SetCreationDate(info.Meta.Created)
+	}
+
+	r := &pdfRenderer{
+		pdf:      pdf,
+		fontSize: p.opts.FontSize,
+		images:   make(map[string]BookImage, len(info.Images)),
+	}
+	for _, image := range info.Images {
+		r.images[image.Name] = image
+	}
+
+	if info.Cover.Name != "" {
+		pdf.AddPage()
+		r.coverImage(info.Cover)
+	}
+
+	pdf.AddPage()
+	r.renderHtml(titlePageHtml(info))
+
+	if info.Html != "" || len(info.Chapters) == 0 {
+		pdf.AddPage()
+		pdf.Bookmark(info.Title, 0, -1)
+		r.renderHtml(info.Html)
+	}
+
+	prevDepth := -1
+	for _, chapter := range info.Chapters {
+		// bookmark levels can't skip a level
+		depth := min(chapter.Depth, prevDepth+1)
+		prevDepth = depth
+
+		if depth == 0 {
+			pdf.AddPage()
+		} else {
+			r.block()
+		}
+		pdf.Bookmark(chapter.Title, depth, -1)
+		r.renderHtml(chapter.Html)
+	}
+
+	err = pdf.Output(out)
+	if err != nil {
+		return fmt.Errorf("write pdf '%s': %w", info.Title, err)
+	}
+	return nil
+}
+
+func (p PDF) addFonts(pdf *fpdf.Fpdf) error {
+	if p.opts.FontFile != "" {
+		font, err := os.ReadFile(p.opts.FontFile)
+		if err != nil {
+			return fmt.Errorf("read font file: %w", err)
+		}
+		for _, style := range []string{"", "B", "I", "BI"} {
+			pdf.AddUTF8FontFromBytes(pdfTextFont, style, font)
+		}
+	} else {
+		pdf.AddUTF8FontFromBytes(pdfTextFont, "", goregular.TTF)
+		pdf.AddUTF8FontFromBytes(pdfTextFont, "B", gobold.TTF)
+		pdf.AddUTF8FontFromBytes(pdfTextFont, "I", goitalic.TTF)
+		pdf.AddUTF8FontFromBytes(pdfTextFont, "BI", gobolditalic.TTF)
+	}
+	pdf.AddUTF8FontFromBytes(pdfMonoFont, "", gomono.TTF)
+	return pdf.Error()
+}
+
+type pdfList struct {
+	ordered bool
+	index   int
+}
+
+// pdfRenderer lays out html as a flow of text, it supports the subset of html used in reddit posts
+type pdfRenderer struct {
+	pdf      *fpdf.Fpdf
+	fontSize float64
+	images   map[string]BookImage
+
+	bold   int
+	italic int
+	mono   int
+	pre    int
+	scale  float64
+	indent float64
+	link   string
+	lists  []pdfList
+}
+
+func (r *pdfRenderer) renderHtml(src string) {
+	r.scale = 1
+	r.setFont()
+
+	nodes, err := html.ParseFragment(strings.NewReader(src), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		r.text(src)
+		return
+	}
+
+	for _, node := range nodes {
+		r.node(node)
+	}
+	r.block()
+}
+
+func (r *pdfRenderer) lineHeight() float64 {
+	return r.fontSize * r.scale * pdfPointSize * 1.4
+}
+
+func (r *pdfRenderer) setFont() {
+	if r.mono > 0 {
+		r.pdf.SetFont(pdfMonoFont, "", r.fontSize*r.scale)
+		return
+	}
+
+	style := ""
+	if r.bold > 0 {
+		style += "B"
+	}
+	if r.italic > 0 {
+		style += "I"
+	}
+	r.pdf.SetFont(pdfTextFont, style, r.fontSize*r.scale)
+}
+
+// block moves to the start of a new line, if something was written on the current one
+func (r *pdfRenderer) block() {
+	left, _, _, _ := r.pdf.GetMargins()
+	if r.pdf.GetX() > left+0.01 {
+		r.pdf.Ln(r.lineHeight())
+	}
+}
+
+func (r *pdfRenderer) space() {
+	r.block()
+	r.pdf.Ln(r.lineHeight() / 3)
+}
+
+func (r *pdfRenderer) setIndent(delta float64) {
+	r.indent += delta
+	r.pdf.SetLeftMargin(pdfMargin + r.indent)
+	r.pdf.SetX(pdfMargin + r.indent)
+}
+
+func (r *pdfRenderer) text(text string) {
+	if r.pre == 0 {
+		text = strings.Join(strings.Fields(text), " ")
+		if text == "" {
+			return
+		}
+	}
+
+	if r.link != "" {
+		r.pdf.SetTextColor(30, 80, 180)
+		r.pdf.WriteLinkString(r.lineHeight(), text, r.link)
+		r.pdf.SetTextColor(0, 0, 0)
+		return
+	}
+	r.pdf.Write(r.lineHeight(), text)
+}
+
+func (r *pdfRenderer) children(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		r.node(child)
+	}
+}
+
+func (r *pdfRenderer) node(node *html.Node) {
+	switch node.Type {
+	case html.TextNode:
+		r.textNode(node)
+		return
+	case html.ElementNode:
+	default:
+		r.children(node)
+		return
+	}
+
+	switch node.Data {
+	case "script", "style", "head":
+	case "p", "div", "section", "article", "table", "tr":
+		r.block()
+		r.children(node)
+		r.space()
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		headingScales := map[string]float64{"h1": 1.8, "h2": 1.5, "h3": 1.3, "h4": 1.15, "h5": 1.05, "h6": 1}
+		r.space()
+		r.scale = headingScales[node.Data]
+		r.bold++
+		r.setFont()
+		r.children(node)
+		r.block()
+		r.bold--
+		r.scale = 1
+		r.setFont()
+		r.space()
+	case "br":
+		r.pdf.Ln(r.lineHeight())
+	case "hr":
+		r.space()
+		left, _, right, _ := r.pdf.GetMargins()
+		width, _ := r.pdf.GetPageSize()
+		y := r.pdf.GetY()
+		r.pdf.Line(left, y, width-right, y)
+		r.space()
+	case "b", "strong", "th":
+		r.bold++
+		r.setFont()
+		r.children(node)
+		r.bold--
+		r.setFont()
+	case "i", "em", "cite":
+		r.italic++
+		r.setFont()
+		r.children(node)
+		r.italic--
+		r.setFont()
+	case "code", "kbd", "samp":
+		r.mono++
+		r.setFont()
+		r.children(node)
+		r.mono--
+		r.setFont()
+	case "pre":
+		r.block()
+		r.pre++
+		r.mono++
+		r.setFont()
+		r.children(node)
+		r.mono--
+		r.pre--
+		r.setFont()
+		r.space()
+	case "a":
+		prev := r.link
+		r.link = attr(node, "href")
+		r.children(node)
+		r.link = prev
+	case "blockquote":
+		r.block()
+		r.setIndent(6)
+		r.pdf.SetTextColor(80, 80, 80)
+		r.children(node)
+		r.pdf.SetTextColor(0, 0, 0)
+		r.block()
+		r.setIndent(-6)
+	case "ul", "ol":
+		r.block()
+		r.lists = append(r.lists, pdfList{ordered: node.Data == "ol"})
+		r.setIndent(6)
+		r.children(node)
+		r.block()
+		r.setIndent(-6)
+		r.lists = r.lists[:len(r.lists)-1]
+		if len(r.lists) == 0 {
+			r.space()
+		}
+	case "li":
+		r.listItem(node)
+	case "td":
+		r.children(node)
+		r.text(" | ")
+	case "img":
+		r.image(node)
+	default:
+		r.children(node)
+	}
+}
+
+func (r *pdfRenderer) textNode(node *html.Node) {
+	if r.pre == 0 {
+		r.text(node.Data)
+		return
+	}
+	for i, line := range strings.Split(node.Data, "\n") {
+		if i > 0 {
+			r.pdf.Ln(r.lineHeight())
+		}
+		r.text(line)
+	}
+}
+
+func (r *pdfRenderer) listItem(node *html.Node) {
+	r.block()
+	marker := "• "
+	if len(r.lists) > 0 {
+		list := &r.lists[len(r.lists)-1]
+		list.index++
+		if list.ordered {
+			marker = fmt.Sprintf("%d. ", list.index)
+		}
+	}
+
+	// hang the marker to the left of item text
+	markerWidth := r.pdf.GetStringWidth(marker)
+	r.pdf.SetX(pdfMargin + r.indent - markerWidth)
+	r.pdf.Write(r.lineHeight(), marker)
+	r.children(node)
+	r.block()
+}
+
+func (r *pdfRenderer) image(node *html.Node) {
+	name := attr(node, "src")
+	image, ok := r.images[name]
+	if !ok {
+		r.text(fmt.Sprintf("[%s]", cmp.Or(attr(node, "alt"), "image")))
+		return
+	}
+
+	info, ok := r.registerImage(image)
+	if !ok {
+		r.text(fmt.Sprintf("[%s]", cmp.Or(attr(node, "alt"), "image")))
+		return
+	}
+
+	r.block()
+	left, top, right, bottom := r.pdf.GetMargins()
+	pageWidth, pageHeight := r.pdf.GetPageSize()
+	maxWidth := pageWidth - left - right
+	maxHeight := pageHeight - top - bottom
+
+	width, height := info.Extent()
+	scale := min(1, maxWidth/width, maxHeight/height)
+	width, height = width*scale, height*scale
+
+	if r.pdf.GetY()+height > pageHeight-bottom {
+		r.pdf.AddPage()
+	}
+	r.pdf.ImageOptions(image.Name, left, -1, width, height, true, fpdf.ImageOptions{}, 0, "")
+	r.space()
+}
+
+func (r *pdfRenderer) coverImage(cover BookImage) {
+	info, ok := r.registerImage(cover)
+	if !ok {
+		return
+	}
+
+	pageWidth, pageHeight := r.pdf.GetPageSize()
+	width, height := info.Extent()
+	scale := min(pageWidth/width, pageHeight/height)
+	width, height = width*scale, height*scale
+	r.pdf.ImageOptions(cover.Name, (pageWidth-width)/2, (pageHeight-height)/2, width, height, false, fpdf.ImageOptions{}, 0, "")
+}
+
+// registerImage adds image to pdf, formats not supported by pdf are converted to png
+func (r *pdfRenderer) registerImage(image BookImage) (*fpdf.ImageInfoType, bool) {
+	imageType, data := pdfImage(image)
+	if imageType == "" {
+		return nil, false
+	}
+
+	info := r.pdf.RegisterImageOptionsReader(image.Name, fpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+	if !r.pdf.Ok() {
+		// broken image shouldn't break the whole book
+		r.pdf.ClearError()
+		return nil, false
+	}
+	return info, true
+}
+
+func pdfImage(img BookImage) (string, []byte) {
+	switch img.ContentType {
+	case "image/jpeg":
+		return "JPEG", img.Data
+	case "image/png":
+		return "PNG", img.Data
+	case "image/gif":
+		return "GIF", img.Data
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return "", nil
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := png.Encode(buf, decoded); err != nil {
+		return "", nil
+	}
+	return "PNG", buf.Bytes()
+}
+
+func attr(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
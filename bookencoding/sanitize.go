@@ -0,0 +1,117 @@
+package bookencoding
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// tags that are kept with their allowed attributes, other tags are unwrapped to their content
+var sanitizeAllowedTags = map[string][]string{
+	"p": nil, "div": nil, "span": nil, "br": nil, "hr": nil,
+	"h1": nil, "h2": nil, "h3": nil, "h4": nil, "h5": nil, "h6": nil,
+	"b": nil, "strong": nil, "i": nil, "em": nil, "u": nil, "s": nil, "del": nil, "strike": nil,
+	"sup": nil, "sub": nil, "code": nil, "pre": nil, "blockquote": nil, "cite": nil,
+	"ul": nil, "ol": {"start"}, "li": nil,
+	"table": nil, "thead": nil, "tbody": nil, "tr": nil, "th": {"colspan", "rowspan"}, "td": {"colspan", "rowspan"},
+	"a":   {"href"},
+	"img": {"src", "alt"},
+}
+
+// tags that are dropped together with their content
+var sanitizeDroppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"form": true, "input": true, "button": true, "select": true, "textarea": true,
+	"noscript": true, "svg": true, "head": true, "title": true, "meta": true, "link": true,
+}
+
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+// imageAttrs returns attributes for an <img> with src image name, nil replaces the image with alt text
+type imageAttrs func(src string) []xhtml.Attribute
+
+// sanitizeHtml keeps tags and attributes that e-readers support and renders the result as xhtml
+func sanitizeHtml(src string, images imageAttrs) string {
+	nodes, err := xhtml.ParseFragment(strings.NewReader(src), &xhtml.Node{
+		Type:     xhtml.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+	if err != nil {
+		return fmt.Sprintf("<pre>%s</pre>", html.EscapeString(src))
+	}
+
+	var sb strings.Builder
+	for _, node := range nodes {
+		sanitizeNode(&sb, node, images)
+	}
+	return sb.String()
+}
+
+func sanitizeNode(sb *strings.Builder, node *xhtml.Node, images imageAttrs) {
+	switch node.Type {
+	case xhtml.TextNode:
+		sb.WriteString(html.EscapeString(node.Data))
+		return
+	case xhtml.ElementNode:
+	default:
+		sanitizeChildren(sb, node, images)
+		return
+	}
+
+	tag := node.Data
+	if sanitizeDroppedTags[tag] {
+		return
+	}
+	allowedAttrs, ok := sanitizeAllowedTags[tag]
+	if !ok {
+		sanitizeChildren(sb, node, images)
+		return
+	}
+
+	attrs := make([]xhtml.Attribute, 0, len(allowedAttrs))
+	for _, attr := range node.Attr {
+		if attr.Key == "src" && tag == "img" {
+			continue
+		}
+		if attr.Key == "href" && strings.HasPrefix(strings.ToLower(strings.TrimSpace(attr.Val)), "javascript:") {
+			continue
+		}
+		for _, allowed := range allowedAttrs {
+			if attr.Key == allowed {
+				attrs = append(attrs, attr)
+			}
+		}
+	}
+
+	if tag == "img" {
+		imgAttrs := images(attr(node, "src"))
+		if imgAttrs == nil {
+			fmt.Fprintf(sb, "[%s]", html.EscapeString(cmp.Or(attr(node, "alt"), "image")))
+			return
+		}
+		attrs = append(attrs, imgAttrs...)
+	}
+
+	sb.WriteString("<" + tag)
+	for _, attr := range attrs {
+		fmt.Fprintf(sb, ` %s="%s"`, attr.Key, html.EscapeString(attr.Val))
+	}
+	if voidTags[tag] {
+		sb.WriteString("/>")
+		return
+	}
+	sb.WriteString(">")
+	sanitizeChildren(sb, node, images)
+	sb.WriteString("</" + tag + ">")
+}
+
+func sanitizeChildren(sb *strings.Builder, node *xhtml.Node, images imageAttrs) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		sanitizeNode(sb, child, images)
+	}
+}
@@ -1,28 +1,50 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log/slog"
 	"os"
+	"path"
+	"slices"
 	"strings"
 
 	"github.com/alecthomas/kong"
 	"github.com/awryme/reddit-exporter/bookencoding"
+	"github.com/awryme/reddit-exporter/pkg/xhttp"
 	"github.com/awryme/reddit-exporter/redditclient"
 	"github.com/awryme/reddit-exporter/redditexporter"
 	"github.com/awryme/reddit-exporter/redditexporter/bookstore"
 	"github.com/awryme/reddit-exporter/redditexporter/imagestore"
+	"github.com/awryme/reddit-exporter/redditexporter/pagefetcher"
 	"github.com/awryme/slogf"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 )
 
 type App struct {
-	ClientID     string `help:"reddit app client_id" required:"" `
-	ClientSecret string `help:"reddit app client_secret" required:"" `
-	BotToken     string `help:"tg bot token from botfather" required:"" `
-	BasicDir     string `help:"dir to store books"`
+	ClientID      string `help:"reddit app client_id" required:"" `
+	ClientSecret  string `help:"reddit app client_secret" required:"" `
+	BotToken      string `help:"tg bot token from botfather" required:"" `
+	BasicDir      string `help:"dir to store books"`
+	RedditUrl     string `help:"base url of a reddit stand-in for api, auth and image requests, like a local fake server or a caching proxy"`
+	RedditRetries int    `help:"retries of rate limited, failed (5xx) and network failed reddit requests, 0 disables retries" default:"3"`
+	UserAgent     string `help:"user agent of reddit and linked article requests, reddit throttles generic user agents" placeholder:"reddit-exporter/v1.2"`
+
+	Comments         bool `help:"export comment tree of posts as book chapters"`
+	CommentsDepth    int  `help:"max depth of exported comment replies, 0 is unlimited"`
+	CommentsMinScore int  `help:"drop comments (with replies) scored lower, 0 disables the filter"`
+	CommentsLimit    int  `help:"max number of exported comments per post, 0 is unlimited" default:"500"`
+
+	Covers     bool `help:"generate cover images for books"`
+	ImageWidth int  `help:"download image previews not wider than this, 0 downloads originals"`
+	VideoAudio bool `help:"mux the separate audio track into downloaded reddit videos"`
+	Articles   bool `help:"fetch linked pages of link posts and export their main content, requests external sites"`
+
+	PdfPageSize string  `help:"pdf page size: A4, A5, A6, Letter, Legal" default:"A5"`
+	PdfFontSize float64 `help:"pdf font size in points" default:"11"`
+	PdfFontFile string  `type:"existingfile" help:"ttf font file for pdf text, embedded Go fonts are used if empty"`
 }
 
 func (app *App) Run() error {
@@ -36,6 +58,7 @@ func (app *App) Run() error {
 		app.ClientID,
 		app.ClientSecret,
 		redditclient.NewMemoryTokenStore(),
+		app.redditOptions()...,
 	)
 
 	memBookStore := bookstore.NewMemory()
@@ -58,6 +81,7 @@ func (app *App) Run() error {
 		bookencoding.NewEpub(),
 		bookStore,
 		imageStore,
+		app.exporterOptions(log)...,
 	)
 
 	b, err := bot.New(app.BotToken,
@@ -74,6 +98,59 @@ func (app *App) Run() error {
 	return nil
 }
 
+func (app *App) redditOptions() []redditclient.Option {
+	opts := []redditclient.Option{
+		redditclient.WithRetries(app.RedditRetries),
+		redditclient.WithUserAgent(app.userAgent()),
+	}
+	if app.RedditUrl != "" {
+		opts = append(opts, redditclient.WithBaseUrl(app.RedditUrl))
+	}
+	return opts
+}
+
+func (app *App) userAgent() string {
+	return cmp.Or(app.UserAgent, redditclient.DefaultUserAgent)
+}
+
+func (app *App) exporterOptions(log slog.Handler) []redditexporter.Option {
+	opts := []redditexporter.Option{
+		redditexporter.WithLog(log),
+		redditexporter.WithEncoders(
+			bookencoding.NewPDF(bookencoding.PDFOptions{
+				PageSize: app.PdfPageSize,
+				FontSize: app.PdfFontSize,
+				FontFile: app.PdfFontFile,
+			}),
+			bookencoding.NewMobi(),
+			bookencoding.NewFB2(),
+			bookencoding.NewMarkdown(),
+			bookencoding.NewHTML(),
+			bookencoding.NewCBZ(),
+		),
+	}
+	if app.Comments {
+		opts = append(opts, redditexporter.WithComments(redditexporter.CommentOptions{
+			Depth:    app.CommentsDepth,
+			MinScore: app.CommentsMinScore,
+			Limit:    app.CommentsLimit,
+		}))
+	}
+	if app.Covers {
+		opts = append(opts, redditexporter.WithCovers())
+	}
+	if app.ImageWidth > 0 {
+		opts = append(opts, redditexporter.WithImageWidth(app.ImageWidth))
+	}
+	if app.VideoAudio {
+		opts = append(opts, redditexporter.WithVideoAudio())
+	}
+	if app.Articles {
+		opts = append(opts, redditexporter.WithArticles(pagefetcher.NewHTTP(xhttp.NewPublicClient(), app.userAgent())))
+	}
+	return opts
+}
+
 func main() {
 	ctx := kong.Parse(&App{}, kong.DefaultEnvars(""))
 
@@ -102,12 +179,22 @@ func handler(logf slogf.Logf, exporter *redditexporter.Exporter, bookStore *book
 			}
 		}
 
-		urls := strings.Split(msg.Text, "\n")
-		resp, err := exporter.ExportURLs(ctx, urls...)
+		req, err := parseRequest(msg.Text, exporter.Formats())
 		if err != nil {
-			sendText(fmt.Sprintf("error: cannot export urls: %v", err))
+			sendText(fmt.Sprintf("error: %v", err))
+			return
+		}
+
+		status := newStatusMessage(ctx, b, msg.Chat.ID, logf)
+		req.OnProgress = status.progress
+
+		// books of exported urls are sent even if other urls failed
+		resp, exportErr := exporter.Export(ctx, req)
+		if resp == nil {
+			status.finish(ctx, fmt.Sprintf("error: cannot export urls: %v", exportErr))
 			return
 		}
+		status.finish(ctx, resultsText(resp.Sources, exportErr))
 
 		for _, id := range resp.BookIds {
 			book, ok := bookStore.GetBook(id)
@@ -137,22 +224,119 @@ func handler(logf slogf.Logf, exporter *redditexporter.Exporter, bookStore *book
 				return
 			}
 
-			_, err := b.SendDocument(ctx, &bot.SendDocumentParams{
-				ChatID: msg.Chat.ID,
-				Document: &models.InputFileUpload{
-					Filename: image.Name,
-					Data:     image.Data,
-				},
-			})
+			file := &models.InputFileUpload{
+				Filename: image.Name,
+				Data:     image.Data,
+			}
+			// reddit videos are stored with images, send them as playable videos
+			if path.Ext(image.Name) == ".mp4" {
+				_, err = b.SendVideo(ctx, &bot.SendVideoParams{
+					ChatID:            msg.Chat.ID,
+					Video:             file,
+					SupportsStreaming: true,
+				})
+			} else {
+				_, err = b.SendDocument(ctx, &bot.SendDocumentParams{
+					ChatID:   msg.Chat.ID,
+					Document: file,
+				})
+			}
 			if err != nil {
-				sendText(fmt.Sprintf("error: cannot send book with id %s: %v", id, err))
+				sendText(fmt.Sprintf("error: cannot send image with id %s: %v", id, err))
 				return
 			}
-			bookStore.DeleteBook(id)
+			imageStore.DeleteImage(id)
+		}
+	}
+}
+
+// telegram rejects longer messages
+const maxMessageLength = 4096
+
+// resultsText reports the result of every url of the request
+func resultsText(sources []redditexporter.ExportedSource, err error) string {
+	exported := 0
+	// exported listings can have failed posts
+	partial := false
+	for _, source := range sources {
+		if source.Status == redditexporter.StatusExported {
+			exported++
+			partial = partial || source.Err != nil
 		}
+	}
 
-		sendText("Done. ")
+	var sb strings.Builder
+	if exported == len(sources) {
+		sb.WriteString("Done.")
+	} else {
+		fmt.Fprintf(&sb, "Exported %d of %d urls.", exported, len(sources))
+	}
+	if err != nil {
+		fmt.Fprintf(&sb, " Export stopped: %v", err)
+	}
+
+	// a single exported url needs no details
+	if len(sources) > 1 || exported < len(sources) || partial {
+		for _, source := range sources {
+			fmt.Fprintf(&sb, "\n%s: %s", source.Status, cmp.Or(source.Target, source.Url))
+			if source.Status != redditexporter.StatusSkipped && source.Err != nil {
+				fmt.Fprintf(&sb, " (%v)", source.Err)
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// truncateMessage cuts text to the max message length
+func truncateMessage(text string) string {
+	runes := []rune(text)
+	if len(runes) <= maxMessageLength {
+		return text
+	}
+	return string(append(runes[:maxMessageLength-1], '…'))
+}
+
+// parseRequest reads urls from message text, words starting with '/' are commands:
+// /omnibus - join all parts of serialized stories into one book
+// /comic - send images of a comment as one cbz comic archive
+// /images - send images of gallery posts as separate files instead of a book
+// /anthology - export all posts of user, subreddit and search urls as one book
+// /wiki - add pages linked from wiki urls to the wiki book as chapters
+// /<format> - export books in format (/epub, /pdf, /mobi, /fb2, /md, /html, /cbz), can be repeated to get several formats
+func parseRequest(text string, formats []string) (redditexporter.Request, error) {
+	req := redditexporter.Request{}
+	for _, word := range strings.Fields(text) {
+		command, ok := strings.CutPrefix(word, "/")
+		if !ok {
+			req.Urls = append(req.Urls, word)
+			continue
+		}
+
+		switch command {
+		case "omnibus":
+			req.Omnibus = true
+		case "comic":
+			req.Comic = true
+		case "images":
+			req.Images = true
+		case "anthology":
+			req.Anthology = true
+		case "wiki":
+			req.WikiLinks = true
+		case "start", "help":
+		default:
+			if slices.Contains(formats, command) {
+				req.Formats = append(req.Formats, command)
+				continue
+			}
+			return req, fmt.Errorf("unknown command '%s'", word)
+		}
+	}
+	if req.Comic && len(req.Formats) == 0 {
+		req.Formats = []string{bookencoding.NewCBZ().Format()}
 	}
+	return req, nil
 }
 
 func firstNonNil[T any](values ...*T) *T {
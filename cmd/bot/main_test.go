@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/awryme/reddit-exporter/bookencoding"
+	"github.com/awryme/reddit-exporter/redditclient"
+	"github.com/awryme/reddit-exporter/redditclient/reddittest"
+	"github.com/awryme/reddit-exporter/redditexporter"
+	"github.com/awryme/reddit-exporter/redditexporter/bookstore"
+	"github.com/awryme/reddit-exporter/redditexporter/imagestore"
+	"github.com/awryme/slogf"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// telegram is a fake bot api, it records sent messages and documents
+type telegram struct {
+	mu sync.Mutex
+	// messages are texts of sent and edited messages, in order
+	messages  []string
+	documents []string
+}
+
+func (tg *telegram) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tg.mu.Lock()
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/sendMessage"), strings.HasSuffix(r.URL.Path, "/editMessageText"):
+		tg.messages = append(tg.messages, r.FormValue("text"))
+	case strings.HasSuffix(r.URL.Path, "/sendDocument"), strings.HasSuffix(r.URL.Path, "/sendVideo"):
+		for _, files := range r.MultipartForm.File {
+			for _, file := range files {
+				tg.documents = append(tg.documents, file.Filename)
+			}
+		}
+	}
+	tg.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`)
+}
+
+func TestHandler(t *testing.T) {
+	postUrl := func(id string) string {
+		return fmt.Sprintf("https://www.reddit.com/r/test/comments/%s/title/", id)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		// want is the last text of the status message
+		want          string
+		wantDocuments []string
+	}{
+		{
+			name:          "one post",
+			text:          postUrl("p1"),
+			want:          "Done.",
+			wantDocuments: []string{"Post p1.epub"},
+		},
+		{
+			name:          "formats",
+			text:          postUrl("p1") + " /md /html",
+			want:          "Done.",
+			wantDocuments: []string{"Post p1.md", "Post p1.html"},
+		},
+		{
+			name:          "failed url",
+			text:          postUrl("p1") + "\n" + postUrl("missing"),
+			want:          "Exported 1 of 2 urls.\nexported: post r/test/p1\nfailed: post r/test/missing",
+			wantDocuments: []string{"Post p1.epub"},
+		},
+		{
+			name: "unknown command",
+			text: postUrl("p1") + " /unknown",
+			want: "error: unknown command '/unknown'",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := reddittest.NewServer()
+			t.Cleanup(srv.Close)
+			srv.AddPost(redditclient.JsonPostData{
+				Id:        "p1",
+				Title:     "Post p1",
+				Author:    "author",
+				Subreddit: "test",
+				Selfhtml:  html.EscapeString("<p>text of p1</p>"),
+				IsSelf:    true,
+			})
+
+			tg := &telegram{}
+			tgSrv := httptest.NewServer(tg)
+			t.Cleanup(tgSrv.Close)
+
+			app := &App{RedditUrl: srv.URL, PdfPageSize: "A5", PdfFontSize: 11}
+			client := redditclient.New(slog.DiscardHandler, "client", "secret", redditclient.NewMemoryTokenStore(), app.redditOptions()...)
+			bookStore := bookstore.NewMemory()
+			imageStore := imagestore.NewMemory()
+			exporter := redditexporter.New(client, bookencoding.NewEpub(), bookStore, imageStore, app.exporterOptions(slog.DiscardHandler)...)
+
+			b, err := bot.New("token", bot.WithServerURL(tgSrv.URL), bot.WithSkipGetMe())
+			if err != nil {
+				t.Fatalf("create bot: %v", err)
+			}
+			handle := handler(slogf.New(slog.DiscardHandler), exporter, bookStore, imageStore)
+			handle(t.Context(), b, &models.Update{
+				Message: &models.Message{Chat: models.Chat{ID: 1}, Text: test.text},
+			})
+
+			tg.mu.Lock()
+			defer tg.mu.Unlock()
+			if len(tg.messages) == 0 {
+				t.Fatalf("no messages were sent")
+			}
+			if got := tg.messages[len(tg.messages)-1]; !strings.HasPrefix(got, test.want) {
+				t.Errorf("got message %q, want %q", got, test.want)
+			}
+			if !slices.Equal(tg.documents, test.wantDocuments) {
+				t.Errorf("got documents %v, want %v", tg.documents, test.wantDocuments)
+			}
+		})
+	}
+}
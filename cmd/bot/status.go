@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awryme/reddit-exporter/redditexporter"
+	"github.com/awryme/slogf"
+	"github.com/go-telegram/bot"
+)
+
+// telegram throttles frequent edits of a message
+const statusEditInterval = 2 * time.Second
+
+// statusMessage is a message of the chat edited with export progress, it shows the latest event of every url
+type statusMessage struct {
+	bot    *bot.Bot
+	chatID int64
+	logf   slogf.Logf
+	// messageID is 0 if the message was not sent
+	messageID int
+
+	mu    sync.Mutex
+	lines []string
+	// sent is the text of the message
+	sent string
+
+	changed chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newStatusMessage(ctx context.Context, b *bot.Bot, chatID int64, logf slogf.Logf) *statusMessage {
+	status := &statusMessage{
+		bot:     b,
+		chatID:  chatID,
+		logf:    logf,
+		sent:    "Exporting...",
+		changed: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	msg, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   status.sent,
+	})
+	if err != nil {
+		logf("send status message", slogf.Error(err))
+	} else {
+		status.messageID = msg.ID
+	}
+
+	go status.run(ctx)
+	return status
+}
+
+// progress is the progress func of the export, it never waits for telegram
+func (status *statusMessage) progress(event redditexporter.Progress) {
+	status.mu.Lock()
+	if len(status.lines) < event.Count {
+		status.lines = append(status.lines, make([]string, event.Count-len(status.lines))...)
+	}
+	status.lines[event.Index] = redditexporter.ProgressText(event)
+	status.mu.Unlock()
+
+	select {
+	case status.changed <- struct{}{}:
+	default:
+	}
+}
+
+// run edits the message on changes, at most once per statusEditInterval
+func (status *statusMessage) run(ctx context.Context) {
+	defer close(status.stopped)
+
+	for {
+		select {
+		case <-status.changed:
+		case <-status.done:
+			return
+		}
+
+		status.mu.Lock()
+		text := fmt.Sprintf("Exporting...\n%s", strings.Join(status.lines, "\n"))
+		status.mu.Unlock()
+		status.edit(ctx, text)
+
+		select {
+		case <-time.After(statusEditInterval):
+		case <-status.done:
+			return
+		}
+	}
+}
+
+// finish stops progress edits and replaces the message with text, text is sent as a new message if there is none
+func (status *statusMessage) finish(ctx context.Context, text string) {
+	close(status.done)
+	<-status.stopped
+
+	if status.messageID != 0 {
+		status.edit(ctx, text)
+		return
+	}
+	_, err := status.bot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: status.chatID,
+		Text:   truncateMessage(text),
+	})
+	if err != nil {
+		status.logf("send response", slog.String("text", text), slogf.Error(err))
+	}
+}
+
+func (status *statusMessage) edit(ctx context.Context, text string) {
+	text = truncateMessage(text)
+	// telegram rejects edits that don't change the text
+	if status.messageID == 0 || text == status.sent {
+		return
+	}
+
+	_, err := status.bot.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    status.chatID,
+		MessageID: status.messageID,
+		Text:      text,
+	})
+	if err != nil {
+		status.logf("edit status message", slog.String("text", text), slogf.Error(err))
+		return
+	}
+	status.sent = text
+}
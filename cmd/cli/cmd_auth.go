@@ -1,18 +1,32 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"crypto/rand"
 	"fmt"
 	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/awryme/reddit-exporter/redditclient"
 	"github.com/awryme/slogf"
 )
 
+// time to allow access on the reddit page
+const userAuthTimeout = 5 * time.Minute
+
 type AuthCmd struct {
 	ClientID   string `help:"reddit client_id, if empty will be prompted from stdin"`
 	SecretsDir string `type:"path" help:"dir to cache auth token and store creds" default:"~/.reddit-exporter/"`
+	RedditUrl  string `help:"base url of a reddit stand-in for api, auth and image requests, like a local fake server or a caching proxy"`
+
+	User        bool   `help:"authorize a reddit account to export saved and upvoted posts (reddit.com/user/me/saved), stored creds are reused"`
+	RedirectUrl string `help:"redirect uri of the reddit app, a local listener receives the account authorization on it" default:"http://localhost:65010/authorize_callback"`
 }
 
 func (cmd *AuthCmd) Run() error {
@@ -22,7 +36,7 @@ func (cmd *AuthCmd) Run() error {
 	credsfile := filepath.Join(cmd.SecretsDir, credsFileName)
 	tokenfile := filepath.Join(cmd.SecretsDir, tokenFileName)
 
-	creds, err := SaveCredsToFile(credsfile, cmd.ClientID)
+	creds, err := cmd.creds(credsfile)
 	if err != nil {
 		return fmt.Errorf("save cred file: %w", err)
 	}
@@ -30,8 +44,12 @@ func (cmd *AuthCmd) Run() error {
 	logf("saved creds", slog.String("cred_file", credsfile))
 
 	tokenstore := redditclient.NewFileTokenStore(tokenfile)
-	auth := redditclient.NewAuth(log, creds.ClientID, creds.ClientSecret, tokenstore)
-	_, err = auth.ForceAuth()
+	auth := redditclient.NewAuth(log, creds.ClientID, creds.ClientSecret, tokenstore, redditOptions(cmd.RedditUrl)...)
+	if cmd.User {
+		err = authorizeUser(redditclient.NewUserAuth(auth, cmd.RedirectUrl), cmd.RedirectUrl)
+	} else {
+		_, err = auth.ForceAuth()
+	}
 	if err != nil {
 		return fmt.Errorf("auth app on reddit: %w", err)
 	}
@@ -40,3 +58,74 @@ func (cmd *AuthCmd) Run() error {
 
 	return nil
 }
+
+// creds prompts for new creds, account authorization reuses stored ones if there are any
+func (cmd *AuthCmd) creds(credsfile string) (Creds, error) {
+	if cmd.User {
+		creds, err := ReadCredsFromFile(credsfile)
+		if err == nil && (cmd.ClientID == "" || cmd.ClientID == creds.ClientID) {
+			return creds, nil
+		}
+	}
+	return SaveCredsToFile(credsfile, cmd.ClientID)
+}
+
+// authorizeUser runs the authorization code flow, reddit redirects the browser to a local listener on redirectUrl
+func authorizeUser(userAuth *redditclient.UserAuth, redirectUrl string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), userAuthTimeout)
+	defer cancel()
+
+	redirect, err := url.Parse(redirectUrl)
+	if err != nil {
+		return fmt.Errorf("parse redirect url: %w", err)
+	}
+
+	state := rand.Text()
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(cmp.Or(redirect.Path, "/"), func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "unknown authorization state", http.StatusBadRequest)
+			return
+		}
+		if reason := query.Get("error"); reason != "" {
+			sendOnce(errs, fmt.Errorf("reddit denied access: %s", reason))
+			fmt.Fprintln(w, "Access denied, you can close this page")
+			return
+		}
+
+		sendOnce(codes, query.Get("code"))
+		fmt.Fprintln(w, "Access allowed, you can close this page")
+	})
+
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return fmt.Errorf("listen on redirect url: %w", err)
+	}
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	fmt.Println("Open this url to allow access to your reddit account:")
+	fmt.Println(userAuth.AuthorizeUrl(state))
+
+	select {
+	case code := <-codes:
+		return userAuth.Authorize(code)
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("wait for account authorization: %w", ctx.Err())
+	}
+}
+
+// sendOnce sends to a buffered channel of one, repeated redirects are dropped
+func sendOnce[T any](ch chan T, value T) {
+	select {
+	case ch <- value:
+	default:
+	}
+}
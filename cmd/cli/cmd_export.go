@@ -2,25 +2,71 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/awryme/reddit-exporter/bookencoding"
+	"github.com/awryme/reddit-exporter/pkg/xhttp"
 	"github.com/awryme/reddit-exporter/redditclient"
 	"github.com/awryme/reddit-exporter/redditexporter"
 	"github.com/awryme/reddit-exporter/redditexporter/bookstore"
 	"github.com/awryme/reddit-exporter/redditexporter/imagestore"
+	"github.com/awryme/reddit-exporter/redditexporter/pagefetcher"
+	"github.com/awryme/reddit-exporter/redditexporter/syncstore"
 	"github.com/awryme/slogf"
 )
 
 type ExportCmd struct {
 	Urls []string `arg:"" help:"urls to reddit posts, can be in @file format"`
 
-	Dir        string `help:"dir to store books and images" default:".data"`
-	SecretsDir string `type:"path" help:"dir to cache auth token and store creds" default:"~/.reddit-exporter/"`
+	Dir           string   `help:"dir to store books and images" default:".data"`
+	SecretsDir    string   `type:"path" help:"dir to cache auth token and store creds" default:"~/.reddit-exporter/"`
+	Omnibus       bool     `help:"follow serialized stories across parts and export each as one book"`
+	Format        []string `help:"book formats, every book is exported once per format: epub, pdf, mobi, fb2, md, html, cbz" default:"epub"`
+	Comic         bool     `help:"bundle images of comments into one book instead of separate images, use with --format=cbz for comic archives"`
+	Images        bool     `help:"export images of gallery posts as separate images instead of an illustrated book"`
+	RedditUrl     string   `help:"base url of a reddit stand-in for api, auth and image requests, like a local fake server or a caching proxy"`
+	RedditRetries int      `help:"retries of rate limited, failed (5xx) and network failed reddit requests, 0 disables retries" default:"3"`
+	UserAgent     string   `help:"user agent of reddit and linked article requests, reddit throttles generic user agents" placeholder:"reddit-exporter/v1.2"`
+	Concurrency   int      `help:"number of urls exported at once, also limits images downloaded at once, reddit requests are paced by its rate limit" default:"4"`
+	StopOnError   bool     `help:"skip remaining urls after the first failed one, failed urls are reported at the end by default"`
+
+	Anthology     bool      `help:"export posts of user, subreddit listing and search urls as one book instead of a book per post"`
+	UserSubreddit []string  `help:"export posts of user urls only from these subreddits"`
+	UserAfter     time.Time `help:"export posts of user urls created on or after this date (YYYY-MM-DD)" format:"2006-01-02"`
+	UserBefore    time.Time `help:"export posts of user urls created before this date (YYYY-MM-DD)" format:"2006-01-02"`
+	UserMinScore  int       `help:"export posts of user urls scored at least this, 0 disables the filter"`
+
+	ListingLimit    int    `help:"number of exported posts of subreddit listing and search urls (reddit.com/r/<sub>/top?t=week)" default:"25"`
+	ListingMinScore int    `help:"export listed posts scored at least this, 0 disables the filter"`
+	ListingPeriod   string `help:"time filter of top, controversial and search urls without t: hour, day, week, month, year or all"`
+
+	WikiLinks bool `help:"follow links of wiki urls (reddit.com/r/<sub>/wiki/<page>) to other pages of the same wiki and export them as chapters"`
+
+	Sync       bool   `help:"sync user list urls (reddit.com/user/me/saved): export only posts added since the last sync, run 'auth --user' first"`
+	SyncState  string `help:"file to remember synced posts, sync.json in --dir if empty"`
+	SyncRemove bool   `help:"delete books of posts that left synced lists since the last sync"`
+
+	Comments         bool `help:"export comment tree of posts as book chapters"`
+	CommentsDepth    int  `help:"max depth of exported comment replies, 0 is unlimited"`
+	CommentsMinScore int  `help:"drop comments (with replies) scored lower, 0 disables the filter"`
+	CommentsLimit    int  `help:"max number of exported comments per post, 0 is unlimited" default:"500"`
+
+	Covers     bool `help:"generate cover images for books"`
+	ImageWidth int  `help:"download image previews not wider than this, 0 downloads originals"`
+	VideoAudio bool `help:"mux the separate audio track into downloaded reddit videos"`
+	Articles   bool `help:"fetch linked pages of link posts and export their main content, requests external sites"`
+
+	PdfPageSize string  `help:"pdf page size: A4, A5, A6, Letter, Legal" default:"A5"`
+	PdfFontSize float64 `help:"pdf font size in points" default:"11"`
+	PdfFontFile string  `type:"existingfile" help:"ttf font file for pdf text, embedded Go fonts are used if empty"`
 }
 
 func (cmd *ExportCmd) Run() error {
@@ -47,10 +93,11 @@ func (cmd *ExportCmd) Run() error {
 	}
 
 	exporter := redditexporter.New(
-		redditclient.New(log, creds.ClientID, creds.ClientSecret, tokenstore),
+		redditclient.New(log, creds.ClientID, creds.ClientSecret, tokenstore, cmd.redditOptions()...),
 		bookencoding.NewEpub(),
 		bookStore,
 		imageStore,
+		cmd.exporterOptions(log)...,
 	)
 
 	urls, err := parseUrls(cmd.Urls)
@@ -58,8 +105,156 @@ func (cmd *ExportCmd) Run() error {
 		return fmt.Errorf("parse input urls: %w", err)
 	}
 
-	_, err = exporter.ExportURLs(ctx, urls...)
-	return err
+	if cmd.Sync {
+		return cmd.sync(ctx, exporter, urls, slogf.New(log))
+	}
+
+	progress := newProgressPrinter()
+	resp, err := exporter.Export(ctx, redditexporter.Request{
+		Urls:    urls,
+		Omnibus: cmd.Omnibus,
+		Formats: cmd.Format,
+		Comic:   cmd.Comic,
+		Images:  cmd.Images,
+
+		Anthology: cmd.Anthology,
+		User: redditexporter.UserFilter{
+			Subreddits: cmd.UserSubreddit,
+			After:      cmd.UserAfter,
+			Before:     cmd.UserBefore,
+			MinScore:   cmd.UserMinScore,
+		},
+		Listing: redditexporter.ListingFilter{
+			Limit:    cmd.ListingLimit,
+			MinScore: cmd.ListingMinScore,
+			Period:   cmd.ListingPeriod,
+		},
+		WikiLinks:   cmd.WikiLinks,
+		StopOnError: cmd.StopOnError,
+		OnProgress:  progress.progress,
+	})
+	progress.close()
+	if resp == nil {
+		return err
+	}
+
+	printResults(resp.Sources)
+	if err != nil {
+		return err
+	}
+	if failed := countFailed(resp.Sources); failed > 0 {
+		return fmt.Errorf("%d of %d urls failed", failed, len(resp.Sources))
+	}
+	return nil
+}
+
+// printResults prints a table of url results, errors are listed after it
+func printResults(sources []redditexporter.ExportedSource) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tSTATUS\tTARGET\tBOOKS\tIMAGES\tTIME")
+	for i, source := range sources {
+		books := 0
+		for _, ids := range source.BookIds {
+			books += len(ids)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\n",
+			i+1,
+			source.Status,
+			cmp.Or(source.Target, source.Url),
+			books,
+			len(source.ImageIds),
+			source.Duration.Round(100*time.Millisecond),
+		)
+	}
+	w.Flush()
+
+	for i, source := range sources {
+		// exported listings keep errors of failed posts
+		if source.Status != redditexporter.StatusSkipped && source.Err != nil {
+			fmt.Printf("url #%d: %v\n", i+1, source.Err)
+		}
+	}
+}
+
+func countFailed(sources []redditexporter.ExportedSource) int {
+	failed := 0
+	for _, source := range sources {
+		if source.Status != redditexporter.StatusExported {
+			failed++
+		}
+	}
+	return failed
+}
+
+func (cmd *ExportCmd) sync(ctx context.Context, exporter *redditexporter.Exporter, urls []string, logf slogf.Logf) error {
+	store := syncstore.NewFile(cmp.Or(cmd.SyncState, filepath.Join(cmd.Dir, "sync.json")))
+	for _, url := range urls {
+		resp, err := exporter.Sync(ctx, store, redditexporter.SyncRequest{
+			Url:     url,
+			Formats: cmd.Format,
+			Remove:  cmd.SyncRemove,
+		})
+		if err != nil {
+			return fmt.Errorf("sync '%s': %w", url, err)
+		}
+		for _, source := range resp.Exported {
+			if source.Status == redditexporter.StatusFailed {
+				logf("sync post failed", slog.String("url", source.Url), slogf.Error(source.Err))
+			}
+		}
+		logf("synced", slog.String("url", url), slog.Int("exported", len(resp.Exported)), slog.Int("removed", len(resp.Removed)))
+	}
+	return nil
+}
+
+func (cmd *ExportCmd) redditOptions() []redditclient.Option {
+	return append(redditOptions(cmd.RedditUrl),
+		redditclient.WithRetries(cmd.RedditRetries),
+		redditclient.WithUserAgent(cmd.userAgent()),
+	)
+}
+
+func (cmd *ExportCmd) userAgent() string {
+	return cmp.Or(cmd.UserAgent, redditclient.DefaultUserAgent)
+}
+
+func (cmd *ExportCmd) exporterOptions(log slog.Handler) []redditexporter.Option {
+	opts := []redditexporter.Option{
+		redditexporter.WithLog(log),
+		redditexporter.WithEncoders(
+			bookencoding.NewPDF(bookencoding.PDFOptions{
+				PageSize: cmd.PdfPageSize,
+				FontSize: cmd.PdfFontSize,
+				FontFile: cmd.PdfFontFile,
+			}),
+			bookencoding.NewMobi(),
+			bookencoding.NewFB2(),
+			bookencoding.NewMarkdown(),
+			bookencoding.NewHTML(),
+			bookencoding.NewCBZ(),
+		),
+		redditexporter.WithConcurrency(cmd.Concurrency),
+	}
+	if cmd.Comments {
+		opts = append(opts, redditexporter.WithComments(redditexporter.CommentOptions{
+			Depth:    cmd.CommentsDepth,
+			MinScore: cmd.CommentsMinScore,
+			Limit:    cmd.CommentsLimit,
+		}))
+	}
+	if cmd.Covers {
+		opts = append(opts, redditexporter.WithCovers())
+	}
+	if cmd.ImageWidth > 0 {
+		opts = append(opts, redditexporter.WithImageWidth(cmd.ImageWidth))
+	}
+	if cmd.VideoAudio {
+		opts = append(opts, redditexporter.WithVideoAudio())
+	}
+	if cmd.Articles {
+		opts = append(opts, redditexporter.WithArticles(pagefetcher.NewHTTP(xhttp.NewPublicClient(), cmd.userAgent())))
+	}
+	return opts
 }
 
 // custom parse to interpret '@ signed files' @
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/alecthomas/kong"
+	"github.com/awryme/reddit-exporter/redditclient"
 )
 
 const (
@@ -14,6 +15,13 @@ var App struct {
 	Export ExportCmd `cmd:"" help:"export reddit post as book"`
 }
 
+func redditOptions(redditUrl string) []redditclient.Option {
+	if redditUrl == "" {
+		return nil
+	}
+	return []redditclient.Option{redditclient.WithBaseUrl(redditUrl)}
+}
+
 func main() {
 	ctx := kong.Parse(
 		&App,
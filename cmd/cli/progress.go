@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/awryme/reddit-exporter/redditexporter"
+	"golang.org/x/term"
+)
+
+// progressPrinter shows export progress on stderr, finished urls are printed as lines,
+// on terminals the latest event is shown on a status line that is rewritten in place
+type progressPrinter struct {
+	out io.Writer
+	// width of the terminal, 0 if stderr is not a terminal
+	width int
+
+	mu       sync.Mutex
+	finished int
+	status   bool
+}
+
+func newProgressPrinter() *progressPrinter {
+	width := 0
+	fd := int(os.Stderr.Fd())
+	if term.IsTerminal(fd) {
+		// unknown width falls back to a classic terminal
+		width = 80
+		if w, _, err := term.GetSize(fd); err == nil && w > 0 {
+			width = max(w, 10)
+		}
+	}
+	return &progressPrinter{out: os.Stderr, width: width}
+}
+
+func (p *progressPrinter) progress(event redditexporter.Progress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if event.Stage == redditexporter.StageDone {
+		p.finished++
+		p.clearStatus()
+		fmt.Fprintf(p.out, "[%d/%d] %s\n", p.finished, event.Count, redditexporter.ProgressText(event))
+		return
+	}
+	if p.width == 0 {
+		return
+	}
+
+	line := []rune(fmt.Sprintf("[%d/%d] %s", p.finished, event.Count, redditexporter.ProgressText(event)))
+	// a wrapped line can't be rewritten
+	if len(line) >= p.width {
+		line = append(line[:p.width-2], '…')
+	}
+	fmt.Fprintf(p.out, "\r\033[K%s", string(line))
+	p.status = true
+}
+
+// close removes the status line
+func (p *progressPrinter) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clearStatus()
+}
+
+func (p *progressPrinter) clearStatus() {
+	if p.status {
+		fmt.Fprint(p.out, "\r\033[K")
+		p.status = false
+	}
+}
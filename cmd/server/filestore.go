@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 
 	"github.com/awryme/reddit-exporter/httpexporter"
 	"github.com/awryme/reddit-exporter/pkg/jsonfile"
@@ -20,7 +21,10 @@ type Meta map[string]httpexporter.BookInfo
 type FsBookStore struct {
 	dir      string
 	metafile string
-	meta     Meta
+
+	// lock guards meta, stores are used by concurrent exports and syncs
+	lock sync.Mutex
+	meta Meta
 }
 
 func NewFsBookStore(dir string) (*FsBookStore, error) {
@@ -42,11 +46,12 @@ func NewFsBookStore(dir string) (*FsBookStore, error) {
 	}, nil
 }
 
+// saveMeta writes meta to the metafile, lock must be held
 func (ms *FsBookStore) saveMeta() error {
 	return jsonfile.Write(ms.metafile, ms.meta)
 }
 
-func (ms *FsBookStore) SaveBook(id, title, format string, data io.Reader) error {
+func (ms *FsBookStore) SaveBook(id, title, format string, meta httpexporter.BookMeta, data io.Reader) error {
 	file, err := os.Create(filepath.Join(ms.dir, id))
 	if err != nil {
 		return fmt.Errorf("create data file: %w", err)
@@ -57,17 +62,37 @@ func (ms *FsBookStore) SaveBook(id, title, format string, data io.Reader) error
 	if err != nil {
 		return fmt.Errorf("copy data to file: %w", err)
 	}
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
 	ms.meta[id] = httpexporter.BookInfo{
 		ID:     id,
 		Title:  title,
 		Format: format,
 		Size:   n,
+		Meta:   meta,
 	}
 
 	return ms.saveMeta()
 }
 
+func (ms *FsBookStore) DeleteBook(id string) error {
+	err := os.Remove(filepath.Join(ms.dir, id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove data file: %w", err)
+	}
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	delete(ms.meta, id)
+
+	return ms.saveMeta()
+}
+
 func (ms *FsBookStore) ListBooks() ([]httpexporter.BookInfo, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
 	books := make([]httpexporter.BookInfo, 0, len(ms.meta))
 	for _, info := range ms.meta {
 		books = append(books, info)
@@ -90,5 +115,12 @@ func (ms *FsBookStore) DownloadBook(id string, w io.Writer) error {
 }
 
 func (ms *FsBookStore) GetSize(id string) (int64, error) {
-	panic("not impl")
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	info, ok := ms.meta[id]
+	if !ok {
+		return 0, fmt.Errorf("book '%s' not found", id)
+	}
+	return info.Size, nil
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/awryme/reddit-exporter/httpexporter"
+)
+
+// stores are used by concurrent exports and syncs, run with -race
+func TestStoresConcurrentUse(t *testing.T) {
+	books, err := NewFsBookStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("create book store: %v", err)
+	}
+	media, err := NewFsMediaStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("create media store: %v", err)
+	}
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprintf("id%02d", i)
+
+			if err := books.SaveBook(id, "title", "epub", httpexporter.BookMeta{}, strings.NewReader("book")); err != nil {
+				t.Errorf("save book: %v", err)
+			}
+			if _, err := books.ListBooks(); err != nil {
+				t.Errorf("list books: %v", err)
+			}
+			if err := media.SaveImage(id, "image.png", strings.NewReader("image")); err != nil {
+				t.Errorf("save image: %v", err)
+			}
+			if _, err := media.ListMedia(); err != nil {
+				t.Errorf("list media: %v", err)
+			}
+			if i%2 == 0 {
+				if err := books.DeleteBook(id); err != nil {
+					t.Errorf("delete book: %v", err)
+				}
+				if err := media.DeleteImage(id); err != nil {
+					t.Errorf("delete image: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	listed, _ := books.ListBooks()
+	if len(listed) != n/2 {
+		t.Errorf("got %d books, want %d", len(listed), n/2)
+	}
+	size, err := books.GetSize("id01")
+	if err != nil || size != int64(len("book")) {
+		t.Errorf("got size %d, %v of a stored book", size, err)
+	}
+	listedMedia, _ := media.ListMedia()
+	if len(listedMedia) != n/2 {
+		t.Errorf("got %d media, want %d", len(listedMedia), n/2)
+	}
+}
@@ -1,18 +1,22 @@
 package main
 
 import (
+	"cmp"
+	"context"
 	"fmt"
 	"log/slog"
 	"net/netip"
 	"os"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/awryme/reddit-exporter/bookencoding"
 	"github.com/awryme/reddit-exporter/httpexporter"
+	"github.com/awryme/reddit-exporter/pkg/xhttp"
 	"github.com/awryme/reddit-exporter/redditclient"
 	"github.com/awryme/reddit-exporter/redditexporter"
 	"github.com/awryme/reddit-exporter/redditexporter/bookstore"
-	"github.com/awryme/reddit-exporter/redditexporter/imagestore"
+	"github.com/awryme/reddit-exporter/redditexporter/pagefetcher"
 	"github.com/awryme/slogf"
 )
 
@@ -20,9 +24,34 @@ type App struct {
 	Port     int    `default:"8080"`
 	Dir      string `help:"dir to store books" default:".data/exporter-server/books/"`
 	BasicDir string `help:"dir to store a flat basic list of books"`
+	MediaDir string `help:"dir to store images and videos" default:".data/exporter-server/media/"`
 
-	ClientID     string `required:"" help:"reddit app client_id"`
-	ClientSecret string `required:"" help:"reddit app client_secret"`
+	ClientID      string `required:"" help:"reddit app client_id"`
+	ClientSecret  string `required:"" help:"reddit app client_secret"`
+	RedirectUrl   string `help:"public url of the /auth/callback route set as the reddit app redirect uri, enables reddit account authorization for saved and upvoted posts"`
+	TokenFile     string `help:"file to keep the auth token and account authorization across restarts, kept in memory if empty"`
+	RedditUrl     string `help:"base url of a reddit stand-in for api, auth and image requests, like a local fake server or a caching proxy"`
+	RedditRetries int    `help:"retries of rate limited, failed (5xx) and network failed reddit requests, 0 disables retries" default:"3"`
+	UserAgent     string `help:"user agent of reddit and linked article requests, reddit throttles generic user agents" placeholder:"reddit-exporter/v1.2"`
+
+	SyncUrl      []string      `help:"user lists to sync periodically (reddit.com/user/me/saved), new posts are exported with the default format, needs an authorized reddit account"`
+	SyncInterval time.Duration `help:"time between syncs of --sync-url lists" default:"1h"`
+	SyncRemove   bool          `help:"delete books of posts that left synced lists"`
+	SyncState    string        `help:"file to remember synced posts" default:".data/exporter-server/sync.json"`
+
+	Comments         bool `help:"export comment tree of posts as book chapters"`
+	CommentsDepth    int  `help:"max depth of exported comment replies, 0 is unlimited"`
+	CommentsMinScore int  `help:"drop comments (with replies) scored lower, 0 disables the filter"`
+	CommentsLimit    int  `help:"max number of exported comments per post, 0 is unlimited" default:"500"`
+
+	Covers     bool `help:"generate cover images for books"`
+	ImageWidth int  `help:"download image previews not wider than this, 0 downloads originals"`
+	VideoAudio bool `help:"mux the separate audio track into downloaded reddit videos"`
+	Articles   bool `help:"fetch linked pages of link posts and export their main content, requests external sites"`
+
+	PdfPageSize string  `help:"pdf page size: A4, A5, A6, Letter, Legal" default:"A5"`
+	PdfFontSize float64 `help:"pdf font size in points" default:"11"`
+	PdfFontFile string  `type:"existingfile" help:"ttf font file for pdf text, embedded Go fonts are used if empty"`
 }
 
 func (app *App) Run() error {
@@ -49,22 +78,99 @@ func (app *App) Run() error {
 		logf("using basic fs store", slog.String("dir", app.BasicDir))
 	}
 
+	mediaStore, err := NewFsMediaStore(app.MediaDir)
+	if err != nil {
+		return fmt.Errorf("create media filestore: %w", err)
+	}
+
+	var tokenStore redditclient.TokenStore = redditclient.NewMemoryTokenStore()
+	if app.TokenFile != "" {
+		tokenStore = redditclient.NewFileTokenStore(app.TokenFile)
+	}
+
+	client := redditclient.New(log, app.ClientID, app.ClientSecret, tokenStore, app.redditOptions()...)
 	exporter := redditexporter.New(
-		redditclient.New(log, app.ClientID, app.ClientSecret, redditclient.NewMemoryTokenStore()),
+		client,
 		bookencoding.NewEpub(),
 		bookStore,
-		imagestore.NoOpImageStore,
+		mediaStore,
+		app.exporterOptions(log)...,
 	)
 
+	// account authorization stores tokens through the client auth, so the client doesn't refresh them concurrently
+	var userAuth httpexporter.Authorizer
+	if app.RedirectUrl != "" {
+		userAuth = redditclient.NewUserAuth(client.Auth(), app.RedirectUrl)
+	}
+
+	if len(app.SyncUrl) > 0 {
+		go app.runSync(context.Background(), exporter, logf)
+	}
+
 	logf("running", slog.String("addr", listen.String()))
 	svc := httpexporter.New(
 		listen,
 		fsStore,
+		mediaStore,
 		exporter,
+		userAuth,
 	)
 	return svc.Run()
 }
 
+func (app *App) redditOptions() []redditclient.Option {
+	opts := []redditclient.Option{
+		redditclient.WithRetries(app.RedditRetries),
+		redditclient.WithUserAgent(app.userAgent()),
+	}
+	if app.RedditUrl != "" {
+		opts = append(opts, redditclient.WithBaseUrl(app.RedditUrl))
+	}
+	return opts
+}
+
+func (app *App) userAgent() string {
+	return cmp.Or(app.UserAgent, redditclient.DefaultUserAgent)
+}
+
+func (app *App) exporterOptions(log slog.Handler) []redditexporter.Option {
+	opts := []redditexporter.Option{
+		redditexporter.WithLog(log),
+		redditexporter.WithEncoders(
+			bookencoding.NewPDF(bookencoding.PDFOptions{
+				PageSize: app.PdfPageSize,
+				FontSize: app.PdfFontSize,
+				FontFile: app.PdfFontFile,
+			}),
+			bookencoding.NewMobi(),
+			bookencoding.NewFB2(),
+			bookencoding.NewMarkdown(),
+			bookencoding.NewHTML(),
+			bookencoding.NewCBZ(),
+		),
+	}
+	if app.Comments {
+		opts = append(opts, redditexporter.WithComments(redditexporter.CommentOptions{
+			Depth:    app.CommentsDepth,
+			MinScore: app.CommentsMinScore,
+			Limit:    app.CommentsLimit,
+		}))
+	}
+	if app.Covers {
+		opts = append(opts, redditexporter.WithCovers())
+	}
+	if app.ImageWidth > 0 {
+		opts = append(opts, redditexporter.WithImageWidth(app.ImageWidth))
+	}
+	if app.VideoAudio {
+		opts = append(opts, redditexporter.WithVideoAudio())
+	}
+	if app.Articles {
+		opts = append(opts, redditexporter.WithArticles(pagefetcher.NewHTTP(xhttp.NewPublicClient(), app.userAgent())))
+	}
+	return opts
+}
+
 func main() {
 	ctx := kong.Parse(&App{}, kong.DefaultEnvars(""))
 
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/awryme/reddit-exporter/httpexporter"
+	"github.com/awryme/reddit-exporter/pkg/jsonfile"
+)
+
+type MediaMeta map[string]httpexporter.MediaInfo
+
+// FsMediaStore stores exported images and videos
+type FsMediaStore struct {
+	dir      string
+	metafile string
+
+	// lock guards meta, stores are used by concurrent exports and syncs
+	lock sync.Mutex
+	meta MediaMeta
+}
+
+func NewFsMediaStore(dir string) (*FsMediaStore, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("create store dir: %w", err)
+	}
+	metafile := filepath.Join(dir, metafileName)
+	meta, err := jsonfile.Read[MediaMeta](metafile)
+	if err != nil && !errors.Is(err, jsonfile.ErrFileNotFound) {
+		return nil, fmt.Errorf("unmarshal existing meta: %w", err)
+	}
+	if meta == nil {
+		meta = make(MediaMeta)
+	}
+	return &FsMediaStore{
+		dir:      dir,
+		metafile: metafile,
+		meta:     meta,
+	}, nil
+}
+
+// saveMeta writes meta to the metafile, lock must be held
+func (ms *FsMediaStore) saveMeta() error {
+	return jsonfile.Write(ms.metafile, ms.meta)
+}
+
+func (ms *FsMediaStore) SaveImage(id, name string, data io.Reader) error {
+	file, err := os.Create(filepath.Join(ms.dir, id))
+	if err != nil {
+		return fmt.Errorf("create data file: %w", err)
+	}
+	defer file.Close()
+
+	n, err := io.Copy(file, data)
+	if err != nil {
+		return fmt.Errorf("copy data to file: %w", err)
+	}
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	ms.meta[id] = httpexporter.MediaInfo{
+		ID:   id,
+		Name: name,
+		Size: n,
+	}
+
+	return ms.saveMeta()
+}
+
+func (ms *FsMediaStore) DeleteImage(id string) error {
+	err := os.Remove(filepath.Join(ms.dir, id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove data file: %w", err)
+	}
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	delete(ms.meta, id)
+
+	return ms.saveMeta()
+}
+
+func (ms *FsMediaStore) ListMedia() ([]httpexporter.MediaInfo, error) {
+	ms.lock.Lock()
+	defer ms.lock.Unlock()
+
+	media := make([]httpexporter.MediaInfo, 0, len(ms.meta))
+	for _, info := range ms.meta {
+		media = append(media, info)
+	}
+	slices.SortFunc(media, func(a, b httpexporter.MediaInfo) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return media, nil
+}
+
+func (ms *FsMediaStore) DownloadMedia(id string, w io.Writer) error {
+	file, err := os.Open(filepath.Join(ms.dir, id))
+	if err != nil {
+		return fmt.Errorf("open data file: %w", err)
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/awryme/reddit-exporter/redditexporter"
+	"github.com/awryme/reddit-exporter/redditexporter/syncstore"
+	"github.com/awryme/slogf"
+)
+
+// runSync syncs --sync-url lists right away and then every interval until ctx is done,
+// failed syncs are logged and continue on the next run
+func (app *App) runSync(ctx context.Context, exporter *redditexporter.Exporter, logf slogf.Logf) {
+	store := syncstore.NewFile(app.SyncState)
+	ticker := time.NewTicker(app.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, url := range app.SyncUrl {
+			resp, err := exporter.Sync(ctx, store, redditexporter.SyncRequest{
+				Url:    url,
+				Remove: app.SyncRemove,
+			})
+			if err != nil {
+				logf("sync failed", slog.String("url", url), slogf.Error(err))
+				continue
+			}
+			for _, source := range resp.Exported {
+				if source.Status == redditexporter.StatusFailed {
+					logf("sync post failed", slog.String("url", source.Url), slogf.Error(source.Err))
+				}
+			}
+			logf("synced", slog.String("url", url), slog.Int("exported", len(resp.Exported)), slog.Int("removed", len(resp.Removed)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -7,9 +7,12 @@ require (
 	github.com/awryme/slogf v0.0.0-20240608221655-d06d6e131500
 	github.com/denisbrodbeck/machineid v1.0.1
 	github.com/go-chi/chi/v5 v5.2.1
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/go-shiori/go-epub v1.2.1
 	github.com/go-telegram/bot v1.16.0
 	github.com/oklog/ulid/v2 v2.1.0
+	golang.org/x/image v0.32.0
+	golang.org/x/net v0.39.0
 	golang.org/x/term v0.33.0
 	maragu.dev/gomponents v1.1.0
 	maragu.dev/gomponents-htmx v0.6.1
@@ -62,9 +65,9 @@ require (
 	github.com/xanzy/ssh-agent v0.3.3 // indirect
 	github.com/zeebo/xxh3 v1.0.2 // indirect
 	golang.org/x/crypto v0.37.0 // indirect
-	golang.org/x/net v0.39.0 // indirect
-	golang.org/x/sync v0.16.0 // indirect
+	golang.org/x/sync v0.17.0 // indirect
 	golang.org/x/sys v0.34.0 // indirect
+	golang.org/x/text v0.30.0 // indirect
 	gopkg.in/warnings.v0 v0.1.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 	mvdan.cc/sh/v3 v3.12.0 // indirect
@@ -5,17 +5,29 @@ import (
 	"io"
 	"net/http"
 	"net/netip"
+	"time"
 
 	"github.com/awryme/reddit-exporter/httpexporter/ui"
 	"github.com/go-chi/chi/v5"
 )
 
 type (
+	BookMeta = struct {
+		Author    string
+		Subreddit string
+		Url       string
+		Created   time.Time
+		Edited    time.Time
+		Score     int
+		Flair     string
+	}
+
 	BookInfo = struct {
 		ID     string
 		Title  string
 		Format string
 		Size   int64
+		Meta   BookMeta
 	}
 
 	BookStore interface {
@@ -23,33 +35,112 @@ type (
 		DownloadBook(id string, w io.Writer) error
 		GetSize(id string) (int64, error)
 	}
+
+	MediaInfo = struct {
+		ID   string
+		Name string
+		Size int64
+	}
+
+	MediaStore interface {
+		ListMedia() ([]MediaInfo, error)
+		DownloadMedia(id string, w io.Writer) error
+	}
 )
 
 type (
+	ExporterRequest = struct {
+		Urls      []string
+		Omnibus   bool
+		Formats   []string
+		Comic     bool
+		Images    bool
+		Anthology bool
+		User      UserFilter
+		Listing   ListingFilter
+		WikiLinks bool
+		// StopOnError skips urls after the first failed one
+		StopOnError bool
+		OnProgress  func(event ExportProgress)
+	}
+
+	// ExportProgress is a progress event of an url export, Stage is resolved, fetched, post, images, encoding, stored or done
+	ExportProgress = struct {
+		Index  int
+		Count  int
+		Url    string
+		Target string
+		Stage  string
+		Title  string
+		Done   int
+		Total  int
+		Format string
+		Id     string
+		Status string
+		Err    error
+		Text   string
+	}
+
+	UserFilter = struct {
+		Subreddits []string
+		After      time.Time
+		Before     time.Time
+		MinScore   int
+	}
+
+	ListingFilter = struct {
+		Limit    int
+		MinScore int
+		Period   string
+	}
+
+	// ExportedSource is the result of one url, Status is exported, failed or skipped
+	ExportedSource = struct {
+		Url      string
+		Target   string
+		Status   string
+		BookIds  map[string][]string
+		ImageIds []string
+		Err      error
+		Duration time.Duration
+	}
+
 	ExporterResponse = struct {
 		BookIds  []string
 		ImageIds []string
+		Sources  []ExportedSource
 	}
 
 	Exporter interface {
-		ExportURLs(ctx context.Context, urls ...string) (resp *ExporterResponse, err error)
+		Export(ctx context.Context, req ExporterRequest) (resp *ExporterResponse, err error)
+		Formats() []string
+	}
+
+	// Authorizer authorizes a reddit account for saved and upvoted posts,
+	// its redirect url must point to the auth callback route of the service
+	Authorizer interface {
+		AuthorizeUrl(state string) string
+		Authorize(code string) error
 	}
 )
 
 type Service struct {
 	listen   netip.AddrPort
 	store    BookStore
+	media    MediaStore
 	exporter Exporter
+	auth     Authorizer
 }
 
-func New(listen netip.AddrPort, store BookStore, exporter Exporter) *Service {
-	return &Service{listen, store, exporter}
+// New creates the service, auth can be nil to disable reddit account authorization
+func New(listen netip.AddrPort, store BookStore, media MediaStore, exporter Exporter, auth Authorizer) *Service {
+	return &Service{listen, store, media, exporter, auth}
 }
 
 func (svc *Service) Run() error {
 	router := chi.NewRouter()
 
-	ui := ui.New(svc.exporter, svc.store)
+	ui := ui.New(svc.exporter, svc.store, svc.media, svc.auth)
 	router.Group(ui.Handle)
 
 	srv := http.Server{
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"fmt"
+	"net/url"
 )
 
 // routes
@@ -9,10 +10,21 @@ const (
 	IndexPage = "/"
 	Static    = "/static"
 	Download  = "/download"
+	Media     = "/media"
 
+	// AuthLogin redirects to reddit account authorization, reddit redirects back to AuthCallback
+	AuthLogin    = "/auth/login"
+	AuthCallback = "/auth/callback"
+
+	// UiExport starts exports with POST, GET waits for progress of an export
 	UiExport = "/ui/v1/export"
 )
 
+// FmtExportProgress is the progress of export id newer than version
+func FmtExportProgress(id string, version int) string {
+	return fmt.Sprintf("%s?id=%s&version=%d", UiExport, url.QueryEscape(id), version)
+}
+
 func FmtStatic(file string) string {
 	return fmt.Sprintf("%s/%s", Static, file)
 }
@@ -20,3 +32,7 @@ func FmtStatic(file string) string {
 func FmtDownload(id string, filename string) string {
 	return fmt.Sprintf("%s/%s/%s", Download, id, filename)
 }
+
+func FmtMedia(id string, filename string) string {
+	return fmt.Sprintf("%s/%s/%s", Media, id, filename)
+}
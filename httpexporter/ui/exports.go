@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+)
+
+// exportWait is the longest time a progress request waits for new events
+const exportWait = 20 * time.Second
+
+// exportTTL is the time a finished export waits for the browser to get its results
+const exportTTL = 10 * time.Minute
+
+// exportJobs are exports running in the background, the browser long polls them for progress
+type exportJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*exportJob
+}
+
+func newExportJobs() *exportJobs {
+	return &exportJobs{jobs: make(map[string]*exportJob)}
+}
+
+// start runs the export in the background and returns its id
+func (jobs *exportJobs) start(exporter Exporter, req ExporterRequest) string {
+	id := rand.Text()
+	job := &exportJob{changed: make(chan struct{})}
+
+	jobs.mu.Lock()
+	jobs.jobs[id] = job
+	jobs.mu.Unlock()
+
+	req.OnProgress = job.progress
+	go func() {
+		// export outlives the request that started it
+		resp, err := exporter.Export(context.Background(), req)
+		job.update(func() {
+			job.state.done = true
+			job.state.resp = resp
+			job.state.err = err
+		})
+		time.AfterFunc(exportTTL, func() { jobs.remove(id) })
+	}()
+	return id
+}
+
+func (jobs *exportJobs) get(id string) (*exportJob, bool) {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+
+	job, ok := jobs.jobs[id]
+	return job, ok
+}
+
+func (jobs *exportJobs) remove(id string) {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+
+	delete(jobs.jobs, id)
+}
+
+type exportState struct {
+	// version counts changes of the state
+	version int
+	// lines are the latest progress of every url
+	lines []string
+	done  bool
+	resp  *ExporterResponse
+	err   error
+}
+
+type exportJob struct {
+	mu    sync.Mutex
+	state exportState
+	// changed is closed on the next change
+	changed chan struct{}
+}
+
+func (job *exportJob) update(fn func()) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+
+	fn()
+	job.state.version++
+	close(job.changed)
+	job.changed = make(chan struct{})
+}
+
+func (job *exportJob) progress(event ExportProgress) {
+	job.update(func() {
+		if len(job.state.lines) < event.Count {
+			job.state.lines = append(job.state.lines, make([]string, event.Count-len(job.state.lines))...)
+		}
+		job.state.lines[event.Index] = event.Text
+	})
+}
+
+// wait returns the state when it is newer than version, after exportWait or when ctx is done
+func (job *exportJob) wait(ctx context.Context, version int) exportState {
+	timer := time.NewTimer(exportWait)
+	defer timer.Stop()
+
+	for {
+		job.mu.Lock()
+		state := job.state
+		state.lines = append([]string(nil), job.state.lines...)
+		changed := job.changed
+		job.mu.Unlock()
+
+		if state.version > version || state.done {
+			return state
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return state
+		case <-ctx.Done():
+			return state
+		}
+	}
+}
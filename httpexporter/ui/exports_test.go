@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// stepExporter sends progress events of one url and waits for the test between them
+type stepExporter struct {
+	steps chan string
+	err   error
+}
+
+func (ex *stepExporter) Formats() []string { return []string{"epub"} }
+
+func (ex *stepExporter) Export(ctx context.Context, req ExporterRequest) (*ExporterResponse, error) {
+	for text := range ex.steps {
+		req.OnProgress(ExportProgress{Index: 0, Count: 1, Url: req.Urls[0], Text: text})
+	}
+	if ex.err != nil {
+		return nil, ex.err
+	}
+	return &ExporterResponse{Sources: []ExportedSource{{Url: req.Urls[0], Status: "exported"}}}, nil
+}
+
+func TestExportJobs(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []string
+		err   error
+	}{
+		{name: "finished", steps: []string{"post: started", "post: encoding epub", "post: exported"}},
+		{name: "failed", steps: []string{"post: started"}, err: errors.New("export failed")},
+		{name: "no events", steps: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ex := &stepExporter{steps: make(chan string), err: test.err}
+			jobs := newExportJobs()
+			id := jobs.start(ex, ExporterRequest{Urls: []string{"url"}})
+
+			job, ok := jobs.get(id)
+			if !ok {
+				t.Fatalf("started job %s not found", id)
+			}
+
+			version := 0
+			for _, step := range test.steps {
+				ex.steps <- step
+				state := job.wait(t.Context(), version)
+				if state.version <= version {
+					t.Fatalf("got version %d after a step, want newer than %d", state.version, version)
+				}
+				if !slices.Equal(state.lines, []string{step}) {
+					t.Errorf("got lines %v, want %v", state.lines, []string{step})
+				}
+				version = state.version
+			}
+			close(ex.steps)
+
+			state := job.wait(t.Context(), version)
+			if !state.done {
+				t.Fatalf("job is not done after export returned")
+			}
+			if state.err != test.err {
+				t.Errorf("got error %v, want %v", state.err, test.err)
+			}
+			if (state.resp == nil) != (test.err != nil) {
+				t.Errorf("got response %+v with error %v", state.resp, state.err)
+			}
+
+			jobs.remove(id)
+			if _, ok := jobs.get(id); ok {
+				t.Errorf("removed job %s is found", id)
+			}
+		})
+	}
+}
+
+func TestExportJobWaitReturnsOnCancel(t *testing.T) {
+	job := &exportJob{changed: make(chan struct{})}
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	// nothing changes, the request context ends the wait before exportWait
+	state := job.wait(ctx, 0)
+	if state.version != 0 || state.done {
+		t.Errorf("got state %+v, want the unchanged state", state)
+	}
+}
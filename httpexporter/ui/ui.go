@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"path"
+	"time"
 
 	"github.com/awryme/reddit-exporter/httpexporter/internal/routes"
 	"github.com/awryme/reddit-exporter/httpexporter/ui/static"
@@ -13,11 +16,22 @@ import (
 )
 
 type (
+	BookMeta = struct {
+		Author    string
+		Subreddit string
+		Url       string
+		Created   time.Time
+		Edited    time.Time
+		Score     int
+		Flair     string
+	}
+
 	BookInfo = struct {
 		ID     string
 		Title  string
 		Format string
 		Size   int64
+		Meta   BookMeta
 	}
 
 	BookStore interface {
@@ -25,24 +39,103 @@ type (
 		DownloadBook(id string, w io.Writer) error
 		GetSize(id string) (int64, error)
 	}
+
+	MediaInfo = struct {
+		ID   string
+		Name string
+		Size int64
+	}
+
+	MediaStore interface {
+		ListMedia() ([]MediaInfo, error)
+		DownloadMedia(id string, w io.Writer) error
+	}
 )
 
+type ExporterRequest = struct {
+	Urls      []string
+	Omnibus   bool
+	Formats   []string
+	Comic     bool
+	Images    bool
+	Anthology bool
+	User      UserFilter
+	Listing   ListingFilter
+	WikiLinks bool
+	// StopOnError skips urls after the first failed one
+	StopOnError bool
+	OnProgress  func(event ExportProgress)
+}
+
+// ExportProgress is a progress event of an url export, Stage is resolved, fetched, post, images, encoding, stored or done
+type ExportProgress = struct {
+	Index  int
+	Count  int
+	Url    string
+	Target string
+	Stage  string
+	Title  string
+	Done   int
+	Total  int
+	Format string
+	Id     string
+	Status string
+	Err    error
+	// Text describes the event for people
+	Text string
+}
+
+type UserFilter = struct {
+	Subreddits []string
+	After      time.Time
+	Before     time.Time
+	MinScore   int
+}
+
+type ListingFilter = struct {
+	Limit    int
+	MinScore int
+	Period   string
+}
+
+// ExportedSource is the result of one url, Status is exported, failed or skipped
+type ExportedSource = struct {
+	Url      string
+	Target   string
+	Status   string
+	BookIds  map[string][]string
+	ImageIds []string
+	Err      error
+	Duration time.Duration
+}
+
 type ExporterResponse = struct {
 	BookIds  []string
 	ImageIds []string
+	Sources  []ExportedSource
 }
 
 type Exporter interface {
-	ExportURLs(ctx context.Context, urls ...string) (resp *ExporterResponse, err error)
+	Export(ctx context.Context, req ExporterRequest) (resp *ExporterResponse, err error)
+	Formats() []string
+}
+
+type Authorizer interface {
+	AuthorizeUrl(state string) string
+	Authorize(code string) error
 }
 
 type UI struct {
 	exporter Exporter
 	store    BookStore
+	media    MediaStore
+	// auth is nil if reddit account authorization is disabled
+	auth    Authorizer
+	exports *exportJobs
 }
 
-func New(exporter Exporter, store BookStore) *UI {
-	return &UI{exporter, store}
+func New(exporter Exporter, store BookStore, media MediaStore, auth Authorizer) *UI {
+	return &UI{exporter, store, media, auth, newExportJobs()}
 }
 
 func (ui *UI) Handle(router chi.Router) {
@@ -57,7 +150,13 @@ func (ui *UI) Handle(router chi.Router) {
 
 	router.Method(ui.indexHandler())
 	router.Method(ui.exportHandler())
+	router.Method(ui.exportProgressHandler())
 	router.Method(ui.downloadHandler())
+	router.Method(ui.mediaHandler())
+	if ui.auth != nil {
+		router.Method(ui.authLoginHandler())
+		router.Method(ui.authCallbackHandler())
+	}
 }
 
 type HandleParams struct {
@@ -75,12 +174,21 @@ func (ui *UI) indexHandler() (string, string, http.HandlerFunc) {
 			return
 		}
 
-		ctx.Render(IndexPage(books))
+		media, err := ui.media.ListMedia()
+		if ctx.Error(err, "list media") {
+			return
+		}
+
+		ctx.Render(IndexPage(books, media, ui.exporter.Formats(), ui.auth != nil))
 	}
 }
 
 func (ui *UI) exportHandler() (string, string, http.HandlerFunc) {
-	return http.MethodPost, routes.UiExport, handleExportUrls(ui.exporter, ui.store)
+	return http.MethodPost, routes.UiExport, handleExportUrls(ui.exporter, ui.exports)
+}
+
+func (ui *UI) exportProgressHandler() (string, string, http.HandlerFunc) {
+	return http.MethodGet, routes.UiExport, handleExportProgress(ui.exporter, ui.store, ui.media, ui.exports)
 }
 
 func (ui *UI) downloadHandler() (string, string, http.HandlerFunc) {
@@ -95,7 +203,9 @@ func (ui *UI) downloadHandler() (string, string, http.HandlerFunc) {
 		// 	return
 		// }
 		// w.Header().Set("Content-Length", fmt.Sprint(size))
-		w.Header().Set("Content-Type", "application/epub+zip")
+		w.Header().Set("Content-Type", contentType(chi.URLParam(r, "*")))
+		// html books are saved, not opened in the app origin
+		w.Header().Set("Content-Disposition", "attachment")
 		err := ui.store.DownloadBook(id, w)
 		if ctx.Error(err, "download book") {
 			return
@@ -104,3 +214,41 @@ func (ui *UI) downloadHandler() (string, string, http.HandlerFunc) {
 
 	return http.MethodGet, route, handler
 }
+
+func (ui *UI) mediaHandler() (string, string, http.HandlerFunc) {
+	route := routes.FmtMedia("{id}", "*")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		ctx := render.New(w, r)
+		id := chi.URLParam(r, "id")
+
+		w.Header().Set("Content-Type", contentType(chi.URLParam(r, "*")))
+		err := ui.media.DownloadMedia(id, w)
+		if ctx.Error(err, "download media") {
+			return
+		}
+	}
+
+	return http.MethodGet, route, handler
+}
+
+var contentTypes = map[string]string{
+	".epub": "application/epub+zip",
+	".pdf":  "application/pdf",
+	".mobi": "application/x-mobipocket-ebook",
+	".fb2":  "application/x-fictionbook+xml",
+	".md":   "text/markdown; charset=utf-8",
+	".html": "text/html; charset=utf-8",
+	".cbz":  "application/vnd.comicbook+zip",
+	".mp4":  "video/mp4",
+}
+
+func contentType(filename string) string {
+	ext := path.Ext(filename)
+	if contentType, ok := contentTypes[ext]; ok {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
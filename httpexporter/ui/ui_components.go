@@ -1,7 +1,12 @@
 package ui
 
 import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/awryme/reddit-exporter/httpexporter/internal/routes"
 	"github.com/awryme/reddit-exporter/httpexporter/ui/css"
@@ -18,7 +23,7 @@ func component(id string) Node {
 	}
 }
 
-func IndexPage(books []BookInfo) Node {
+func IndexPage(books []BookInfo, media []MediaInfo, formats []string, userAuth bool) Node {
 	return c.HTML5(c.HTML5Props{
 		Title:       "Reddit exporter",
 		Description: "reddit exporter service",
@@ -32,10 +37,17 @@ func IndexPage(books []BookInfo) Node {
 		Body: []Node{
 			Nav(
 				Text("Reddit exporter"),
+				If(userAuth, Group{
+					Text(" · "),
+					A(Href(routes.AuthLogin), Text("Authorize reddit account")),
+				}),
 			),
 			statusBar(),
-			bookInput(),
+			exportProgress("", 0, nil),
+			exportResults(nil),
+			bookInput(formats),
 			bookList(books),
+			mediaList(media),
 		},
 	})
 }
@@ -53,7 +65,79 @@ func statusBar(text ...string) Node {
 	)
 }
 
-func bookInput() Node {
+// exportProgress shows the latest event of every url of a running export, empty id hides it.
+// It gets the next events right after it is loaded, the server holds the request until there are any.
+func exportProgress(id string, version int, lines []string) Node {
+	return Div(
+		component("export_progress"),
+		If(id != "", Group{
+			hx.Get(routes.FmtExportProgress(id, version)),
+			hx.Trigger("load"),
+			H1(Text("Exporting")),
+			Div(
+				css.Flex().Column(),
+				Map(lines, func(line string) Node {
+					return Div(Text(line))
+				}),
+			),
+		}),
+	)
+}
+
+// exportResults lists results of urls of the last export, it is empty until something is exported
+func exportResults(sources []ExportedSource) Node {
+	sourceElem := func(source ExportedSource) Node {
+		status := css.Success()
+		switch {
+		case source.Status == "skipped":
+			status = css.Muted()
+		case source.Status == "failed":
+			status = css.Danger()
+		// exported listings with failed posts
+		case source.Err != nil:
+			status = css.Attention()
+		}
+
+		return Div(
+			Strong(status, Text(source.Status)),
+			Text(" "),
+			A(Href(source.Url), Target("_blank"), Text(cmp.Or(source.Target, source.Url))),
+			If(source.Duration > 0,
+				Small(css.Muted(), Text(" "+source.Duration.Round(100*time.Millisecond).String())),
+			),
+			If(source.Err != nil,
+				Div(Small(css.Danger(), Text(errorText(source.Err)))),
+			),
+		)
+	}
+
+	return Div(
+		component("export_results"),
+		If(len(sources) > 0, Group{
+			H1(Text("Exported urls")),
+			Div(
+				css.Flex().Column(),
+				Map(sources, sourceElem),
+			),
+		}),
+	)
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// exportedAll reports if no url or listed post failed and no url was skipped
+func exportedAll(sources []ExportedSource) bool {
+	return !slices.ContainsFunc(sources, func(source ExportedSource) bool {
+		return source.Err != nil
+	})
+}
+
+func bookInput(formats []string) Node {
 	return Div(
 		component("books_input"),
 		H1(Text("Add posts")),
@@ -62,25 +146,137 @@ func bookInput() Node {
 			Style("width: 80%"),
 			Rows("3"),
 		),
+		Div(
+			Label(
+				Input(Type("checkbox"), Name(exportOmnibusName), Value("on")),
+				Text(" Omnibus: join all parts of a serialized story into one book"),
+			),
+		),
+		Div(
+			Label(
+				Input(Type("checkbox"), Name(exportComicName), Value("on")),
+				Text(" Comic: bundle images of a comment into one book, choose cbz for a comic archive"),
+			),
+		),
+		Div(
+			Label(
+				Input(Type("checkbox"), Name(exportImagesName), Value("on")),
+				Text(" Images: save images of gallery posts as separate files instead of a book"),
+			),
+		),
+		Div(
+			Label(
+				Input(Type("checkbox"), Name(exportAnthologyName), Value("on")),
+				Text(" Anthology: export all posts of a user, subreddit or search url as one book"),
+			),
+		),
+		Div(
+			Label(
+				Input(Type("checkbox"), Name(exportWikiLinksName), Value("on")),
+				Text(" Wiki links: add linked pages of the same wiki to wiki books as chapters"),
+			),
+		),
+		Div(
+			Label(
+				Text("Posts of subreddit and search urls "),
+				Input(Type("number"), Name(exportLimitName), Value(strconv.Itoa(defaultExportLimit)), Min("1"), Style("width: 6em")),
+			),
+			Label(
+				Text(" scored at least "),
+				Input(Type("number"), Name(exportMinScoreName), Placeholder("any"), Style("width: 6em")),
+			),
+			Label(
+				Text(" from the "),
+				Select(
+					Name(exportPeriodName),
+					Option(Value(""), Text("url period")),
+					Map(listingPeriods, func(period string) Node {
+						return Option(Value(period), Text(period))
+					}),
+				),
+				Text(" (top and search urls without t)"),
+			),
+		),
+		Div(
+			Text("Formats "),
+			Map(formats, func(format string) Node {
+				return Label(
+					Input(Type("checkbox"), Name(exportFormatName), Value(format), If(format == formats[0], Checked())),
+					Text(" "+format+" "),
+				)
+			}),
+		),
 		Button(
 			Text("Add"),
 			Type("submit"),
 			hx.Post(routes.UiExport),
-			hx.Include("previous textarea"),
+			hx.Include("closest div"),
 		),
 	)
 }
 
+// bookEntry is one exported book with a file per exported format
+type bookEntry struct {
+	Title string
+	Meta  BookMeta
+	// Files are the latest books of every format, ordered by format
+	Files []BookInfo
+}
+
+// groupBooks joins books with the same title exported from the same source into one entry,
+// books are expected to be ordered by id, so later exports replace earlier files of the same format
+func groupBooks(books []BookInfo) []bookEntry {
+	entries := make([]bookEntry, 0, len(books))
+	index := make(map[[2]string]int)
+	for _, book := range books {
+		key := [2]string{book.Meta.Url, book.Title}
+		i, ok := index[key]
+		if !ok {
+			i = len(entries)
+			index[key] = i
+			entries = append(entries, bookEntry{Title: book.Title})
+		}
+
+		entry := &entries[i]
+		entry.Meta = book.Meta
+		j := slices.IndexFunc(entry.Files, func(file BookInfo) bool {
+			return file.Format == book.Format
+		})
+		if j >= 0 {
+			entry.Files[j] = book
+		} else {
+			entry.Files = append(entry.Files, book)
+		}
+	}
+
+	for _, entry := range entries {
+		slices.SortFunc(entry.Files, func(a, b BookInfo) int {
+			return strings.Compare(a.Format, b.Format)
+		})
+	}
+	return entries
+}
+
 func bookList(books []BookInfo) Node {
-	bookElem := func(book BookInfo) Node {
+	fileElem := func(book BookInfo) Node {
 		filename := book.Title + "." + book.Format
+		return A(
+			Href(routes.FmtDownload(book.ID, filename)),
+			Target("_blank"),
+			Download(filename),
+			Text(book.Format),
+		)
+	}
+
+	entryElem := func(entry bookEntry) Node {
 		return Div(
-			A(
-				Href(routes.FmtDownload(book.ID, filename)),
-				Target("_blank"),
-				Download(filename),
-				Text(filename),
-			),
+			Strong(Text(entry.Title)),
+			Text(" "),
+			Map(entry.Files, func(file BookInfo) Node {
+				return Group{fileElem(file), Text(" ")}
+			}),
+			Br(),
+			bookMeta(entry.Meta),
 		)
 	}
 
@@ -91,7 +287,65 @@ func bookList(books []BookInfo) Node {
 		),
 		Div(
 			css.Flex().Column(),
-			Map(books, bookElem),
+			Map(groupBooks(books), entryElem),
 		),
 	)
 }
+
+// mediaList lists exported images and videos, it is empty until something is exported
+func mediaList(media []MediaInfo) Node {
+	mediaElem := func(info MediaInfo) Node {
+		return Div(
+			A(
+				Href(routes.FmtMedia(info.ID, info.Name)),
+				Target("_blank"),
+				Text(info.Name),
+			),
+			Text(" "),
+			Small(css.Muted(), Text(fmt.Sprintf("%d KB", info.Size/1024))),
+		)
+	}
+
+	return Div(
+		component("media_list"),
+		If(len(media) > 0, Group{
+			H1(Text("Media")),
+			Div(
+				css.Flex().Column(),
+				Map(media, mediaElem),
+			),
+		}),
+	)
+}
+
+func bookMeta(meta BookMeta) Node {
+	// books exported before metadata was stored
+	if meta.Url == "" {
+		return nil
+	}
+
+	details := make([]string, 0, 5)
+	if meta.Author != "" {
+		details = append(details, "u/"+meta.Author)
+	}
+	if meta.Subreddit != "" {
+		details = append(details, "r/"+meta.Subreddit)
+	}
+	if !meta.Created.IsZero() {
+		created := meta.Created.Format(time.DateOnly)
+		if !meta.Edited.IsZero() {
+			created += " (edited)"
+		}
+		details = append(details, created)
+	}
+	details = append(details, fmt.Sprintf("%d points", meta.Score))
+	if meta.Flair != "" {
+		details = append(details, meta.Flair)
+	}
+
+	return Small(
+		css.Muted(),
+		Text(strings.Join(details, " · ")+" · "),
+		A(Href(meta.Url), Target("_blank"), Text("source")),
+	)
+}
@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+
+	"github.com/awryme/reddit-exporter/httpexporter/internal/routes"
+	"github.com/awryme/reddit-exporter/pkg/xhttp/render"
+)
+
+// authStateCookie keeps the state of an authorization in progress, reddit returns it to the callback
+const authStateCookie = "reddit_auth_state"
+
+// authStateAge is the time to allow access on the reddit page, in seconds
+const authStateAge = 10 * 60
+
+func (ui *UI) authLoginHandler() (string, string, http.HandlerFunc) {
+	return http.MethodGet, routes.AuthLogin, func(w http.ResponseWriter, r *http.Request) {
+		state := rand.Text()
+		http.SetCookie(w, &http.Cookie{
+			Name:     authStateCookie,
+			Value:    state,
+			Path:     routes.AuthCallback,
+			MaxAge:   authStateAge,
+			HttpOnly: true,
+			// the callback is a top level navigation from reddit
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, ui.auth.AuthorizeUrl(state), http.StatusFound)
+	}
+}
+
+func (ui *UI) authCallbackHandler() (string, string, http.HandlerFunc) {
+	return http.MethodGet, routes.AuthCallback, func(w http.ResponseWriter, r *http.Request) {
+		ctx := render.New(w, r)
+
+		cookie, err := r.Cookie(authStateCookie)
+		if err != nil || cookie.Value != ctx.Query("state") {
+			ctx.Error(render.ErrorWithCode(fmt.Errorf("unknown authorization state, authorize again"), http.StatusBadRequest))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: authStateCookie, Path: routes.AuthCallback, MaxAge: -1})
+
+		if reason := ctx.Query("error"); reason != "" {
+			ctx.Error(render.ErrorWithCode(fmt.Errorf("reddit denied access: %s", reason), http.StatusForbidden))
+			return
+		}
+
+		err = ui.auth.Authorize(ctx.Query("code"))
+		if ctx.Error(err, "authorize reddit account") {
+			return
+		}
+		http.Redirect(w, r, routes.IndexPage, http.StatusFound)
+	}
+}
@@ -1,53 +1,164 @@
 package ui
 
 import (
-	"context"
+	"cmp"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/awryme/reddit-exporter/pkg/xhttp/render"
 )
 
-const exportUrlsName = "urls"
+const (
+	exportUrlsName      = "urls"
+	exportOmnibusName   = "omnibus"
+	exportFormatName    = "format"
+	exportComicName     = "comic"
+	exportImagesName    = "images"
+	exportAnthologyName = "anthology"
+	exportLimitName     = "limit"
+	exportMinScoreName  = "min_score"
+	exportPeriodName    = "period"
+	exportWikiLinksName = "wiki_links"
 
-func handleExportUrls(exporter Exporter, store BookStore) http.HandlerFunc {
-	exportAndList := func(ctx context.Context, urlsData string) ([]BookInfo, error) {
-		urls := strings.Split(urlsData, "\n")
-		_, err := exporter.ExportURLs(ctx, urls...)
+	exportIdName      = "id"
+	exportVersionName = "version"
+)
+
+// defaultExportLimit is the preset number of exported posts of listing urls
+const defaultExportLimit = 25
+
+// listingPeriods are time filters of top, controversial and search urls
+var listingPeriods = []string{"hour", "day", "week", "month", "year", "all"}
+
+// handleExportUrls starts an export in the background, the rendered progress polls handleExportProgress
+func handleExportUrls(exporter Exporter, jobs *exportJobs) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := render.New(w, r)
+
+		urlsData := strings.TrimSpace(r.PostFormValue(exportUrlsName))
+		if urlsData == "" {
+			ctx.Render(statusBar("empty urls data"))
+			return
+		}
+
+		limit, err := strconv.Atoi(cmp.Or(r.PostFormValue(exportLimitName), "0"))
+		if err != nil || limit < 0 {
+			ctx.Render(statusBar("bad number of listed posts"))
+			return
+		}
+		minScore, err := strconv.Atoi(cmp.Or(r.PostFormValue(exportMinScoreName), "0"))
 		if err != nil {
-			return nil, fmt.Errorf("export reddit url: %w", err)
+			ctx.Render(statusBar("bad min score of listed posts"))
+			return
+		}
+		period := r.PostFormValue(exportPeriodName)
+		if period != "" && !slices.Contains(listingPeriods, period) {
+			ctx.Render(statusBar("bad time period of listed posts"))
+			return
+		}
+
+		req := ExporterRequest{
+			Urls:    strings.Split(urlsData, "\n"),
+			Omnibus: r.PostFormValue(exportOmnibusName) != "",
+			// every checked format checkbox sends a value
+			Formats: r.PostForm[exportFormatName],
+			Comic:   r.PostFormValue(exportComicName) != "",
+			// gallery images are stored as media
+			Images: r.PostFormValue(exportImagesName) != "",
+			// user, listing and search urls are exported as one book
+			Anthology: r.PostFormValue(exportAnthologyName) != "",
+			Listing: ListingFilter{
+				Limit:    limit,
+				MinScore: minScore,
+				Period:   period,
+			},
+			WikiLinks: r.PostFormValue(exportWikiLinksName) != "",
 		}
 
+		id := jobs.start(exporter, req)
+		ctx.Render(
+			exportProgress(id, 0, nil),
+			exportResults(nil),
+			statusBar(),
+		)
+	}
+}
+
+// handleExportProgress waits for new progress of an export, finished exports render their results
+func handleExportProgress(exporter Exporter, store BookStore, mediaStore MediaStore, jobs *exportJobs) http.HandlerFunc {
+	formats := exporter.Formats()
+
+	list := func() ([]BookInfo, []MediaInfo, error) {
 		books, err := store.ListBooks()
 		if err != nil {
-			return nil, fmt.Errorf("list stored books: %w", err)
+			return nil, nil, fmt.Errorf("list stored books: %w", err)
+		}
+		media, err := mediaStore.ListMedia()
+		if err != nil {
+			return nil, nil, fmt.Errorf("list stored media: %w", err)
 		}
-		return books, nil
+		return books, media, nil
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx := render.New(w, r)
 
-		urlsData := strings.TrimSpace(r.PostFormValue(exportUrlsName))
-		if urlsData == "" {
-			ctx.Render(statusBar("empty urls data"))
+		id := ctx.Query(exportIdName)
+		job, ok := jobs.get(id)
+		if !ok {
+			ctx.Render(
+				exportProgress("", 0, nil),
+				statusBar("export not found, it may have finished in another tab"),
+			)
 			return
 		}
 
-		books, err := exportAndList(ctx.Context(), urlsData)
-		if err != nil {
+		version, _ := strconv.Atoi(ctx.Query(exportVersionName))
+		state := job.wait(ctx.Context(), version)
+		if !state.done {
+			ctx.Render(exportProgress(id, state.version, state.lines))
+			return
+		}
+		jobs.remove(id)
+
+		resp, err := state.resp, state.err
+		if resp == nil {
 			ctx.Render(
+				exportProgress("", 0, nil),
 				statusBar(fmt.Sprintf("export books: %v", err.Error())),
 			)
+			return
+		}
 
+		// books of exported urls are listed even if other urls failed
+		books, media, listErr := list()
+		if listErr != nil {
+			ctx.Render(
+				exportProgress("", 0, nil),
+				exportResults(resp.Sources),
+				statusBar(listErr.Error()),
+			)
 			return
 		}
 
-		ctx.Render(
+		status := statusBar()
+		if err != nil {
+			status = statusBar(fmt.Sprintf("export stopped: %v", err))
+		}
+		components := []render.Component{
+			exportProgress("", 0, nil),
 			bookList(books),
-			statusBar(),
-			bookInput(),
-		)
+			mediaList(media),
+			exportResults(resp.Sources),
+			status,
+		}
+		// input is kept for retrying if some urls were not exported
+		if exportedAll(resp.Sources) {
+			components = append(components, bookInput(formats))
+		}
+		ctx.Render(components...)
 	}
 }
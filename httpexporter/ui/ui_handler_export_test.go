@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requestExporter sends requests of started exports to the test
+type requestExporter struct {
+	reqs chan ExporterRequest
+}
+
+func (ex *requestExporter) Formats() []string { return []string{"epub"} }
+
+func (ex *requestExporter) Export(ctx context.Context, req ExporterRequest) (*ExporterResponse, error) {
+	ex.reqs <- req
+	return &ExporterResponse{}, nil
+}
+
+func TestHandleExportUrls(t *testing.T) {
+	tests := []struct {
+		name string
+		form url.Values
+		want ListingFilter
+		// wantImages is set for gallery images saved as media
+		wantImages bool
+		wantErr    string
+	}{
+		{
+			name: "listing filter",
+			form: url.Values{exportLimitName: {"10"}, exportMinScoreName: {"100"}, exportPeriodName: {"week"}},
+			want: ListingFilter{Limit: 10, MinScore: 100, Period: "week"},
+		},
+		{
+			name: "empty filter",
+			form: url.Values{exportLimitName: {""}, exportMinScoreName: {""}, exportPeriodName: {""}},
+			want: ListingFilter{},
+		},
+		{
+			name:       "gallery images",
+			form:       url.Values{exportImagesName: {"on"}},
+			wantImages: true,
+		},
+		{
+			name:    "bad min score",
+			form:    url.Values{exportMinScoreName: {"many"}},
+			wantErr: "bad min score of listed posts",
+		},
+		{
+			name:    "bad period",
+			form:    url.Values{exportPeriodName: {"decade"}},
+			wantErr: "bad time period of listed posts",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ex := &requestExporter{reqs: make(chan ExporterRequest, 1)}
+			handler := handleExportUrls(ex, newExportJobs())
+
+			test.form.Set(exportUrlsName, "https://www.reddit.com/r/test/top")
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if test.wantErr != "" {
+				if !strings.Contains(w.Body.String(), test.wantErr) {
+					t.Errorf("got body %s, want error %s", w.Body.String(), test.wantErr)
+				}
+				return
+			}
+
+			select {
+			case req := <-ex.reqs:
+				if req.Listing != test.want {
+					t.Errorf("got listing filter %+v, want %+v", req.Listing, test.want)
+				}
+				if req.Images != test.wantImages {
+					t.Errorf("got images %v, want %v", req.Images, test.wantImages)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("export was not started: %s", w.Body.String())
+			}
+		})
+	}
+}
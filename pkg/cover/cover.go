@@ -0,0 +1,210 @@
+package cover
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	width  = 1200
+	height = 1600
+	margin = 100
+
+	// subreddit band at the bottom of the cover
+	bandHeight = 260
+	// author line above the band
+	authorHeight = 160
+
+	titleAreaHeight = height - bandHeight - authorHeight - margin
+)
+
+// title font sizes, largest that fits title area is used
+var titleSizes = []float64{110, 96, 84, 72, 60, 50, 40}
+
+var (
+	boldFont    = mustParseFont(gobold.TTF)
+	regularFont = mustParseFont(goregular.TTF)
+)
+
+type Info struct {
+	Title     string
+	Author    string
+	Subreddit string
+}
+
+// PNG renders a cover with title, author and subreddit,
+// colors are chosen from the subreddit name, so books from one subreddit look alike
+func PNG(info Info) ([]byte, error) {
+	background, accent := palette(info.Subreddit)
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	band := image.Rect(0, height-bandHeight, width, height)
+	draw.Draw(img, band, image.NewUniform(accent), image.Point{}, draw.Src)
+
+	titleFace, lines, err := fitTitle(info.Title)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+
+	y := margin + titleFace.Metrics().Ascent.Ceil()
+	for _, line := range lines {
+		drawCentered(img, titleFace, line, y)
+		y += lineHeight(titleFace)
+	}
+
+	if info.Author != "" {
+		authorFace, err := newFace(regularFont, 56)
+		if err != nil {
+			return nil, err
+		}
+		defer authorFace.Close()
+		drawCentered(img, authorFace, "u/"+info.Author, band.Min.Y-authorHeight/2)
+	}
+
+	if info.Subreddit != "" {
+		subFace, err := newFace(boldFont, 72)
+		if err != nil {
+			return nil, err
+		}
+		defer subFace.Close()
+		baseline := band.Min.Y + (bandHeight+subFace.Metrics().CapHeight.Ceil())/2
+		drawCentered(img, subFace, "r/"+info.Subreddit, baseline)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := png.Encode(buf, img); err != nil {
+		return nil, fmt.Errorf("encode cover png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fitTitle picks the largest font size that fits the title into title area,
+// title is cut if it doesn't fit even with the smallest size
+func fitTitle(title string) (font.Face, []string, error) {
+	for i, size := range titleSizes {
+		face, err := newFace(boldFont, size)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		lines := wrap(face, title, width-2*margin)
+		maxLines := titleAreaHeight / lineHeight(face)
+		if len(lines) <= maxLines {
+			return face, lines, nil
+		}
+		if i == len(titleSizes)-1 {
+			lines = lines[:maxLines]
+			lines[maxLines-1] += "…"
+			return face, lines, nil
+		}
+		face.Close()
+	}
+	return nil, nil, fmt.Errorf("no title font sizes")
+}
+
+func lineHeight(face font.Face) int {
+	return face.Metrics().Height.Ceil() * 6 / 5
+}
+
+func wrap(face font.Face, text string, maxWidth int) []string {
+	lines := make([]string, 0)
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && font.MeasureString(face, candidate).Ceil() > maxWidth {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func drawCentered(img draw.Image, face font.Face, text string, baseline int) {
+	drawer := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(color.White),
+		Face: face,
+	}
+	textWidth := drawer.MeasureString(text).Ceil()
+	drawer.Dot = fixed.P(max((width-textWidth)/2, margin/2), baseline)
+	drawer.DrawString(text)
+}
+
+// palette returns a dark background and a lighter accent color with hue derived from the subreddit name
+func palette(subreddit string) (color.Color, color.Color) {
+	hash := fnv.New32a()
+	hash.Write([]byte(strings.ToLower(subreddit)))
+	hue := float64(hash.Sum32()%360) / 360
+
+	return hsl(hue, 0.45, 0.25), hsl(hue, 0.5, 0.4)
+}
+
+func hsl(h, s, l float64) color.RGBA {
+	hueToRGB := func(p, q, t float64) float64 {
+		t -= math.Floor(t)
+		switch {
+		case t < 1.0/6:
+			return p + (q-p)*6*t
+		case t < 1.0/2:
+			return q
+		case t < 2.0/3:
+			return p + (q-p)*(2.0/3-t)*6
+		}
+		return p
+	}
+
+	q := l * (1 + s)
+	if l >= 0.5 {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+
+	return color.RGBA{
+		R: uint8(255 * hueToRGB(p, q, h+1.0/3)),
+		G: uint8(255 * hueToRGB(p, q, h)),
+		B: uint8(255 * hueToRGB(p, q, h-1.0/3)),
+		A: 255,
+	}
+}
+
+func newFace(f *opentype.Font, size float64) (font.Face, error) {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create font face: %w", err)
+	}
+	return face, nil
+}
+
+func mustParseFont(ttf []byte) *opentype.Font {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		panic(fmt.Sprintf("parse embedded font: %v", err))
+	}
+	return f
+}
@@ -0,0 +1,191 @@
+package mp4mux
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// boxes that hold other boxes and are parsed as a tree, other boxes are kept as raw payloads
+var containerBoxes = map[string]bool{
+	"moov": true,
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+	"edts": true,
+	"mvex": true,
+	"moof": true,
+	"traf": true,
+}
+
+// atom is a top level box of a file, kept as raw bytes with its offset in the file
+type atom struct {
+	typ   string
+	start int
+	data  []byte
+}
+
+// box is a parsed box, either with children or with a payload
+type box struct {
+	typ      string
+	payload  []byte
+	children []*box
+}
+
+func readAtoms(data []byte) ([]atom, error) {
+	atoms := make([]atom, 0)
+	for pos := 0; pos < len(data); {
+		size, _, err := boxSize(data[pos:])
+		if err != nil {
+			return nil, fmt.Errorf("read box at %d: %w", pos, err)
+		}
+		atoms = append(atoms, atom{
+			typ:   string(data[pos+4 : pos+8]),
+			start: pos,
+			data:  data[pos : pos+size],
+		})
+		pos += size
+	}
+	return atoms, nil
+}
+
+// boxSize reads box header, size 1 is a 64 bit size after the type, size 0 is the rest of data
+func boxSize(data []byte) (size int, header int, err error) {
+	if len(data) < 8 {
+		return 0, 0, fmt.Errorf("short box header")
+	}
+	size, header = int(binary.BigEndian.Uint32(data)), 8
+	switch size {
+	case 0:
+		size = len(data)
+	case 1:
+		if len(data) < 16 {
+			return 0, 0, fmt.Errorf("short box header")
+		}
+		size, header = int(binary.BigEndian.Uint64(data[8:])), 16
+	}
+	if size < header || size > len(data) {
+		return 0, 0, fmt.Errorf("bad box size %d", size)
+	}
+	return size, header, nil
+}
+
+func parseBox(data []byte) (*box, error) {
+	size, header, err := boxSize(data)
+	if err != nil {
+		return nil, err
+	}
+	b := &box{typ: string(data[4:8])}
+	payload := data[header:size]
+	if !containerBoxes[b.typ] {
+		// payloads are patched in place, don't modify source data
+		b.payload = slices.Clone(payload)
+		return b, nil
+	}
+
+	for pos := 0; pos < len(payload); {
+		size, _, err := boxSize(payload[pos:])
+		if err != nil {
+			return nil, fmt.Errorf("read %s child at %d: %w", b.typ, pos, err)
+		}
+		child, err := parseBox(payload[pos : pos+size])
+		if err != nil {
+			return nil, fmt.Errorf("parse %s child: %w", b.typ, err)
+		}
+		b.children = append(b.children, child)
+		pos += size
+	}
+	return b, nil
+}
+
+func (b *box) size() int {
+	if !containerBoxes[b.typ] {
+		return 8 + len(b.payload)
+	}
+	size := 8
+	for _, child := range b.children {
+		size += child.size()
+	}
+	return size
+}
+
+func (b *box) bytes() []byte {
+	data := make([]byte, 0, b.size())
+	return b.append(data)
+}
+
+func (b *box) append(data []byte) []byte {
+	data = binary.BigEndian.AppendUint32(data, uint32(b.size()))
+	data = append(data, b.typ...)
+	if !containerBoxes[b.typ] {
+		return append(data, b.payload...)
+	}
+	for _, child := range b.children {
+		data = child.append(data)
+	}
+	return data
+}
+
+// find returns descendants of box by path of types, like find("mdia", "minf", "stbl")
+func (b *box) find(path ...string) []*box {
+	boxes := []*box{b}
+	for _, typ := range path {
+		next := make([]*box, 0)
+		for _, parent := range boxes {
+			for _, child := range parent.children {
+				if child.typ == typ {
+					next = append(next, child)
+				}
+			}
+		}
+		boxes = next
+	}
+	return boxes
+}
+
+// first returns the first descendant by path or nil
+func (b *box) first(path ...string) *box {
+	boxes := b.find(path...)
+	if len(boxes) == 0 {
+		return nil
+	}
+	return boxes[0]
+}
+
+// field returns box payload that holds at least size bytes
+func field(b *box, size int) ([]byte, error) {
+	if b == nil {
+		return nil, fmt.Errorf("box not found")
+	}
+	if len(b.payload) < size {
+		return nil, fmt.Errorf("short %s box", b.typ)
+	}
+	return b.payload, nil
+}
+
+// versioned reads a field of a full box, 32 bit at v0 in version 0 and 64 bit at v1 in version 1,
+// payload size is checked with fullBox
+func versioned(p []byte, v0, v1 int) uint64 {
+	if p[0] == 1 {
+		return binary.BigEndian.Uint64(p[v1:])
+	}
+	return uint64(binary.BigEndian.Uint32(p[v0:]))
+}
+
+func setVersioned(p []byte, v0, v1 int, value uint64) {
+	if p[0] == 1 {
+		binary.BigEndian.PutUint64(p[v1:], value)
+		return
+	}
+	binary.BigEndian.PutUint32(p[v0:], uint32(min(value, 1<<32-1)))
+}
+
+// fullBox returns payload of a full box that holds at least size0 bytes in version 0
+// and size1 bytes in version 1
+func fullBox(b *box, size0, size1 int) ([]byte, error) {
+	if b != nil && len(b.payload) > 0 && b.payload[0] == 1 {
+		return field(b, size1)
+	}
+	return field(b, size0)
+}
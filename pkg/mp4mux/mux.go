@@ -0,0 +1,457 @@
+package mp4mux
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// full box sizes for version 0 and 1
+const (
+	mvhdSize0, mvhdSize1 = 100, 112
+	tkhdSize0, tkhdSize1 = 24, 36
+	mdhdSize0, mdhdSize1 = 20, 32
+)
+
+type file struct {
+	atoms      []atom
+	ftyp       []byte
+	moov       *box
+	fragmented bool
+}
+
+// fragment is a moof box with the media data that follows it
+type fragment struct {
+	moof *box
+	// start is moof offset in the source file
+	start int
+	media []atom
+	// time is decode time of the fragment in seconds, negative if unknown
+	time float64
+}
+
+// relocation maps a top level box of a source file to its offset in the muxed file
+type relocation struct {
+	old  int
+	new  int
+	size int
+}
+
+// Mux adds the first track of audio file to video file and writes the result to out,
+// both files must be either progressive (moov and mdat) or fragmented (moov, moof and mdat)
+func Mux(video, audio []byte, out io.Writer) error {
+	v, err := parseFile(video)
+	if err != nil {
+		return fmt.Errorf("parse video: %w", err)
+	}
+	a, err := parseFile(audio)
+	if err != nil {
+		return fmt.Errorf("parse audio: %w", err)
+	}
+	if v.fragmented != a.fragmented {
+		return fmt.Errorf("cannot mux fragmented and progressive files")
+	}
+
+	if v.fragmented {
+		// fragment times use track ids of source files, read them before adding the track
+		videoFrags, err := fragments(v)
+		if err != nil {
+			return fmt.Errorf("read video fragments: %w", err)
+		}
+		audioFrags, err := fragments(a)
+		if err != nil {
+			return fmt.Errorf("read audio fragments: %w", err)
+		}
+
+		_, trackID, err := addTrack(v.moov, a.moov)
+		if err != nil {
+			return fmt.Errorf("add audio track: %w", err)
+		}
+		return muxFragmented(v, videoFrags, audioFrags, trackID, out)
+	}
+
+	trak, _, err := addTrack(v.moov, a.moov)
+	if err != nil {
+		return fmt.Errorf("add audio track: %w", err)
+	}
+	return muxProgressive(v, a, trak, out)
+}
+
+func parseFile(data []byte) (*file, error) {
+	atoms, err := readAtoms(data)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &file{atoms: atoms}
+	for _, at := range atoms {
+		switch at.typ {
+		case "ftyp":
+			f.ftyp = at.data
+		case "moov":
+			f.moov, err = parseBox(at.data)
+			if err != nil {
+				return nil, fmt.Errorf("parse moov: %w", err)
+			}
+		case "moof":
+			f.fragmented = true
+		}
+	}
+	if f.moov == nil {
+		return nil, fmt.Errorf("no moov box")
+	}
+	return f, nil
+}
+
+// addTrack copies the first track of audio movie to movie with a new track id,
+// durations are converted to movie timescale
+func addTrack(moov, audioMoov *box) (*box, uint32, error) {
+	mvhd, err := fullBox(moov.first("mvhd"), mvhdSize0, mvhdSize1)
+	if err != nil {
+		return nil, 0, fmt.Errorf("video mvhd: %w", err)
+	}
+	audioMvhd, err := fullBox(audioMoov.first("mvhd"), mvhdSize0, mvhdSize1)
+	if err != nil {
+		return nil, 0, fmt.Errorf("audio mvhd: %w", err)
+	}
+	timescale, audioTimescale := versioned32(mvhd, 12, 20), versioned32(audioMvhd, 12, 20)
+	if timescale == 0 || audioTimescale == 0 {
+		return nil, 0, fmt.Errorf("zero movie timescale")
+	}
+	scale := func(d uint64) uint64 {
+		hi, lo := bits.Mul64(d, uint64(timescale))
+		if hi >= uint64(audioTimescale) {
+			return math.MaxUint64
+		}
+		q, _ := bits.Div64(hi, lo, uint64(audioTimescale))
+		return q
+	}
+
+	trak := audioMoov.first("trak")
+	if trak == nil {
+		return nil, 0, fmt.Errorf("no audio track")
+	}
+
+	var trackID uint32
+	for _, videoTrak := range moov.find("trak") {
+		tkhd, err := fullBox(videoTrak.first("tkhd"), tkhdSize0, tkhdSize1)
+		if err != nil {
+			return nil, 0, fmt.Errorf("video tkhd: %w", err)
+		}
+		trackID = max(trackID, versioned32(tkhd, 12, 20))
+	}
+	trackID++
+
+	tkhd, err := fullBox(trak.first("tkhd"), tkhdSize0, tkhdSize1)
+	if err != nil {
+		return nil, 0, fmt.Errorf("audio tkhd: %w", err)
+	}
+	setVersioned32(tkhd, 12, 20, trackID)
+	duration := scale(versioned(tkhd, 20, 28))
+	setVersioned(tkhd, 20, 28, duration)
+	for _, elst := range trak.find("edts", "elst") {
+		err := scaleEdits(elst, scale)
+		if err != nil {
+			return nil, 0, fmt.Errorf("audio elst: %w", err)
+		}
+	}
+
+	setVersioned(mvhd, 16, 24, max(versioned(mvhd, 16, 24), duration))
+	setVersioned32(mvhd, 96, 108, trackID+1)
+
+	// audio track goes after video tracks
+	last := len(moov.children) - 1
+	for i, child := range moov.children {
+		if child.typ == "trak" {
+			last = i
+		}
+	}
+	moov.children = slices.Insert(moov.children, last+1, trak)
+
+	// fragmented movies declare fragment defaults per track
+	mvex := moov.first("mvex")
+	if mvex == nil {
+		return trak, trackID, nil
+	}
+	trex := audioMoov.first("mvex", "trex")
+	p, err := field(trex, 8)
+	if err != nil {
+		return nil, 0, fmt.Errorf("audio trex: %w", err)
+	}
+	binary.BigEndian.PutUint32(p[4:], trackID)
+	mvex.children = append(mvex.children, trex)
+
+	mehd := mvex.first("mehd")
+	audioMehd := audioMoov.first("mvex", "mehd")
+	if mehd != nil && audioMehd != nil {
+		p, err := fullBox(mehd, 8, 12)
+		if err != nil {
+			return nil, 0, fmt.Errorf("video mehd: %w", err)
+		}
+		audioP, err := fullBox(audioMehd, 8, 12)
+		if err != nil {
+			return nil, 0, fmt.Errorf("audio mehd: %w", err)
+		}
+		setVersioned(p, 4, 4, max(versioned(p, 4, 4), scale(versioned(audioP, 4, 4))))
+	}
+
+	return trak, trackID, nil
+}
+
+func scaleEdits(elst *box, scale func(uint64) uint64) error {
+	p, err := fullBox(elst, 8, 8)
+	if err != nil {
+		return err
+	}
+	count := int(binary.BigEndian.Uint32(p[4:]))
+	entrySize := 12
+	if p[0] == 1 {
+		entrySize = 20
+	}
+	if len(p) < 8+count*entrySize {
+		return fmt.Errorf("short elst box")
+	}
+	for i := range count {
+		entry := p[8+i*entrySize:]
+		if p[0] == 1 {
+			binary.BigEndian.PutUint64(entry, scale(binary.BigEndian.Uint64(entry)))
+			continue
+		}
+		duration := scale(uint64(binary.BigEndian.Uint32(entry)))
+		binary.BigEndian.PutUint32(entry, uint32(min(duration, math.MaxUint32)))
+	}
+	return nil
+}
+
+func muxProgressive(v, a *file, audioTrak *box, out io.Writer) error {
+	pos := len(v.ftyp) + v.moov.size()
+
+	var videoMedia, audioMedia []atom
+	var videoMap, audioMap []relocation
+	for _, at := range v.atoms {
+		switch at.typ {
+		case "ftyp", "moov", "free", "skip":
+			continue
+		}
+		videoMedia = append(videoMedia, at)
+		videoMap = append(videoMap, relocation{old: at.start, new: pos, size: len(at.data)})
+		pos += len(at.data)
+	}
+	for _, at := range a.atoms {
+		if at.typ != "mdat" {
+			continue
+		}
+		audioMedia = append(audioMedia, at)
+		audioMap = append(audioMap, relocation{old: at.start, new: pos, size: len(at.data)})
+		pos += len(at.data)
+	}
+
+	for _, trak := range v.moov.find("trak") {
+		relocations := videoMap
+		if trak == audioTrak {
+			relocations = audioMap
+		}
+		err := relocateChunks(trak, relocations)
+		if err != nil {
+			return fmt.Errorf("relocate chunks: %w", err)
+		}
+	}
+
+	w := bufio.NewWriter(out)
+	w.Write(v.ftyp)
+	w.Write(v.moov.bytes())
+	for _, at := range slices.Concat(videoMedia, audioMedia) {
+		w.Write(at.data)
+	}
+	return w.Flush()
+}
+
+// relocateChunks updates chunk offset tables of a track to offsets in the muxed file
+func relocateChunks(trak *box, relocations []relocation) error {
+	for _, stco := range trak.find("mdia", "minf", "stbl", "stco") {
+		p, err := field(stco, 8)
+		if err != nil {
+			return err
+		}
+		count := int(binary.BigEndian.Uint32(p[4:]))
+		if len(p) < 8+count*4 {
+			return fmt.Errorf("short stco box")
+		}
+		for i := range count {
+			entry := p[8+i*4:]
+			offset, err := relocate(relocations, uint64(binary.BigEndian.Uint32(entry)))
+			if err != nil {
+				return err
+			}
+			if offset > math.MaxUint32 {
+				return fmt.Errorf("chunk offset %d overflows stco box", offset)
+			}
+			binary.BigEndian.PutUint32(entry, uint32(offset))
+		}
+	}
+
+	for _, co64 := range trak.find("mdia", "minf", "stbl", "co64") {
+		p, err := field(co64, 8)
+		if err != nil {
+			return err
+		}
+		count := int(binary.BigEndian.Uint32(p[4:]))
+		if len(p) < 8+count*8 {
+			return fmt.Errorf("short co64 box")
+		}
+		for i := range count {
+			entry := p[8+i*8:]
+			offset, err := relocate(relocations, binary.BigEndian.Uint64(entry))
+			if err != nil {
+				return err
+			}
+			binary.BigEndian.PutUint64(entry, offset)
+		}
+	}
+	return nil
+}
+
+func relocate(relocations []relocation, offset uint64) (uint64, error) {
+	for _, r := range relocations {
+		if offset >= uint64(r.old) && offset < uint64(r.old+r.size) {
+			return offset - uint64(r.old) + uint64(r.new), nil
+		}
+	}
+	return 0, fmt.Errorf("chunk offset %d is outside of media data", offset)
+}
+
+func fragments(f *file) ([]fragment, error) {
+	timescales := make(map[uint32]uint32)
+	for _, trak := range f.moov.find("trak") {
+		tkhd, err := fullBox(trak.first("tkhd"), tkhdSize0, tkhdSize1)
+		if err != nil {
+			return nil, fmt.Errorf("tkhd: %w", err)
+		}
+		mdhd, err := fullBox(trak.first("mdia", "mdhd"), mdhdSize0, mdhdSize1)
+		if err != nil {
+			return nil, fmt.Errorf("mdhd: %w", err)
+		}
+		timescales[versioned32(tkhd, 12, 20)] = versioned32(mdhd, 12, 20)
+	}
+
+	frags := make([]fragment, 0)
+	for _, at := range f.atoms {
+		switch at.typ {
+		case "moof":
+			moof, err := parseBox(at.data)
+			if err != nil {
+				return nil, fmt.Errorf("parse moof: %w", err)
+			}
+			frags = append(frags, fragment{
+				moof:  moof,
+				start: at.start,
+				time:  fragmentTime(moof, timescales),
+			})
+		case "mdat":
+			if len(frags) == 0 {
+				return nil, fmt.Errorf("media data before the first fragment")
+			}
+			frags[len(frags)-1].media = append(frags[len(frags)-1].media, at)
+		}
+	}
+	return frags, nil
+}
+
+// fragmentTime is the decode time of the first track fragment in seconds
+func fragmentTime(moof *box, timescales map[uint32]uint32) float64 {
+	traf := moof.first("traf")
+	if traf == nil {
+		return -1
+	}
+	tfhd, err := field(traf.first("tfhd"), 8)
+	if err != nil {
+		return -1
+	}
+	tfdt, err := fullBox(traf.first("tfdt"), 8, 12)
+	if err != nil {
+		return -1
+	}
+	timescale := timescales[binary.BigEndian.Uint32(tfhd[4:])]
+	if timescale == 0 {
+		return -1
+	}
+	return float64(versioned(tfdt, 4, 4)) / float64(timescale)
+}
+
+func muxFragmented(v *file, videoFrags, audioFrags []fragment, trackID uint32, out io.Writer) error {
+	for _, frag := range audioFrags {
+		for _, traf := range frag.moof.find("traf") {
+			tfhd, err := field(traf.first("tfhd"), 8)
+			if err != nil {
+				return fmt.Errorf("audio tfhd: %w", err)
+			}
+			binary.BigEndian.PutUint32(tfhd[4:], trackID)
+		}
+	}
+
+	// interleave fragments by time, players read the file in order
+	frags := slices.Concat(videoFrags, audioFrags)
+	timed := !slices.ContainsFunc(frags, func(frag fragment) bool { return frag.time < 0 })
+	if timed {
+		slices.SortStableFunc(frags, func(a, b fragment) int {
+			return cmp.Compare(a.time, b.time)
+		})
+	}
+
+	w := bufio.NewWriter(out)
+	w.Write(v.ftyp)
+	w.Write(v.moov.bytes())
+
+	pos := len(v.ftyp) + v.moov.size()
+	for i, frag := range frags {
+		mfhd, err := field(frag.moof.first("mfhd"), 8)
+		if err != nil {
+			return fmt.Errorf("mfhd: %w", err)
+		}
+		binary.BigEndian.PutUint32(mfhd[4:], uint32(i+1))
+
+		// explicit base offsets are absolute, other data offsets are relative to moof
+		for _, traf := range frag.moof.find("traf") {
+			tfhd, err := field(traf.first("tfhd"), 8)
+			if err != nil {
+				return fmt.Errorf("tfhd: %w", err)
+			}
+			if binary.BigEndian.Uint32(tfhd)&1 == 0 {
+				continue
+			}
+			if len(tfhd) < 16 {
+				return fmt.Errorf("short tfhd box")
+			}
+			base := binary.BigEndian.Uint64(tfhd[8:])
+			binary.BigEndian.PutUint64(tfhd[8:], base-uint64(frag.start)+uint64(pos))
+		}
+
+		w.Write(frag.moof.bytes())
+		pos += frag.moof.size()
+		for _, at := range frag.media {
+			w.Write(at.data)
+			pos += len(at.data)
+		}
+	}
+	return w.Flush()
+}
+
+func versioned32(p []byte, v0, v1 int) uint32 {
+	if p[0] == 1 {
+		return binary.BigEndian.Uint32(p[v1:])
+	}
+	return binary.BigEndian.Uint32(p[v0:])
+}
+
+func setVersioned32(p []byte, v0, v1 int, value uint32) {
+	if p[0] == 1 {
+		binary.BigEndian.PutUint32(p[v1:], value)
+		return
+	}
+	binary.BigEndian.PutUint32(p[v0:], value)
+}
@@ -0,0 +1,249 @@
+package mp4mux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// media is a track of a test file, chunks are 4 byte strings in media data
+type media struct {
+	trackID   uint32
+	timescale uint32
+	duration  uint32
+	chunks    []string
+	// times are decode times of fragments in timescale units, files with times are fragmented
+	times []uint64
+}
+
+func leaf(typ string, payload []byte) *box {
+	return &box{typ: typ, payload: payload}
+}
+
+func container(typ string, children ...*box) *box {
+	return &box{typ: typ, children: children}
+}
+
+// fullPayload is a version 0 full box payload of size bytes with 32 bit fields at offsets
+func fullPayload(size int, fields map[int]uint32) []byte {
+	p := make([]byte, size)
+	for offset, value := range fields {
+		binary.BigEndian.PutUint32(p[offset:], value)
+	}
+	return p
+}
+
+func mdat(data string) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(8+len(data))), "mdat"+data...)
+}
+
+// build writes a progressive or fragmented file of one track
+func (m media) build() []byte {
+	ftyp := leaf("ftyp", []byte("isom\x00\x00\x02\x00isom"))
+	mvhd := leaf("mvhd", fullPayload(100, map[int]uint32{12: m.timescale, 16: m.duration, 96: m.trackID + 1}))
+	tkhd := leaf("tkhd", fullPayload(84, map[int]uint32{12: m.trackID, 20: m.duration}))
+	elst := leaf("elst", fullPayload(20, map[int]uint32{4: 1, 8: m.duration}))
+	mdhd := leaf("mdhd", fullPayload(24, map[int]uint32{12: m.timescale, 16: m.duration}))
+	stco := leaf("stco", fullPayload(8+4*len(m.chunks), map[int]uint32{4: uint32(len(m.chunks))}))
+	trak := container("trak", tkhd, container("edts", elst), container("mdia", mdhd, container("minf", container("stbl", stco))))
+	moov := container("moov", mvhd, trak)
+
+	if len(m.times) == 0 {
+		// chunks follow each other in one mdat
+		pos := len(ftyp.bytes()) + moov.size() + 8
+		for i := range m.chunks {
+			binary.BigEndian.PutUint32(stco.payload[8+4*i:], uint32(pos+4*i))
+		}
+		return slices.Concat(ftyp.bytes(), moov.bytes(), mdat(strings.Join(m.chunks, "")))
+	}
+
+	mehd := leaf("mehd", fullPayload(8, map[int]uint32{4: m.duration}))
+	trex := leaf("trex", fullPayload(24, map[int]uint32{4: m.trackID}))
+	moov.children = append(moov.children, container("mvex", mehd, trex))
+	data := slices.Concat(ftyp.bytes(), moov.bytes())
+	for i, chunk := range m.chunks {
+		// tfhd has an explicit base offset to the chunk, tfdt is version 1 with 64 bit time
+		tfhd := leaf("tfhd", fullPayload(16, map[int]uint32{0: 1, 4: m.trackID}))
+		tfdt := leaf("tfdt", append([]byte{1, 0, 0, 0}, binary.BigEndian.AppendUint64(nil, m.times[i])...))
+		moof := container("moof", leaf("mfhd", fullPayload(8, map[int]uint32{4: uint32(i + 1)})), container("traf", tfhd, tfdt))
+		binary.BigEndian.PutUint64(tfhd.payload[8:], uint64(len(data)+moof.size()+8))
+		data = slices.Concat(data, moof.bytes(), mdat(chunk))
+	}
+	return data
+}
+
+// layout is what a test reads from a muxed file
+type layout struct {
+	types []string
+	// tracks are track ids of traks in moov for progressive files and of fragments for fragmented files
+	tracks []uint32
+	// chunks are media read at chunk offsets of traks or at base offsets of fragments, in the same order
+	chunks []string
+	// durations of the movie, the added track and its edit, all in movie timescale
+	duration      uint64
+	audioDuration uint64
+	editDuration  uint64
+}
+
+func readLayout(t *testing.T, data []byte) layout {
+	atoms, err := readAtoms(data)
+	if err != nil {
+		t.Fatalf("read muxed atoms: %v", err)
+	}
+
+	var l layout
+	var moov *box
+	fragment := 0
+	for _, at := range atoms {
+		l.types = append(l.types, at.typ)
+		b, err := parseBox(at.data)
+		if err != nil {
+			t.Fatalf("parse muxed %s: %v", at.typ, err)
+		}
+		switch at.typ {
+		case "moov":
+			moov = b
+		case "moof":
+			fragment++
+			if seq := binary.BigEndian.Uint32(b.first("mfhd").payload[4:]); seq != uint32(fragment) {
+				t.Errorf("fragment #%d has sequence number %d", fragment, seq)
+			}
+			tfhd := b.first("traf", "tfhd").payload
+			l.tracks = append(l.tracks, binary.BigEndian.Uint32(tfhd[4:]))
+			base := binary.BigEndian.Uint64(tfhd[8:])
+			l.chunks = append(l.chunks, string(data[base:base+4]))
+		}
+	}
+	if moov == nil {
+		t.Fatalf("no moov in muxed file")
+	}
+
+	traks := moov.find("trak")
+	for _, trak := range traks {
+		if fragment > 0 {
+			continue
+		}
+		l.tracks = append(l.tracks, binary.BigEndian.Uint32(trak.first("tkhd").payload[12:]))
+		stco := trak.first("mdia", "minf", "stbl", "stco").payload
+		for i := range int(binary.BigEndian.Uint32(stco[4:])) {
+			offset := binary.BigEndian.Uint32(stco[8+4*i:])
+			l.chunks = append(l.chunks, string(data[offset:offset+4]))
+		}
+	}
+
+	audio := traks[len(traks)-1]
+	l.audioDuration = uint64(binary.BigEndian.Uint32(audio.first("tkhd").payload[20:]))
+	l.editDuration = uint64(binary.BigEndian.Uint32(audio.first("edts", "elst").payload[8:]))
+	l.duration = uint64(binary.BigEndian.Uint32(moov.first("mvhd").payload[16:]))
+	if next := binary.BigEndian.Uint32(moov.first("mvhd").payload[96:]); next != uint32(len(traks)+1) {
+		t.Errorf("got next track id %d, want %d", next, len(traks)+1)
+	}
+	if mehd := moov.first("mvex", "mehd"); mehd != nil {
+		if d := uint64(binary.BigEndian.Uint32(mehd.payload[4:])); d != l.duration {
+			t.Errorf("got fragment duration %d, want movie duration %d", d, l.duration)
+		}
+		if trexs := moov.find("mvex", "trex"); len(trexs) != len(traks) {
+			t.Errorf("got %d trex boxes, want one per track", len(trexs))
+		}
+	}
+	return l
+}
+
+func TestMux(t *testing.T) {
+	tests := []struct {
+		name    string
+		video   media
+		audio   media
+		want    layout
+		wantErr bool
+	}{
+		{
+			name:  "progressive",
+			video: media{trackID: 1, timescale: 1000, duration: 2000, chunks: []string{"vid0", "vid1"}},
+			audio: media{trackID: 1, timescale: 44100, duration: 88200, chunks: []string{"aud0"}},
+			want: layout{
+				types:         []string{"ftyp", "moov", "mdat", "mdat"},
+				tracks:        []uint32{1, 2},
+				chunks:        []string{"vid0", "vid1", "aud0"},
+				duration:      2000,
+				audioDuration: 2000,
+				editDuration:  2000,
+			},
+		},
+		{
+			name:  "progressive with longer audio",
+			video: media{trackID: 1, timescale: 600, duration: 1200, chunks: []string{"vid0"}},
+			audio: media{trackID: 3, timescale: 48000, duration: 144000, chunks: []string{"aud0", "aud1"}},
+			want: layout{
+				types:         []string{"ftyp", "moov", "mdat", "mdat"},
+				tracks:        []uint32{1, 2},
+				chunks:        []string{"vid0", "aud0", "aud1"},
+				duration:      1800,
+				audioDuration: 1800,
+				editDuration:  1800,
+			},
+		},
+		{
+			name:  "fragmented interleaves by time",
+			video: media{trackID: 1, timescale: 1000, duration: 4000, chunks: []string{"vid0", "vid1"}, times: []uint64{0, 2000}},
+			audio: media{trackID: 1, timescale: 44100, duration: 176400, chunks: []string{"aud0", "aud1"}, times: []uint64{44100, 132300}},
+			want: layout{
+				types:         []string{"ftyp", "moov", "moof", "mdat", "moof", "mdat", "moof", "mdat", "moof", "mdat"},
+				tracks:        []uint32{1, 2, 1, 2},
+				chunks:        []string{"vid0", "aud0", "vid1", "aud1"},
+				duration:      4000,
+				audioDuration: 4000,
+				editDuration:  4000,
+			},
+		},
+		{
+			name:    "fragmented video and progressive audio",
+			video:   media{trackID: 1, timescale: 1000, duration: 1000, chunks: []string{"vid0"}, times: []uint64{0}},
+			audio:   media{trackID: 1, timescale: 44100, duration: 44100, chunks: []string{"aud0"}},
+			wantErr: true,
+		},
+		{
+			name:    "audio without moov",
+			video:   media{trackID: 1, timescale: 1000, duration: 1000, chunks: []string{"vid0"}},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			audio := test.audio.build()
+			if test.audio.timescale == 0 {
+				audio = mdat("aud0")
+			}
+
+			out := bytes.NewBuffer(nil)
+			err := Mux(test.video.build(), audio, out)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got no error, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mux: %v", err)
+			}
+
+			got := readLayout(t, out.Bytes())
+			if !slices.Equal(got.types, test.want.types) {
+				t.Errorf("got boxes %v, want %v", got.types, test.want.types)
+			}
+			if !slices.Equal(got.tracks, test.want.tracks) {
+				t.Errorf("got tracks %v, want %v", got.tracks, test.want.tracks)
+			}
+			if !slices.Equal(got.chunks, test.want.chunks) {
+				t.Errorf("got chunks %v, want %v", got.chunks, test.want.chunks)
+			}
+			if got.duration != test.want.duration || got.audioDuration != test.want.audioDuration || got.editDuration != test.want.editDuration {
+				t.Errorf("got durations movie %d, audio %d, edit %d, want %d, %d, %d",
+					got.duration, got.audioDuration, got.editDuration,
+					test.want.duration, test.want.audioDuration, test.want.editDuration)
+			}
+		})
+	}
+}
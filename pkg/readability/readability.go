@@ -0,0 +1,510 @@
+package readability
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var ErrNoContent = errors.New("page has no readable content")
+
+type Article struct {
+	Title    string
+	Byline   string
+	SiteName string
+	// Html is the main content of the page, links and images have absolute urls
+	Html string
+}
+
+// class and id patterns, same as in mozilla readability
+var (
+	unlikelyPattern = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|related|remark|replies|rss|shoutbox|sidebar|skyscraper|social|sponsor|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote|newsletter|subscribe|cookie`)
+	maybePattern    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positivePattern = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|pagination|post|text|blog|story`)
+	negativePattern = regexp.MustCompile(`(?i)-ad-|hidden|^hid$| hid$| hid |^hid |banner|combx|comment|com-|contact|footer|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|widget`)
+	sentenceEnd     = regexp.MustCompile(`\.( |$)`)
+)
+
+// elements that are never part of the content
+var removedTags = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Form:     true,
+	atom.Nav:      true,
+	atom.Header:   true,
+	atom.Footer:   true,
+	atom.Aside:    true,
+	atom.Button:   true,
+	atom.Input:    true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Svg:      true,
+	atom.Canvas:   true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Link:     true,
+	atom.Meta:     true,
+	atom.Template: true,
+	atom.Dialog:   true,
+}
+
+// elements that score their parents as paragraphs of text
+var scoredTags = map[atom.Atom]bool{
+	atom.P:       true,
+	atom.Pre:     true,
+	atom.Td:      true,
+	atom.Section: true,
+	atom.H2:      true,
+	atom.H3:      true,
+	atom.H4:      true,
+	atom.H5:      true,
+	atom.H6:      true,
+}
+
+var blockTags = map[atom.Atom]bool{
+	atom.Address:    true,
+	atom.Article:    true,
+	atom.Aside:      true,
+	atom.Blockquote: true,
+	atom.Div:        true,
+	atom.Dl:         true,
+	atom.Figure:     true,
+	atom.Footer:     true,
+	atom.Form:       true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Header:     true,
+	atom.Hr:         true,
+	atom.Main:       true,
+	atom.Ol:         true,
+	atom.P:          true,
+	atom.Pre:        true,
+	atom.Section:    true,
+	atom.Table:      true,
+	atom.Ul:         true,
+}
+
+// Extract finds the main content of an html page like firefox reader view does,
+// pageUrl resolves relative links and images
+func Extract(page io.Reader, pageUrl string) (*Article, error) {
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil, fmt.Errorf("parse page url: %w", err)
+	}
+	doc, err := html.Parse(page)
+	if err != nil {
+		return nil, fmt.Errorf("parse page html: %w", err)
+	}
+
+	article := pageMeta(doc)
+	body := findFirst(doc, atom.Body)
+	if body == nil {
+		return nil, ErrNoContent
+	}
+
+	removeUnlikely(body)
+	content := mainContent(body)
+	cleanContent(content, article.Title)
+	resolveUrls(content, base)
+
+	if textLength(content) == 0 {
+		return nil, ErrNoContent
+	}
+
+	var sb strings.Builder
+	for child := content.FirstChild; child != nil; child = child.NextSibling {
+		err := html.Render(&sb, child)
+		if err != nil {
+			return nil, fmt.Errorf("render content: %w", err)
+		}
+	}
+	article.Html = sb.String()
+	return article, nil
+}
+
+// pageMeta reads title, author and site name from meta tags and the title element
+func pageMeta(doc *html.Node) *Article {
+	meta := make(map[string]string)
+	walk(doc, func(n *html.Node) {
+		if n.DataAtom != atom.Meta {
+			return
+		}
+		key := strings.ToLower(cmp.Or(attr(n, "property"), attr(n, "name")))
+		if _, ok := meta[key]; !ok && key != "" {
+			meta[key] = strings.TrimSpace(attr(n, "content"))
+		}
+	})
+
+	title := cmp.Or(meta["og:title"], meta["twitter:title"])
+	if title == "" {
+		if node := findFirst(doc, atom.Title); node != nil {
+			title = innerText(node)
+		}
+	}
+
+	// article:author is often a profile url
+	byline := meta["author"]
+	if author := meta["article:author"]; byline == "" && !strings.HasPrefix(author, "http") {
+		byline = author
+	}
+
+	return &Article{
+		Title:    title,
+		Byline:   byline,
+		SiteName: meta["og:site_name"],
+	}
+}
+
+func removeUnlikely(body *html.Node) {
+	var remove []*html.Node
+	walk(body, func(n *html.Node) {
+		if n.Type == html.CommentNode {
+			remove = append(remove, n)
+			return
+		}
+		if n.Type != html.ElementNode || n == body {
+			return
+		}
+		if removedTags[n.DataAtom] || isHidden(n) {
+			remove = append(remove, n)
+			return
+		}
+
+		switch n.DataAtom {
+		case atom.Article, atom.Main, atom.A, atom.Table, atom.Tbody, atom.Tr, atom.Td, atom.Th:
+			return
+		}
+		match := attr(n, "class") + " " + attr(n, "id")
+		if unlikelyPattern.MatchString(match) && !maybePattern.MatchString(match) {
+			remove = append(remove, n)
+		}
+	})
+	for _, n := range remove {
+		// children of removed nodes are already detached with them
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+func isHidden(n *html.Node) bool {
+	if hasAttr(n, "hidden") || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	style := strings.ReplaceAll(strings.ToLower(attr(n, "style")), " ", "")
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+// mainContent scores paragraphs into their ancestors and returns the best ancestor
+// joined with related siblings in a div
+func mainContent(body *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+	candidates := make([]*html.Node, 0)
+	walk(body, func(n *html.Node) {
+		if n.Type != html.ElementNode || !(scoredTags[n.DataAtom] || isParagraphDiv(n)) {
+			return
+		}
+		text := innerText(n)
+		if len(text) < 25 {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text)/100), 3)
+		ancestor := n.Parent
+		for level := 0; level < 5 && ancestor != nil && ancestor.Type == html.ElementNode; level++ {
+			if _, ok := scores[ancestor]; !ok {
+				scores[ancestor] = initialScore(ancestor)
+				candidates = append(candidates, ancestor)
+			}
+			switch level {
+			case 0:
+				scores[ancestor] += score
+			case 1:
+				scores[ancestor] += score / 2
+			default:
+				scores[ancestor] += score / float64(level*3)
+			}
+			ancestor = ancestor.Parent
+		}
+	})
+
+	var top *html.Node
+	for _, candidate := range candidates {
+		scores[candidate] *= 1 - linkDensity(candidate)
+		if top == nil || scores[candidate] > scores[top] {
+			top = candidate
+		}
+	}
+
+	content := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	if top == nil || top == body {
+		moveChildren(body, content)
+		return content
+	}
+
+	topScore := scores[top]
+	threshold := max(10, topScore*0.2)
+	topClass := attr(top, "class")
+	var siblings []*html.Node
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		if sibling == top {
+			siblings = append(siblings, sibling)
+			continue
+		}
+		if sibling.Type != html.ElementNode {
+			continue
+		}
+
+		score, scored := scores[sibling]
+		if scored && topClass != "" && attr(sibling, "class") == topClass {
+			score += topScore * 0.2
+		}
+		if scored && score >= threshold {
+			siblings = append(siblings, sibling)
+			continue
+		}
+
+		if sibling.DataAtom == atom.P {
+			text := innerText(sibling)
+			density := linkDensity(sibling)
+			long := len(text) > 80 && density < 0.25
+			short := len(text) > 0 && len(text) <= 80 && density == 0 && sentenceEnd.MatchString(text)
+			if long || short {
+				siblings = append(siblings, sibling)
+			}
+		}
+	}
+	for _, sibling := range siblings {
+		sibling.Parent.RemoveChild(sibling)
+		content.AppendChild(sibling)
+	}
+	return content
+}
+
+// isParagraphDiv reports divs that have only inline content, they are scored as paragraphs
+func isParagraphDiv(n *html.Node) bool {
+	if n.DataAtom != atom.Div {
+		return false
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && blockTags[child.DataAtom] {
+			return false
+		}
+	}
+	return true
+}
+
+func initialScore(n *html.Node) float64 {
+	score := classWeight(n)
+	switch n.DataAtom {
+	case atom.Div:
+		score += 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score += 3
+	case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+		score -= 3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score -= 5
+	}
+	return score
+}
+
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	for _, value := range []string{attr(n, "class"), attr(n, "id")} {
+		if value == "" {
+			continue
+		}
+		if negativePattern.MatchString(value) {
+			weight -= 25
+		}
+		if positivePattern.MatchString(value) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// cleanContent drops boilerplate left in the content: link lists, negative blocks, empty blocks
+// and the heading that repeats the title
+func cleanContent(content *html.Node, title string) {
+	var remove []*html.Node
+	walk(content, func(n *html.Node) {
+		if n.Type != html.ElementNode || n == content {
+			return
+		}
+
+		switch n.DataAtom {
+		case atom.H1:
+			if strings.EqualFold(innerText(n), strings.TrimSpace(title)) {
+				remove = append(remove, n)
+			}
+		case atom.Div, atom.Section, atom.Ul, atom.Ol, atom.Table:
+			text := innerText(n)
+			images := len(findAll(n, atom.Img))
+			switch {
+			case classWeight(n) < 0:
+				remove = append(remove, n)
+			case len(text) < 200 && images == 0 && linkDensity(n) > 0.5:
+				remove = append(remove, n)
+			case text == "" && images == 0:
+				remove = append(remove, n)
+			}
+		case atom.P:
+			if innerText(n) == "" && len(findAll(n, atom.Img)) == 0 {
+				remove = append(remove, n)
+			}
+		}
+	})
+	for _, n := range remove {
+		if n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+// lazy loaded images keep the real source in data attributes
+var lazySrcAttrs = []string{"data-src", "data-original", "data-lazy-src", "data-url"}
+
+func resolveUrls(content *html.Node, base *url.URL) {
+	resolve := func(ref string) string {
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil {
+			return ""
+		}
+		return u.String()
+	}
+
+	walk(content, func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.A:
+			if href := attr(n, "href"); href != "" && !strings.HasPrefix(href, "#") {
+				setAttr(n, "href", resolve(href))
+			}
+		case atom.Img:
+			src := attr(n, "src")
+			if src == "" || strings.HasPrefix(src, "data:") {
+				for _, key := range lazySrcAttrs {
+					if value := attr(n, key); value != "" {
+						src = value
+						break
+					}
+				}
+			}
+			setAttr(n, "src", resolve(src))
+			removeAttr(n, "srcset")
+		}
+	})
+}
+
+func linkDensity(n *html.Node) float64 {
+	length := len(innerText(n))
+	if length == 0 {
+		return 0
+	}
+	links := 0
+	for _, a := range findAll(n, atom.A) {
+		links += len(innerText(a))
+	}
+	return float64(links) / float64(length)
+}
+
+func textLength(n *html.Node) int {
+	return len(innerText(n)) + len(findAll(n, atom.Img))
+}
+
+// innerText returns text of the node with collapsed whitespace
+func innerText(n *html.Node) string {
+	var sb strings.Builder
+	walk(n, func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			sb.WriteString(" ")
+		}
+	})
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+func walk(n *html.Node, fn func(n *html.Node)) {
+	fn(n)
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child, fn)
+	}
+}
+
+func findFirst(n *html.Node, tag atom.Atom) *html.Node {
+	nodes := findAll(n, tag)
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
+
+func findAll(n *html.Node, tag atom.Atom) []*html.Node {
+	var nodes []*html.Node
+	walk(n, func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == tag {
+			nodes = append(nodes, n)
+		}
+	})
+	return nodes
+}
+
+func moveChildren(from, to *html.Node) {
+	for from.FirstChild != nil {
+		child := from.FirstChild
+		from.RemoveChild(child)
+		to.AppendChild(child)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func setAttr(n *html.Node, key, value string) {
+	for i, a := range n.Attr {
+		if a.Key == key {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: value})
+}
+
+func removeAttr(n *html.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Key != key {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
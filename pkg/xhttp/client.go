@@ -2,15 +2,34 @@ package xhttp
 
 import (
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
+	"syscall"
 	"time"
 )
 
+// ErrNonPublicAddress is returned by public clients for connections to local networks
+var ErrNonPublicAddress = errors.New("address is not public")
+
 func NewClient() *http.Client {
+	return newClient(nil)
+}
+
+// NewPublicClient refuses to connect to loopback, private and link-local addresses,
+// it is used for urls from untrusted sources like links in posts.
+// Addresses are checked when dialing, after dns and on every redirect.
+func NewPublicClient() *http.Client {
+	return newClient(publicOnly)
+}
+
+func newClient(control func(network, address string, conn syscall.RawConn) error) *http.Client {
 	dialer := &net.Dialer{
 		Timeout:   30 * time.Second,
 		KeepAlive: 30 * time.Second,
+		Control:   control,
 	}
 	transport := &http.Transport{
 		DialContext:           dialer.DialContext,
@@ -29,3 +48,22 @@ func NewClient() *http.Client {
 	}
 	return client
 }
+
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("split dialed address '%s': %w", address, err)
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("parse dialed address '%s': %w", address, err)
+	}
+
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		// net adds the address to the error
+		return ErrNonPublicAddress
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package xhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPublicOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{address: "93.184.216.34:443", public: true},
+		{address: "[2606:2800:220:1::1]:443", public: true},
+		{address: "127.0.0.1:80"},
+		{address: "[::1]:80"},
+		{address: "10.1.2.3:80"},
+		{address: "172.16.0.1:80"},
+		{address: "192.168.1.1:80"},
+		{address: "169.254.169.254:80"},
+		{address: "[fe80::1]:80"},
+		{address: "[fd00::1]:80"},
+		{address: "0.0.0.0:80"},
+		{address: "[::ffff:127.0.0.1]:80"},
+	}
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			err := publicOnly("tcp", test.address, nil)
+			if test.public && err != nil {
+				t.Errorf("got error %v for a public address", err)
+			}
+			if !test.public && !errors.Is(err, ErrNonPublicAddress) {
+				t.Errorf("got error %v, want %v", err, ErrNonPublicAddress)
+			}
+		})
+	}
+}
+
+func TestPublicClient(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	_, err := NewPublicClient().Get(srv.URL)
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Errorf("got error %v for a local server, want %v", err, ErrNonPublicAddress)
+	}
+
+	res, err := NewClient().Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get with a regular client: %v", err)
+	}
+	res.Body.Close()
+}
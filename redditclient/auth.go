@@ -1,25 +1,26 @@
 package redditclient
 
 import (
+	"cmp"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/awryme/reddit-exporter/pkg/xhttp"
 	"github.com/awryme/slogf"
 	"github.com/denisbrodbeck/machineid"
 )
 
-const (
-	redditGrantType = "https://oauth.reddit.com/grants/installed_client"
-	redditAuthUrl   = "https://www.reddit.com/api/v1/access_token"
-	redditUserAgent = "reddit-exporter/v1.1"
-)
+const redditGrantType = "https://oauth.reddit.com/grants/installed_client"
+
+// ErrUserNotAuthorized is returned for requests that need a reddit account when only the app is authorized
+var ErrUserNotAuthorized = errors.New("reddit account is not authorized")
 
 const tokenExpBefore = time.Hour
 
@@ -27,11 +28,15 @@ type authResponse struct {
 	AccessToken string `json:"access_token"`
 	TokenType   string `json:"token_type"`
 	ExpiresIn   uint64 `json:"expires_in"`
+	// RefreshToken is returned only for permanent user authorization
+	RefreshToken string `json:"refresh_token"`
 }
 
 type SavedToken struct {
 	Token   string    `json:"token"`
 	Expires time.Time `json:"expires"`
+	// RefreshToken is set if a reddit account is authorized, tokens are refreshed with it instead of app-only auth
+	RefreshToken string `json:"refresh_token,omitempty"`
 }
 
 const machineidAppID = "reddit-exporter"
@@ -45,23 +50,30 @@ type AuthService struct {
 	clientID     string
 	clientSecret string
 	tokenStore   TokenStore
+	// mu serializes token store access, concurrent requests wait for one new token
+	mu sync.Mutex
 
 	logf       slogf.Logf
 	httpClient *http.Client
+	opts       options
 }
 
-func NewAuth(log slog.Handler, clientID string, clientSecret string, tokenStore TokenStore) *AuthService {
-	httpClient := xhttp.NewClient()
+func NewAuth(log slog.Handler, clientID string, clientSecret string, tokenStore TokenStore, opts ...Option) *AuthService {
+	o := newOptions(opts)
 	return &AuthService{
 		clientID:     clientID,
 		clientSecret: clientSecret,
 		tokenStore:   tokenStore,
 		logf:         slogf.New(log),
-		httpClient:   httpClient,
+		httpClient:   o.httpClient,
+		opts:         o,
 	}
 }
 
 func (svc *AuthService) Auth() (string, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
 	token, ok, err := svc.tokenStore.GetToken()
 	if err != nil {
 		return "", fmt.Errorf("retrieve token from store: %w", err)
@@ -69,53 +81,86 @@ func (svc *AuthService) Auth() (string, error) {
 
 	if !ok {
 		svc.logf("authenticating: no token in store")
-		return svc.authAndStoreFile()
+		return svc.authAndStoreFile("")
 	}
 
 	if time.Now().Add(tokenExpBefore).After(token.Expires) {
 		svc.logf("authenticating: token expires soon", slog.Time("expires in", token.Expires))
-		return svc.authAndStoreFile()
+		return svc.authAndStoreFile(token.RefreshToken)
 	}
 	return token.Token, nil
 }
 
 func (svc *AuthService) ForceAuth() (string, error) {
-	return svc.authAndStoreFile()
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	token, _, err := svc.tokenStore.GetToken()
+	if err != nil {
+		return "", fmt.Errorf("retrieve token from store: %w", err)
+	}
+	return svc.authAndStoreFile(token.RefreshToken)
 }
 
-func (svc *AuthService) authAndStoreFile() (string, error) {
-	res, err := svc.httpAuth()
+// UserAuthorized reports if tokens are issued for a reddit account instead of the app only
+func (svc *AuthService) UserAuthorized() (bool, error) {
+	svc.mu.Lock()
+	defer svc.mu.Unlock()
+
+	token, ok, err := svc.tokenStore.GetToken()
+	if err != nil {
+		return false, fmt.Errorf("retrieve token from store: %w", err)
+	}
+	return ok && token.RefreshToken != "", nil
+}
+
+// authAndStoreFile gets a new token, refreshing user authorization if refreshToken is set
+func (svc *AuthService) authAndStoreFile(refreshToken string) (string, error) {
+	form := url.Values{}
+	if refreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refreshToken)
+	} else {
+		deviceID, err := getDeviceID()
+		if err != nil {
+			return "", err
+		}
+		form.Set("grant_type", redditGrantType)
+		form.Set("device_id", deviceID)
+	}
+
+	res, err := svc.httpAuth(form)
 	if err != nil {
 		return "", fmt.Errorf("http auth: %w", err)
 	}
 
+	// refresh responses don't repeat the refresh token
+	return svc.storeToken(res, cmp.Or(res.RefreshToken, refreshToken))
+}
+
+func (svc *AuthService) storeToken(res *authResponse, refreshToken string) (string, error) {
 	savedToken := SavedToken{
-		Token:   res.AccessToken,
-		Expires: time.Now().Add(time.Second * time.Duration(res.ExpiresIn)),
+		Token:        res.AccessToken,
+		Expires:      time.Now().Add(time.Second * time.Duration(res.ExpiresIn)),
+		RefreshToken: refreshToken,
 	}
 
-	err = svc.tokenStore.SaveToken(savedToken)
+	err := svc.tokenStore.SaveToken(savedToken)
 	if err != nil {
 		return "", fmt.Errorf("save token to store: %w", err)
 	}
 	return savedToken.Token, nil
 }
 
-func (svc *AuthService) httpAuth() (*authResponse, error) {
+func (svc *AuthService) httpAuth(form url.Values) (*authResponse, error) {
 	if svc.clientID == "" || svc.clientSecret == "" {
 		return nil, fmt.Errorf("client_id or client_secret is empty")
 	}
-	deviceID, err := getDeviceID()
-	if err != nil {
-		return nil, err
-	}
-	form := url.Values{}
-	form.Set("grant_type", redditGrantType)
-	form.Set("device_id", deviceID)
 
-	authReq, _ := http.NewRequest(http.MethodPost, redditAuthUrl, strings.NewReader(form.Encode()))
+	authReq, _ := http.NewRequest(http.MethodPost, svc.opts.authUrl+"/api/v1/access_token", strings.NewReader(form.Encode()))
 	authReq.SetBasicAuth(svc.clientID, svc.clientSecret)
-	authReq.Header.Set("User-Agent", redditUserAgent)
+	authReq.Header.Set("User-Agent", svc.opts.userAgent)
+	authReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	resp, err := svc.httpClient.Do(authReq)
 	if err != nil {
@@ -129,11 +174,7 @@ func (svc *AuthService) httpAuth() (*authResponse, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("read bad status body: %w", err)
-		}
-		return nil, fmt.Errorf("auth http bad status %d: %w, body: '%s'", resp.StatusCode, err, string(body))
+		return nil, fmt.Errorf("auth http bad status %d, body: '%s'", resp.StatusCode, string(body))
 	}
 
 	var tokenResp authResponse
@@ -7,43 +7,125 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
-	"github.com/awryme/reddit-exporter/pkg/xhttp"
+	"github.com/awryme/slogf"
 )
 
-// reddit domains
-const (
-	domainReddit       = "reddit.com"
-	domainRedditWWW    = "www.reddit.com"
-	domainRedditOauth  = "oauth.reddit.com"
-	domainRedditImages = "i.redd.it"
-)
+// permalinks of posts and wikis point to reddit even if requests go to a stand-in
+const domainRedditWWW = "www.reddit.com"
+
+// redditDomains and their subdomains serve reddit media, images from other hosts are downloaded by the external client
+var redditDomains = []string{"reddit.com", "redd.it", "redditmedia.com", "redditstatic.com"}
 
 type (
 	Post = struct {
-		Title string
-		Html  string
+		ID        string
+		Title     string
+		Author    string
+		Subreddit string
+		Url       string
+		Created   time.Time
+		// Edited is zero if post was never edited
+		Edited time.Time
+		Score  int
+		Flair  string
+		Html   string
+		// Markdown is the source text of self posts
+		Markdown string
+		// Gallery has ordered images of gallery posts
+		Gallery []ImageInfo
+		// Video is set for posts with a reddit hosted video, crossposts use video of the original post
+		Video *VideoInfo
+		// LinkUrl is the external page of link posts, empty for self posts and reddit media
+		LinkUrl string
+	}
+
+	VideoInfo = struct {
+		Name string
+		// Url of the video without audio
+		Url string
+		// DashUrl is the dash playlist with the audio track
+		DashUrl  string
+		Width    int
+		Height   int
+		Duration time.Duration
+		// IsGif is set for videos converted from gifs, they have no audio
+		IsGif bool
 	}
 
 	ImageInfo = struct {
 		Name string
-		Url  string
+		// Url of the original image
+		Url string
+		// Caption is set for gallery images
+		Caption string
+		Width   int
+		Height  int
+		// Previews are smaller versions of the image, ordered by width
+		Previews []ImageVariant
+	}
+
+	ImageVariant = struct {
+		Url    string
+		Width  int
+		Height int
+	}
+
+	WikiPage = struct {
+		Subreddit string
+		// Name is the page path inside the wiki: index, stories/part_1
+		Name     string
+		Url      string
+		Html     string
+		Markdown string
+		// Revised is the time of the latest revision
+		Revised time.Time
+		// RevisedBy is the author of the latest revision
+		RevisedBy string
 	}
 
 	Comment = struct {
+		ID        string
+		Author    string
+		Subreddit string
+		// Title is the title of the commented post
+		Title   string
+		Url     string
+		Created time.Time
+		Score   int
+		// Images are ordered as they appear in the comment
 		Images []ImageInfo
 	}
 )
 
 type Client struct {
-	httpClient *http.Client
-	auth       *AuthService
+	httpClient     *http.Client
+	externalClient *http.Client
+	auth           *AuthService
+	limiter        *rateLimiter
+	logf           slogf.Logf
+	opts           options
 }
 
-func New(log slog.Handler, clientID string, clientSecret string, tokenStore TokenStore) *Client {
-	auth := NewAuth(log, clientID, clientSecret, tokenStore)
-	httpClient := xhttp.NewClient()
-	return &Client{httpClient, auth}
+func New(log slog.Handler, clientID string, clientSecret string, tokenStore TokenStore, opts ...Option) *Client {
+	auth := NewAuth(log, clientID, clientSecret, tokenStore, opts...)
+	o := newOptions(opts)
+	return &Client{
+		httpClient:     o.httpClient,
+		externalClient: o.externalClient,
+		auth:           auth,
+		limiter:        newRateLimiter(o.rateLimitReserve),
+		logf:           slogf.New(log),
+		opts:           o,
+	}
+}
+
+// Auth returns the token service of the client, user authorization through it shares the client token refreshes
+func (cli *Client) Auth() *AuthService {
+	return cli.auth
 }
 
 func (cli *Client) GetPostByID(ctx context.Context, subreddit, id string) (*Post, error) {
@@ -51,10 +133,46 @@ func (cli *Client) GetPostByID(ctx context.Context, subreddit, id string) (*Post
 	if err != nil {
 		return nil, fmt.Errorf("get json post: %w", err)
 	}
+	return cli.newPost(data), nil
+}
+
+func (cli *Client) newPost(data JsonPostData) *Post {
+	var edited time.Time
+	if data.Edited > 0 {
+		edited = time.Unix(int64(data.Edited), 0).UTC()
+	}
+
 	return &Post{
-		Title: data.Title,
-		Html:  html.UnescapeString(data.Selfhtml),
-	}, nil
+		ID:        data.Id,
+		Title:     data.Title,
+		Author:    data.Author,
+		Subreddit: data.Subreddit,
+		Url:       fmt.Sprintf("https://%s%s", domainRedditWWW, data.Permalink),
+		Created:   time.Unix(int64(data.CreatedUtc), 0).UTC(),
+		Edited:    edited,
+		Score:     data.Score,
+		Flair:     data.Flair,
+		Html:      html.UnescapeString(data.Selfhtml),
+		Markdown:  html.UnescapeString(data.Selftext),
+		Gallery:   galleryImages(data, cli.opts.imagesUrl),
+		Video:     postVideo(data),
+		LinkUrl:   linkUrl(data),
+	}
+}
+
+func linkUrl(data JsonPostData) string {
+	if len(data.CrosspostParentList) > 0 {
+		return linkUrl(data.CrosspostParentList[0])
+	}
+	if data.IsSelf || data.IsGallery || postVideo(data) != nil {
+		return ""
+	}
+	// relative urls point back to reddit
+	link := html.UnescapeString(data.Url)
+	if !strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://") {
+		return ""
+	}
+	return link
 }
 
 func (cli *Client) GetCommentByID(ctx context.Context, subreddit, id string) (*Comment, error) {
@@ -65,43 +183,76 @@ func (cli *Client) GetCommentByID(ctx context.Context, subreddit, id string) (*C
 
 	meta := data.MediaMetadata
 	infos := make([]ImageInfo, 0, len(meta))
-	for id, info := range meta {
-		var name string
-		switch info.Type {
-		case "image/jpeg":
-			name = fmt.Sprintf("%s.jpeg", id)
+	for _, id := range mediaOrder(data.Body, meta) {
+		info, ok := mediaImage(id, meta[id], cli.opts.imagesUrl)
+		if ok {
+			infos = append(infos, info)
 		}
-		url := fmt.Sprintf("https://%s/%s", domainRedditImages, name)
-		infos = append(infos, ImageInfo{
-			Name: name,
-			Url:  url,
-		})
 	}
 
 	return &Comment{
-		Images: infos,
+		ID:        data.Id,
+		Author:    data.Author,
+		Subreddit: data.Subreddit,
+		Title:     html.UnescapeString(data.LinkTitle),
+		Url:       fmt.Sprintf("https://%s%s", domainRedditWWW, data.Permalink),
+		Created:   time.Unix(int64(data.CreatedUtc), 0).UTC(),
+		Score:     data.Score,
+		Images:    infos,
 	}, nil
 }
 
+// DownloadImage writes an image, images outside reddit hosts are downloaded by the external client
 func (cli *Client) DownloadImage(ctx context.Context, info ImageInfo, buf io.Writer) error {
-	client := xhttp.NewClient()
+	client := cli.externalClient
+	if cli.isRedditUrl(info.Url) {
+		client = cli.httpClient
+	}
+	err := cli.download(ctx, client, info.Url, "image/*", buf)
+	if err != nil {
+		return fmt.Errorf("download reddit image: %w", err)
+	}
+	return nil
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, info.Url, nil)
+// isRedditUrl reports if url points to reddit or to one of the configured base urls
+func (cli *Client) isRedditUrl(rawUrl string) bool {
+	u, err := url.Parse(rawUrl)
 	if err != nil {
-		return fmt.Errorf("create http request for reddit image: %w", err)
+		return false
 	}
+	host := strings.ToLower(u.Hostname())
+	for _, base := range []string{cli.opts.apiUrl, cli.opts.authUrl, cli.opts.imagesUrl} {
+		baseUrl, err := url.Parse(base)
+		if err == nil && strings.EqualFold(u.Host, baseUrl.Host) {
+			return true
+		}
+	}
+	for _, domain := range redditDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
 
-	req.Header.Set("User-Agent", "reddit-exporter/v1.2")
-	req.Header.Set("Accept", "image/*")
+func (cli *Client) download(ctx context.Context, client *http.Client, url, accept string, buf io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("create http request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", cli.opts.userAgent)
+	req.Header.Set("Accept", accept)
 
-	res, err := client.Do(req)
+	res, err := cli.send(ctx, client, req, false)
 	if err != nil {
-		return fmt.Errorf("send http request for reddit image: %w", err)
+		return fmt.Errorf("send http request: %w", err)
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("send http request for reddit image: bad status %d (%s)", res.StatusCode, res.Status)
+		return fmt.Errorf("send http request: bad status %d (%s)", res.StatusCode, res.Status)
 	}
 
 	_, err = io.Copy(buf, res.Body)
@@ -116,13 +267,13 @@ func getListings[Data any](ctx context.Context, cli *Client, subreddit string, k
 	var data Data
 	fullID := fmt.Sprintf("%s_%s", kind, id)
 
-	token, err := cli.auth.Auth()
-	if err != nil {
-		return data, fmt.Errorf("auth new token: %w", err)
+	// gallery links have no subreddit, info of any post can be requested without it
+	path := "/api/info"
+	if subreddit != "" {
+		path = fmt.Sprintf("/r/%s/api/info", subreddit)
 	}
-
-	url := fmt.Sprintf("https://%s/r/%s/api/info?id=%s", domainRedditOauth, subreddit, fullID)
-	listing, err := jsonGetPost[Data](ctx, cli.httpClient, url, token)
+	url := fmt.Sprintf("%s%s?id=%s", cli.opts.apiUrl, path, fullID)
+	listing, err := jsonGetPost[Data](ctx, cli, url)
 	if err != nil {
 		return data, fmt.Errorf("get json post: %w", err)
 	}
@@ -0,0 +1,54 @@
+package redditclient_test
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/awryme/reddit-exporter/redditclient"
+	"github.com/awryme/reddit-exporter/redditclient/reddittest"
+)
+
+// newClient is a client of srv that retries without waiting long
+func newClient(srv *reddittest.Server, opts ...redditclient.Option) *redditclient.Client {
+	opts = append(srv.Options(), append([]redditclient.Option{
+		redditclient.WithRetryDelay(time.Millisecond, 10*time.Millisecond),
+	}, opts...)...)
+	return redditclient.New(slog.DiscardHandler, "client", "secret", redditclient.NewMemoryTokenStore(), opts...)
+}
+
+func newServer(t *testing.T) *reddittest.Server {
+	srv := reddittest.NewServer()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGetPostByID(t *testing.T) {
+	srv := newServer(t)
+	srv.AddPost(redditclient.JsonPostData{
+		Id:        "abc",
+		Title:     "Title",
+		Author:    "author",
+		Subreddit: "test",
+		Selftext:  "text",
+		Selfhtml:  "&lt;p&gt;text&lt;/p&gt;",
+		IsSelf:    true,
+	})
+	cli := newClient(srv)
+
+	post, err := cli.GetPostByID(t.Context(), "test", "abc")
+	if err != nil {
+		t.Fatalf("get post: %v", err)
+	}
+	if post.ID != "abc" || post.Title != "Title" || post.Author != "author" || post.Subreddit != "test" {
+		t.Errorf("got post %+v", post)
+	}
+	if post.Html != "<p>text</p>" {
+		t.Errorf("got html %q, want unescaped html", post.Html)
+	}
+
+	_, err = cli.GetPostByID(t.Context(), "test", "missing")
+	if err == nil {
+		t.Errorf("got no error for a missing post")
+	}
+}
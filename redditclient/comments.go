@@ -0,0 +1,219 @@
+package redditclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+)
+
+// reddit accepts at most 100 ids per /api/morechildren call
+const moreChildrenBatch = 100
+
+// stop expanding "more" stubs after this many calls, huge threads would take forever
+const maxMoreChildrenCalls = 50
+
+type (
+	// PostComment is a single comment of a flattened comment tree.
+	// Comments are ordered depth-first, Depth is 0 for top level comments.
+	PostComment = struct {
+		ID       string
+		Author   string
+		Html     string
+		Markdown string
+		Score    int
+		Depth    int
+	}
+
+	CommentOptions = struct {
+		// Depth limits reply depth, 0 is unlimited
+		Depth int
+		// MinScore drops comments (with their replies) scored lower, 0 disables the filter
+		MinScore int
+		// Limit is the max number of returned comments, 0 is unlimited
+		Limit int
+	}
+)
+
+type commentNode struct {
+	data    JsonCommentData
+	replies []*commentNode
+}
+
+type commentTree struct {
+	root  []*commentNode
+	nodes map[string]*commentNode
+	more  []JsonMoreData
+}
+
+func (cli *Client) GetPostComments(ctx context.Context, subreddit, postID string, opts CommentOptions) ([]PostComment, error) {
+	query := url.Values{}
+	if opts.Depth > 0 {
+		query.Set("depth", fmt.Sprint(opts.Depth))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", fmt.Sprint(opts.Limit))
+	}
+
+	// response is [post listing, comments listing]
+	var listings []JsonListing[json.RawMessage]
+	commentsUrl := fmt.Sprintf("%s/r/%s/comments/%s?%s", cli.opts.apiUrl, subreddit, postID, query.Encode())
+	err := jsonGet(ctx, cli, commentsUrl, &listings)
+	if err != nil {
+		return nil, fmt.Errorf("get json comments: %w", err)
+	}
+	if len(listings) != 2 {
+		return nil, fmt.Errorf("comments response has %d listings, expected 2", len(listings))
+	}
+
+	tree := &commentTree{nodes: make(map[string]*commentNode)}
+	fullPostID := fmt.Sprintf("%s_%s", KindPost, postID)
+	err = tree.addChildren(fullPostID, listings[1].Data.Children)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cli.expandMore(ctx, tree, fullPostID, opts.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return tree.flatten(opts), nil
+}
+
+// expandMore resolves "more" stubs with /api/morechildren until there is none left or limit is reached
+func (cli *Client) expandMore(ctx context.Context, tree *commentTree, linkID string, limit int) error {
+	for calls := 0; len(tree.more) > 0 && calls < maxMoreChildrenCalls; calls++ {
+		if limit > 0 && len(tree.nodes) >= limit {
+			return nil
+		}
+
+		ids := make([]string, 0, moreChildrenBatch)
+		for len(tree.more) > 0 && len(ids) < moreChildrenBatch {
+			more := &tree.more[0]
+			n := min(moreChildrenBatch-len(ids), len(more.Children))
+			ids = append(ids, more.Children[:n]...)
+			more.Children = more.Children[n:]
+			if len(more.Children) == 0 {
+				tree.more = tree.more[1:]
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		query := url.Values{}
+		query.Set("api_type", "json")
+		query.Set("link_id", linkID)
+		query.Set("children", strings.Join(ids, ","))
+		query.Set("limit_children", "false")
+
+		var resp JsonMoreChildren
+		moreUrl := fmt.Sprintf("%s/api/morechildren?%s", cli.opts.apiUrl, query.Encode())
+		err := jsonGet(ctx, cli, moreUrl, &resp)
+		if err != nil {
+			return fmt.Errorf("get json more children: %w", err)
+		}
+		if len(resp.Json.Errors) > 0 {
+			return fmt.Errorf("get json more children: api errors %v", resp.Json.Errors)
+		}
+
+		// things are flat, every comment is attached to its parent_id
+		for _, thing := range resp.Json.Data.Things {
+			err := tree.addThing("", thing)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (tree *commentTree) addChildren(parentID string, children []JsonPost[json.RawMessage]) error {
+	for _, child := range children {
+		err := tree.addThing(parentID, child)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addThing adds a comment or a "more" stub to the tree, empty parentID means use parent_id of the thing
+func (tree *commentTree) addThing(parentID string, thing JsonPost[json.RawMessage]) error {
+	switch thing.Kind {
+	case KindComment:
+		var data JsonCommentData
+		if err := json.Unmarshal(thing.Data, &data); err != nil {
+			return fmt.Errorf("decode json comment: %w", err)
+		}
+		if parentID == "" {
+			parentID = data.ParentID
+		}
+
+		node := &commentNode{data: data}
+		tree.nodes[data.Name] = node
+		if parent, ok := tree.nodes[parentID]; ok {
+			parent.replies = append(parent.replies, node)
+		} else {
+			tree.root = append(tree.root, node)
+		}
+
+		// replies are an empty string when there are none
+		replies := bytes.TrimSpace(data.Replies)
+		if len(replies) == 0 || replies[0] != '{' {
+			return nil
+		}
+		var listing JsonListing[json.RawMessage]
+		if err := json.Unmarshal(replies, &listing); err != nil {
+			return fmt.Errorf("decode json comment replies: %w", err)
+		}
+		return tree.addChildren(data.Name, listing.Data.Children)
+	case KindMore:
+		var data JsonMoreData
+		if err := json.Unmarshal(thing.Data, &data); err != nil {
+			return fmt.Errorf("decode json more: %w", err)
+		}
+		// "continue this thread" stubs have no children
+		if len(data.Children) > 0 {
+			tree.more = append(tree.more, data)
+		}
+	}
+	return nil
+}
+
+func (tree *commentTree) flatten(opts CommentOptions) []PostComment {
+	comments := make([]PostComment, 0, len(tree.nodes))
+
+	var walk func(nodes []*commentNode, depth int)
+	walk = func(nodes []*commentNode, depth int) {
+		if opts.Depth > 0 && depth >= opts.Depth {
+			return
+		}
+		for _, node := range nodes {
+			if opts.Limit > 0 && len(comments) >= opts.Limit {
+				return
+			}
+			if opts.MinScore != 0 && node.data.Score < opts.MinScore {
+				continue
+			}
+
+			comments = append(comments, PostComment{
+				ID:       node.data.Id,
+				Author:   node.data.Author,
+				Html:     html.UnescapeString(node.data.BodyHtml),
+				Markdown: html.UnescapeString(node.data.Body),
+				Score:    node.data.Score,
+				Depth:    depth,
+			})
+			walk(node.replies, depth+1)
+		}
+	}
+	walk(tree.root, 0)
+
+	return comments
+}
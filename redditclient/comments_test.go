@@ -0,0 +1,103 @@
+package redditclient_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/awryme/reddit-exporter/redditclient"
+	"github.com/awryme/reddit-exporter/redditclient/reddittest"
+)
+
+// addThread adds comments to post p1 of r/test, as id/parent/score: c1 has replies r1 (with rr1), r2, r3
+func addThread(srv *reddittest.Server) {
+	srv.AddPost(redditclient.JsonPostData{Id: "p1", Title: "Post", Subreddit: "test"})
+	comments := []struct {
+		id     string
+		parent string
+		score  int
+	}{
+		{"c1", "", 10},
+		{"r1", "t1_c1", -5},
+		{"rr1", "t1_r1", 1},
+		{"r2", "t1_c1", 1},
+		{"r3", "t1_c1", 1},
+		{"c2", "", 1},
+		{"c3", "", 1},
+		{"c4", "", 1},
+	}
+	for _, comment := range comments {
+		srv.AddComment("p1", redditclient.JsonCommentData{
+			Id:        comment.id,
+			ParentID:  comment.parent,
+			Subreddit: "test",
+			Author:    "author",
+			Body:      comment.id,
+			BodyHtml:  comment.id,
+			Score:     comment.score,
+		})
+	}
+}
+
+func TestGetPostComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		collapse int
+		opts     redditclient.CommentOptions
+		// want are comments as id/depth in order
+		want []string
+	}{
+		{
+			name: "all comments",
+			want: []string{"c1/0", "r1/1", "rr1/2", "r2/1", "r3/1", "c2/0", "c3/0", "c4/0"},
+		},
+		{
+			name:     "more stubs are expanded in place",
+			collapse: 2,
+			want:     []string{"c1/0", "r1/1", "rr1/2", "r2/1", "r3/1", "c2/0", "c3/0", "c4/0"},
+		},
+		{
+			name:     "every reply behind more stubs",
+			collapse: 1,
+			want:     []string{"c1/0", "r1/1", "rr1/2", "r2/1", "r3/1", "c2/0", "c3/0", "c4/0"},
+		},
+		{
+			name:     "limit",
+			collapse: 1,
+			opts:     redditclient.CommentOptions{Limit: 3},
+			want:     []string{"c1/0", "r1/1", "rr1/2"},
+		},
+		{
+			name:     "depth",
+			collapse: 2,
+			opts:     redditclient.CommentOptions{Depth: 1},
+			want:     []string{"c1/0", "c2/0", "c3/0", "c4/0"},
+		},
+		{
+			name:     "min score drops replies of low scored comments",
+			collapse: 2,
+			opts:     redditclient.CommentOptions{MinScore: 1},
+			want:     []string{"c1/0", "r2/1", "r3/1", "c2/0", "c3/0", "c4/0"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newServer(t)
+			addThread(srv)
+			srv.CollapseComments(test.collapse)
+
+			comments, err := newClient(srv).GetPostComments(t.Context(), "test", "p1", test.opts)
+			if err != nil {
+				t.Fatalf("get comments: %v", err)
+			}
+
+			got := make([]string, 0, len(comments))
+			for _, comment := range comments {
+				got = append(got, fmt.Sprintf("%s/%d", comment.ID, comment.Depth))
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got comments %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -14,19 +14,142 @@ const (
 	KindListing JsonKind = "Listing"
 	KindComment JsonKind = "t1"
 	KindPost    JsonKind = "t3"
+	KindMore    JsonKind = "more"
+	KindUser    JsonKind = "t2"
+	KindWiki    JsonKind = "wikipage"
 )
 
 type JsonPostData struct {
-	Title    string
-	Selftext string
-	Selfhtml string `json:"selftext_html"`
-	Id       string
+	Title      string
+	Selftext   string
+	Selfhtml   string `json:"selftext_html"`
+	Id         string
+	Author     string
+	Subreddit  string
+	CreatedUtc float64 `json:"created_utc"`
+	Permalink  string
+	Score      int
+	Flair      string `json:"link_flair_text"`
+	Edited     JsonEdited
+	// Url is the linked page of link posts and the permalink of self posts
+	Url    string
+	IsSelf bool `json:"is_self"`
+
+	IsGallery     bool                 `json:"is_gallery"`
+	GalleryData   *JsonGalleryData     `json:"gallery_data"`
+	MediaMetadata map[string]JsonMedia `json:"media_metadata"`
+
+	Media       *JsonPostMedia
+	SecureMedia *JsonPostMedia `json:"secure_media"`
+	// CrosspostParentList has the original post of a crosspost
+	CrosspostParentList []JsonPostData `json:"crosspost_parent_list"`
+}
+
+// JsonPostMedia is embedded media of a post, only reddit hosted videos are used
+type JsonPostMedia struct {
+	RedditVideo *JsonRedditVideo `json:"reddit_video"`
+}
+
+// JsonRedditVideo is a v.redd.it video, fallback url is an mp4 without audio
+type JsonRedditVideo struct {
+	FallbackUrl string `json:"fallback_url"`
+	DashUrl     string `json:"dash_url"`
+	HlsUrl      string `json:"hls_url"`
+	Width       int
+	Height      int
+	// Duration is in seconds
+	Duration int
+	IsGif    bool `json:"is_gif"`
+}
+
+// JsonEdited is false for posts that were never edited and an edit timestamp otherwise
+type JsonEdited float64
+
+func (edited *JsonEdited) UnmarshalJSON(data []byte) error {
+	var timestamp float64
+	if err := json.Unmarshal(data, &timestamp); err != nil {
+		// a bool, edit time is unknown
+		*edited = 0
+		return nil
+	}
+	*edited = JsonEdited(timestamp)
+	return nil
 }
 
 type JsonCommentData struct {
-	MediaMetadata map[string]struct {
-		Type string `json:"m"`
-	} `json:"media_metadata"`
+	Id         string
+	Name       string
+	ParentID   string `json:"parent_id"`
+	Author     string
+	Subreddit  string
+	Permalink  string
+	LinkTitle  string  `json:"link_title"`
+	CreatedUtc float64 `json:"created_utc"`
+	Body       string
+	BodyHtml   string `json:"body_html"`
+	Score      int
+	// Replies is either an empty string or a listing of child comments
+	Replies json.RawMessage
+
+	MediaMetadata map[string]JsonMedia `json:"media_metadata"`
+}
+
+// JsonMedia is a media_metadata entry of images in galleries and comments
+type JsonMedia struct {
+	// Status is "valid" for processed media, "unprocessed" or "failed" otherwise
+	Status string
+	// Kind is Image, AnimatedImage or RedditVideo
+	Kind string `json:"e"`
+	// Type is the mime type of the original
+	Type     string            `json:"m"`
+	Source   JsonMediaSource   `json:"s"`
+	Previews []JsonMediaSource `json:"p"`
+}
+
+// JsonMediaSource is one resolution of a media, animated images have gif and mp4 urls instead of u
+type JsonMediaSource struct {
+	X   int
+	Y   int
+	U   string
+	Gif string
+	Mp4 string
+}
+
+// JsonGalleryData orders media of gallery posts
+type JsonGalleryData struct {
+	Items []struct {
+		MediaID string `json:"media_id"`
+		Caption string
+	}
+}
+
+type JsonUserData struct {
+	Name string
+}
+
+type JsonWikiPageData struct {
+	ContentMd   string `json:"content_md"`
+	ContentHtml string `json:"content_html"`
+	// RevisionDate is the unix time of the latest revision
+	RevisionDate float64 `json:"revision_date"`
+	// RevisionBy is the author of the latest revision, empty if unknown
+	RevisionBy *JsonPost[JsonUserData] `json:"revision_by"`
+}
+
+type JsonMoreData struct {
+	Count    int
+	Id       string
+	ParentID string `json:"parent_id"`
+	Children []string
+}
+
+type JsonMoreChildren struct {
+	Json struct {
+		Errors [][]string
+		Data   struct {
+			Things []JsonPost[json.RawMessage]
+		}
+	}
 }
 
 type JsonPost[Data any] struct {
@@ -37,36 +160,46 @@ type JsonPost[Data any] struct {
 type JsonListing[Data any] struct {
 	Kind JsonKind
 	Data struct {
+		After    string
+		Before   string
 		Children []JsonPost[Data]
 	}
 }
 
-func jsonGetPost[Data any](ctx context.Context, httpClient *http.Client, url, token string) (*JsonListing[Data], error) {
+func jsonGetPost[Data any](ctx context.Context, cli *Client, url string) (*JsonListing[Data], error) {
+	var listing JsonListing[Data]
+	err := jsonGet(ctx, cli, url, &listing)
+	if err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+// jsonGet gets an authorized api url, retrying transient failures
+func jsonGet(ctx context.Context, cli *Client, url string, value any) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("create http request: %w", err)
+		return fmt.Errorf("create http request: %w", err)
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	req.Header.Set("User-Agent", "reddit-exporter/v1.2")
+	req.Header.Set("User-Agent", cli.opts.userAgent)
 
-	res, err := httpClient.Do(req)
+	res, err := cli.send(ctx, cli.httpClient, req, true)
 	if err != nil {
-		return nil, fmt.Errorf("get response for url '%s': %w", url, err)
+		return fmt.Errorf("get response for url '%s': %w", url, err)
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("bad status code for url '%s': %d (%s)", url, res.StatusCode, res.Status)
+		return fmt.Errorf("bad status code for url '%s': %d (%s)", url, res.StatusCode, res.Status)
 	}
 
-	return jsonDecode[Data](res.Body)
+	return jsonDecode(res.Body, value)
 }
 
-func jsonDecode[Data any](data io.Reader) (*JsonListing[Data], error) {
-	var resp JsonListing[Data]
-	err := json.NewDecoder(data).Decode(&resp)
+func jsonDecode(data io.Reader, value any) error {
+	err := json.NewDecoder(data).Decode(value)
 	if err != nil {
-		return nil, fmt.Errorf("decode response body from json: %w", err)
+		return fmt.Errorf("decode response body from json: %w", err)
 	}
-	return &resp, nil
+	return nil
 }
@@ -0,0 +1,147 @@
+package redditclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// reddit returns at most 100 items per listing page
+const listingPageSize = 100
+
+// GetUserPosts returns up to limit newest submissions of a user
+func (cli *Client) GetUserPosts(ctx context.Context, username string, limit int) ([]Post, error) {
+	query := url.Values{}
+	query.Set("sort", "new")
+
+	path := fmt.Sprintf("/user/%s/submitted", url.PathEscape(username))
+	posts, err := cli.listPosts(ctx, path, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list submissions of u/%s: %w", username, err)
+	}
+	return posts, nil
+}
+
+// GetSubredditPosts returns up to limit posts of a subreddit listing,
+// sort is hot, new, top, rising or controversial, period (hour, day, week, month, year, all) applies to top and controversial
+func (cli *Client) GetSubredditPosts(ctx context.Context, subreddit, sort, period string, limit int) ([]Post, error) {
+	query := url.Values{}
+	if period != "" {
+		query.Set("t", period)
+	}
+
+	path := fmt.Sprintf("/r/%s/%s", url.PathEscape(subreddit), url.PathEscape(sort))
+	posts, err := cli.listPosts(ctx, path, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list %s posts of r/%s: %w", sort, subreddit, err)
+	}
+	return posts, nil
+}
+
+// SearchPosts returns up to limit posts found by search query, in subreddit if it is not empty,
+// sort is relevance, hot, top, new or comments
+func (cli *Client) SearchPosts(ctx context.Context, subreddit, search, sort, period string, limit int) ([]Post, error) {
+	query := url.Values{}
+	query.Set("q", search)
+	query.Set("type", "link")
+	if sort != "" {
+		query.Set("sort", sort)
+	}
+	if period != "" {
+		query.Set("t", period)
+	}
+
+	path := "/search"
+	if subreddit != "" {
+		path = fmt.Sprintf("/r/%s/search", url.PathEscape(subreddit))
+		query.Set("restrict_sr", "1")
+	}
+	posts, err := cli.listPosts(ctx, path, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search posts '%s': %w", search, err)
+	}
+	return posts, nil
+}
+
+// GetSavedPosts returns up to limit posts saved by a user, latest saved first,
+// username "me" is the authorized account, saved comments are skipped
+func (cli *Client) GetSavedPosts(ctx context.Context, username string, limit int) ([]Post, error) {
+	return cli.listAccountPosts(ctx, username, "saved", limit)
+}
+
+// GetUpvotedPosts returns up to limit posts upvoted by a user, latest upvoted first,
+// username "me" is the authorized account
+func (cli *Client) GetUpvotedPosts(ctx context.Context, username string, limit int) ([]Post, error) {
+	return cli.listAccountPosts(ctx, username, "upvoted", limit)
+}
+
+// listAccountPosts lists a private tab of a user profile, reddit shows them only to the authorized account
+func (cli *Client) listAccountPosts(ctx context.Context, username, tab string, limit int) ([]Post, error) {
+	authorized, err := cli.auth.UserAuthorized()
+	if err != nil {
+		return nil, err
+	}
+	if !authorized {
+		return nil, fmt.Errorf("list %s posts: %w", tab, ErrUserNotAuthorized)
+	}
+
+	if username == "me" {
+		username, err = cli.authorizedUsername(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	path := fmt.Sprintf("/user/%s/%s", url.PathEscape(username), tab)
+	posts, err := cli.listPosts(ctx, path, url.Values{}, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list %s posts of u/%s: %w", tab, username, err)
+	}
+	return posts, nil
+}
+
+// authorizedUsername returns the name of the authorized reddit account
+func (cli *Client) authorizedUsername(ctx context.Context) (string, error) {
+	var me JsonUserData
+	err := jsonGet(ctx, cli, cli.opts.apiUrl+"/api/v1/me", &me)
+	if err != nil {
+		return "", fmt.Errorf("get authorized account: %w", err)
+	}
+	if me.Name == "" {
+		return "", fmt.Errorf("get authorized account: %w", ErrUserNotAuthorized)
+	}
+	return me.Name, nil
+}
+
+// listPosts pages through a posts listing with after cursors until limit posts are read or listing ends
+func (cli *Client) listPosts(ctx context.Context, path string, query url.Values, limit int) ([]Post, error) {
+	posts := make([]Post, 0, limit)
+	after := ""
+	for len(posts) < limit {
+		query.Set("limit", fmt.Sprint(min(listingPageSize, limit-len(posts))))
+		query.Set("after", after)
+
+		listUrl := fmt.Sprintf("%s%s?%s", cli.opts.apiUrl, path, query.Encode())
+		listing, err := jsonGetPost[JsonPostData](ctx, cli, listUrl)
+		if err != nil {
+			return nil, fmt.Errorf("get json listing: %w", err)
+		}
+		if listing.Kind != KindListing {
+			return nil, fmt.Errorf("listing kind is wrong (expected = %s, got = %s)", KindListing, listing.Kind)
+		}
+
+		for _, post := range listing.Data.Children {
+			if post.Kind != KindPost {
+				continue
+			}
+			posts = append(posts, *cli.newPost(post.Data))
+		}
+
+		after = listing.Data.After
+		if after == "" || len(listing.Data.Children) == 0 {
+			break
+		}
+	}
+
+	return posts, nil
+}
@@ -0,0 +1,128 @@
+package redditclient
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+const mediaStatusValid = "valid"
+
+// file extensions of media_metadata image types
+var mediaExtensions = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/jpg":  "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// mediaImage returns a media_metadata image with its previews,
+// false for media that is not processed or has no downloadable source (videos)
+func mediaImage(id string, media JsonMedia, imagesUrl string) (ImageInfo, bool) {
+	// old media entries have no status
+	if media.Status != "" && media.Status != mediaStatusValid {
+		return ImageInfo{}, false
+	}
+
+	source, ext := mediaSource(id, media, imagesUrl)
+	if source == "" {
+		return ImageInfo{}, false
+	}
+
+	info := ImageInfo{
+		Name:   fmt.Sprintf("%s.%s", id, ext),
+		Url:    source,
+		Width:  media.Source.X,
+		Height: media.Source.Y,
+	}
+	// previews of animated images are still frames
+	if media.Source.Gif != "" || media.Source.Mp4 != "" {
+		return info, true
+	}
+	for _, preview := range media.Previews {
+		if preview.U == "" {
+			continue
+		}
+		info.Previews = append(info.Previews, ImageVariant{
+			Url:    html.UnescapeString(preview.U),
+			Width:  preview.X,
+			Height: preview.Y,
+		})
+	}
+	slices.SortFunc(info.Previews, func(a, b ImageVariant) int {
+		return cmp.Compare(a.Width, b.Width)
+	})
+	return info, true
+}
+
+// mediaSource returns the url and file extension of the original media, imagesUrl serves originals by id
+func mediaSource(id string, media JsonMedia, imagesUrl string) (string, string) {
+	switch {
+	case media.Source.Gif != "":
+		return html.UnescapeString(media.Source.Gif), "gif"
+	case media.Source.Mp4 != "":
+		return html.UnescapeString(media.Source.Mp4), "mp4"
+	}
+
+	// originals of images are served by id, s.u is a full size preview that can be recompressed
+	if ext, ok := mediaExtensions[media.Type]; ok {
+		return fmt.Sprintf("%s/%s.%s", imagesUrl, id, ext), ext
+	}
+
+	if media.Source.U == "" {
+		return "", ""
+	}
+	source := html.UnescapeString(media.Source.U)
+	ext := "jpeg"
+	if parsed, err := url.Parse(source); err == nil && path.Ext(parsed.Path) != "" {
+		ext = strings.TrimPrefix(path.Ext(parsed.Path), ".")
+	}
+	return source, ext
+}
+
+// galleryImages returns images of a gallery post in gallery order with their captions
+func galleryImages(data JsonPostData, imagesUrl string) []ImageInfo {
+	if !data.IsGallery || data.GalleryData == nil {
+		return nil
+	}
+
+	images := make([]ImageInfo, 0, len(data.GalleryData.Items))
+	for _, item := range data.GalleryData.Items {
+		media, ok := data.MediaMetadata[item.MediaID]
+		if !ok {
+			continue
+		}
+		info, ok := mediaImage(item.MediaID, media, imagesUrl)
+		if !ok {
+			continue
+		}
+		info.Caption = html.UnescapeString(item.Caption)
+		images = append(images, info)
+	}
+	return images
+}
+
+// mediaOrder returns media ids ordered by their first mention in text, unmentioned ids go last sorted by id
+func mediaOrder[Media any](text string, media map[string]Media) []string {
+	ids := make([]string, 0, len(media))
+	for id := range media {
+		ids = append(ids, id)
+	}
+
+	position := func(id string) int {
+		pos := strings.Index(text, id)
+		if pos < 0 {
+			return len(text)
+		}
+		return pos
+	}
+	slices.SortFunc(ids, func(a, b string) int {
+		return cmp.Or(cmp.Compare(position(a), position(b)), strings.Compare(a, b))
+	})
+	return ids
+}
@@ -0,0 +1,147 @@
+package redditclient
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/awryme/reddit-exporter/pkg/xhttp"
+)
+
+// default base urls of reddit services
+const (
+	defaultApiUrl    = "https://oauth.reddit.com"
+	defaultAuthUrl   = "https://www.reddit.com"
+	defaultImagesUrl = "https://i.redd.it"
+)
+
+// DefaultUserAgent is sent by clients without WithUserAgent
+const DefaultUserAgent = "reddit-exporter/v1.2"
+
+// default retries of transient failures and rate limit pacing
+const (
+	defaultRetries          = 3
+	defaultRetryDelay       = time.Second
+	defaultMaxRetryDelay    = time.Minute
+	defaultRateLimitReserve = 10
+)
+
+// Option configures Client and AuthService, options of a client are passed to its auth service
+type Option func(opts *options)
+
+type options struct {
+	apiUrl     string
+	authUrl    string
+	imagesUrl  string
+	userAgent  string
+	httpClient *http.Client
+	// externalClient downloads images outside reddit hosts
+	externalClient *http.Client
+
+	retries          int
+	retryDelay       time.Duration
+	maxRetryDelay    time.Duration
+	rateLimitReserve int
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		apiUrl:    defaultApiUrl,
+		authUrl:   defaultAuthUrl,
+		imagesUrl: defaultImagesUrl,
+		userAgent: DefaultUserAgent,
+
+		retries:          defaultRetries,
+		retryDelay:       defaultRetryDelay,
+		maxRetryDelay:    defaultMaxRetryDelay,
+		rateLimitReserve: defaultRateLimitReserve,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.httpClient == nil {
+		o.httpClient = xhttp.NewClient()
+	}
+	if o.externalClient == nil {
+		o.externalClient = xhttp.NewPublicClient()
+	}
+	return o
+}
+
+// WithBaseUrl sends api, auth and image requests to one stand-in for reddit, like a fake server or a caching proxy
+func WithBaseUrl(url string) Option {
+	return func(opts *options) {
+		url = strings.TrimSuffix(url, "/")
+		opts.apiUrl = url
+		opts.authUrl = url
+		opts.imagesUrl = url
+	}
+}
+
+// WithApiUrl sets the base url of api requests, https://oauth.reddit.com by default
+func WithApiUrl(url string) Option {
+	return func(opts *options) {
+		opts.apiUrl = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithAuthUrl sets the base url of token and account authorization requests, https://www.reddit.com by default
+func WithAuthUrl(url string) Option {
+	return func(opts *options) {
+		opts.authUrl = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithImagesUrl sets the base url of original gallery and comment images, https://i.redd.it by default
+func WithImagesUrl(url string) Option {
+	return func(opts *options) {
+		opts.imagesUrl = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithUserAgent sets the user agent of all requests, reddit throttles generic user agents
+func WithUserAgent(userAgent string) Option {
+	return func(opts *options) {
+		opts.userAgent = userAgent
+	}
+}
+
+// WithHTTPClient sets the client of all requests, video downloads use a copy with a longer timeout
+func WithHTTPClient(client *http.Client) Option {
+	return func(opts *options) {
+		opts.httpClient = client
+	}
+}
+
+// WithRetries sets the number of retries of rate limited (429), failed (5xx) and network failed requests,
+// 3 by default, 0 disables retries
+func WithRetries(retries int) Option {
+	return func(opts *options) {
+		opts.retries = max(retries, 0)
+	}
+}
+
+// WithRetryDelay sets the delay before the first retry, doubled for every next one up to maxDelay,
+// Retry-After of reddit responses is used instead if it is set
+func WithRetryDelay(delay, maxDelay time.Duration) Option {
+	return func(opts *options) {
+		opts.retryDelay = delay
+		opts.maxRetryDelay = max(delay, maxDelay)
+	}
+}
+
+// WithRateLimitReserve sets the number of requests left in a rate limit window when requests start to be spread
+// over the rest of it, 10 by default, 0 only waits for the window reset when no requests are left
+func WithRateLimitReserve(reserve int) Option {
+	return func(opts *options) {
+		opts.rateLimitReserve = max(reserve, 0)
+	}
+}
+
+// WithExternalHTTPClient sets the client of image downloads outside reddit hosts,
+// their urls come from posts and articles, so the default client can't reach local networks
+func WithExternalHTTPClient(client *http.Client) Option {
+	return func(opts *options) {
+		opts.externalClient = client
+	}
+}
@@ -0,0 +1,74 @@
+package redditclient
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rate limit headers of api responses
+const (
+	headerRateLimitRemaining = "X-Ratelimit-Remaining"
+	headerRateLimitReset     = "X-Ratelimit-Reset"
+)
+
+// rateLimiter paces api requests by the rate limit headers of reddit responses,
+// requests are spread over the rest of the window when few of them remain and wait for the reset when none remain
+type rateLimiter struct {
+	// reserve is the number of remaining requests when pacing starts
+	reserve int
+
+	mu        sync.Mutex
+	remaining float64
+	// reset is the end of the current window, zero until reddit reports one
+	reset time.Time
+	// next is the earliest time of the next paced request
+	next time.Time
+}
+
+func newRateLimiter(reserve int) *rateLimiter {
+	return &rateLimiter{reserve: reserve}
+}
+
+// delay reserves a slot for a request and returns the time to wait for it
+func (rl *rateLimiter) delay(now time.Time) time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.reset.IsZero() || !now.Before(rl.reset) {
+		return 0
+	}
+
+	at := now
+	switch {
+	case rl.remaining < 1:
+		at = rl.reset
+	case rl.remaining <= float64(rl.reserve):
+		if rl.next.After(at) {
+			at = rl.next
+		}
+		rl.next = at.Add(time.Duration(float64(rl.reset.Sub(now)) / rl.remaining))
+	}
+	rl.remaining--
+
+	return at.Sub(now)
+}
+
+// update reads the rate limit of a response, responses without the headers are ignored
+func (rl *rateLimiter) update(header http.Header, now time.Time) {
+	remaining, err := strconv.ParseFloat(header.Get(headerRateLimitRemaining), 64)
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseFloat(header.Get(headerRateLimitReset), 64)
+	if err != nil {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.remaining = remaining
+	rl.reset = now.Add(time.Duration(reset * float64(time.Second)))
+}
@@ -0,0 +1,628 @@
+// Package reddittest is a fake reddit for offline tests of the client, exporter, server and bot.
+// It serves canned posts, comments, wiki pages and images added to it,
+// with app-only and account authorization that always succeed.
+package reddittest
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/awryme/reddit-exporter/redditclient"
+)
+
+const (
+	// Token is the access token issued by the server, api requests without it are unauthorized
+	Token = "reddittest-token"
+	// RefreshToken is issued for authorized accounts
+	RefreshToken = "reddittest-refresh-token"
+	// Code is the authorization code the authorize page redirects back with
+	Code = "reddittest-code"
+	// Username is the name of the authorized account, saved and upvoted lists belong to it
+	Username = "reddittest"
+)
+
+// reddit returns at most 100 items per listing page
+const maxListingLimit = 100
+
+type Server struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// posts are in the order they were added
+	posts    []redditclient.JsonPostData
+	comments map[string][]redditclient.JsonCommentData
+	wikis    map[string]redditclient.JsonWikiPageData
+	images   map[string][]byte
+	saved    []string
+	upvoted  []string
+	// collapse is the number of comments listed per parent, 0 lists all
+	collapse int
+
+	// failures are statuses of the next api responses
+	failures   []int
+	retryAfter time.Duration
+	// failedPaths are statuses of every api response of a path
+	failedPaths map[string]int
+	// revoked rejects the token until a new one is issued
+	revoked bool
+	// rateLimited adds rate limit headers to api responses
+	rateLimited bool
+	remaining   int
+	reset       time.Time
+}
+
+// NewServer starts a fake reddit, close it after use
+func NewServer() *Server {
+	srv := &Server{
+		comments:    make(map[string][]redditclient.JsonCommentData),
+		wikis:       make(map[string]redditclient.JsonWikiPageData),
+		images:      make(map[string][]byte),
+		failedPaths: make(map[string]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/access_token", srv.handleAccessToken)
+	mux.HandleFunc("GET /api/v1/authorize", srv.handleAuthorize)
+
+	api := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, srv.apiHandler(handler))
+	}
+	api("GET /api/v1/me", srv.handleMe)
+	api("GET /api/info", srv.handleInfo)
+	api("GET /r/{subreddit}/api/info", srv.handleInfo)
+	api("GET /api/morechildren", srv.handleMoreChildren)
+	api("GET /r/{subreddit}/comments/{id}", srv.handleComments)
+	api("GET /r/{subreddit}/comments/{id}/{slug...}", srv.handleComments)
+	api("GET /r/{subreddit}/wiki/{page...}", srv.handleWiki)
+	api("GET /r/{subreddit}/{sort}", srv.handleSubreddit)
+	api("GET /r/{subreddit}/search", srv.handleSearch)
+	api("GET /search", srv.handleSearch)
+	api("GET /user/{username}/{tab}", srv.handleUser)
+
+	// images are served by name from the root, like i.redd.it
+	mux.HandleFunc("GET /{name}", srv.handleImage)
+
+	srv.Server = httptest.NewServer(mux)
+	return srv
+}
+
+// Options point a client to the server
+func (srv *Server) Options() []redditclient.Option {
+	return []redditclient.Option{
+		redditclient.WithBaseUrl(srv.URL),
+		redditclient.WithHTTPClient(srv.Client()),
+	}
+}
+
+// AddPost adds a post, empty permalink and creation time are filled in
+func (srv *Server) AddPost(post redditclient.JsonPostData) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if post.Permalink == "" {
+		post.Permalink = fmt.Sprintf("/r/%s/comments/%s/", post.Subreddit, post.Id)
+	}
+	if post.CreatedUtc == 0 {
+		post.CreatedUtc = float64(len(srv.posts) + 1)
+	}
+	srv.posts = append(srv.posts, post)
+}
+
+// AddComment adds a comment to a post, comments without parent_id are top level
+func (srv *Server) AddComment(postID string, comment redditclient.JsonCommentData) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	comment.Name = cmp.Or(comment.Name, "t1_"+comment.Id)
+	comment.ParentID = cmp.Or(comment.ParentID, "t3_"+postID)
+	if comment.Permalink == "" {
+		comment.Permalink = fmt.Sprintf("/r/%s/comments/%s/comment/%s/", comment.Subreddit, postID, comment.Id)
+	}
+	srv.comments[postID] = append(srv.comments[postID], comment)
+}
+
+// CollapseComments lists at most n comments per parent in comment responses like reddit does,
+// the rest are behind "more" stubs that /api/morechildren expands, 0 lists all comments
+func (srv *Server) CollapseComments(n int) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.collapse = n
+}
+
+// AddWikiPage adds a page to the wiki of a subreddit, page is the path after /wiki/
+func (srv *Server) AddWikiPage(subreddit, page string, data redditclient.JsonWikiPageData) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.wikis[wikiKey(subreddit, page)] = data
+}
+
+// AddImage serves data as an image and returns its url, gallery originals are named <media id>.<ext>
+func (srv *Server) AddImage(name string, data []byte) string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.images[name] = data
+	return srv.URL + "/" + name
+}
+
+// Save adds posts to the saved list of the authorized account, the last saved is listed first
+func (srv *Server) Save(postIDs ...string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	for _, id := range postIDs {
+		srv.saved = slices.Insert(slices.DeleteFunc(srv.saved, func(saved string) bool { return saved == id }), 0, id)
+	}
+}
+
+// Unsave removes posts from the saved list of the authorized account
+func (srv *Server) Unsave(postIDs ...string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.saved = slices.DeleteFunc(srv.saved, func(saved string) bool { return slices.Contains(postIDs, saved) })
+}
+
+// Upvote adds posts to the upvoted list of the authorized account, the last upvoted is listed first
+func (srv *Server) Upvote(postIDs ...string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	for _, id := range postIDs {
+		srv.upvoted = slices.Insert(slices.DeleteFunc(srv.upvoted, func(upvoted string) bool { return upvoted == id }), 0, id)
+	}
+}
+
+// FailNext fails the next n api requests with status, Retry-After is set if retryAfter is not zero
+func (srv *Server) FailNext(n, status int, retryAfter time.Duration) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	for range n {
+		srv.failures = append(srv.failures, status)
+	}
+	srv.retryAfter = retryAfter
+}
+
+// FailPath fails every api request of path with status, like requests of a deleted post or a dead link,
+// status 0 serves the path again
+func (srv *Server) FailPath(path string, status int) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	if status == 0 {
+		delete(srv.failedPaths, path)
+		return
+	}
+	srv.failedPaths[path] = status
+}
+
+// RevokeToken rejects the issued token, api requests are unauthorized until the client gets a new one
+func (srv *Server) RevokeToken() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.revoked = true
+}
+
+// SetRateLimit reports remaining requests and the window reset in api responses like reddit,
+// every api request uses up one, requests over the limit fail with 429
+func (srv *Server) SetRateLimit(remaining int, reset time.Duration) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	srv.rateLimited = true
+	srv.remaining = remaining
+	srv.reset = time.Now().Add(reset)
+}
+
+// apiHandler checks the token, the rate limit, planned and path failures before serving an api request
+func (srv *Server) apiHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.mu.Lock()
+		revoked := srv.revoked
+		limited := srv.limitRequest(w)
+		status := srv.failedPaths[strings.TrimSuffix(r.URL.Path, ".json")]
+		if status == 0 && len(srv.failures) > 0 {
+			status = srv.failures[0]
+			srv.failures = srv.failures[1:]
+			if srv.retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(srv.retryAfter.Seconds())))
+			}
+		}
+		srv.mu.Unlock()
+
+		switch {
+		case revoked || r.Header.Get("Authorization") != "Bearer "+Token:
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		case limited:
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+		case status != 0:
+			http.Error(w, http.StatusText(status), status)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// limitRequest uses up a request of the rate limit window and writes rate limit headers, true if none was left
+func (srv *Server) limitRequest(w http.ResponseWriter) bool {
+	if !srv.rateLimited {
+		return false
+	}
+
+	if !time.Now().Before(srv.reset) {
+		return false
+	}
+	srv.remaining--
+	w.Header().Set("X-Ratelimit-Remaining", strconv.Itoa(max(srv.remaining, 0)))
+	// reset is in whole seconds, rounded up to not report the window over early
+	w.Header().Set("X-Ratelimit-Reset", strconv.Itoa(int(math.Ceil(time.Until(srv.reset).Seconds()))))
+	return srv.remaining < 0
+}
+
+func (srv *Server) handleAccessToken(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := r.BasicAuth(); !ok {
+		http.Error(w, "no client credentials", http.StatusUnauthorized)
+		return
+	}
+
+	srv.mu.Lock()
+	srv.revoked = false
+	srv.mu.Unlock()
+
+	resp := map[string]any{
+		"access_token": Token,
+		"token_type":   "bearer",
+		"expires_in":   86400,
+	}
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		if r.PostFormValue("code") != Code {
+			http.Error(w, "bad code", http.StatusBadRequest)
+			return
+		}
+		resp["refresh_token"] = RefreshToken
+	case "refresh_token":
+		if r.PostFormValue("refresh_token") != RefreshToken {
+			http.Error(w, "bad refresh token", http.StatusBadRequest)
+			return
+		}
+	}
+	writeJson(w, resp)
+}
+
+// handleAuthorize allows access right away and redirects back with the code
+func (srv *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirect.Scheme == "" {
+		http.Error(w, "bad redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	values := url.Values{}
+	values.Set("state", query.Get("state"))
+	values.Set("code", Code)
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (srv *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, redditclient.JsonUserData{Name: Username})
+}
+
+func (srv *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	children := make([]redditclient.JsonPost[any], 0)
+	for _, fullname := range strings.Split(r.URL.Query().Get("id"), ",") {
+		kind, id, _ := strings.Cut(fullname, "_")
+		switch redditclient.JsonKind(kind) {
+		case redditclient.KindPost:
+			if post, ok := srv.post(id); ok {
+				children = append(children, thing(redditclient.KindPost, post))
+			}
+		case redditclient.KindComment:
+			for _, comments := range srv.comments {
+				for _, comment := range comments {
+					if comment.Id == id {
+						children = append(children, thing(redditclient.KindComment, comment))
+					}
+				}
+			}
+		}
+	}
+	writeJson(w, listing(children, ""))
+}
+
+func (srv *Server) handleComments(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	id := r.PathValue("id")
+	post, ok := srv.post(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	replies, err := srv.commentTree(id, "t3_"+id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJson(w, []any{
+		listing([]redditclient.JsonPost[any]{thing(redditclient.KindPost, post)}, ""),
+		listing(replies, ""),
+	})
+}
+
+// commentTree nests comments of a post under parent like reddit does, replies are listings inside comments.
+// Comments past the collapse limit are listed by a "more" stub after the others.
+func (srv *Server) commentTree(postID, parent string) ([]redditclient.JsonPost[any], error) {
+	children := make([]redditclient.JsonPost[any], 0)
+	more := make([]string, 0)
+	for _, comment := range srv.comments[postID] {
+		if comment.ParentID != parent {
+			continue
+		}
+		if srv.collapse > 0 && len(children) >= srv.collapse {
+			more = append(more, comment.Id)
+			continue
+		}
+
+		replies, err := srv.commentTree(postID, comment.Name)
+		if err != nil {
+			return nil, err
+		}
+		if len(replies) > 0 {
+			comment.Replies, err = json.Marshal(listing(replies, ""))
+			if err != nil {
+				return nil, fmt.Errorf("encode replies: %w", err)
+			}
+		}
+		children = append(children, thing(redditclient.KindComment, comment))
+	}
+
+	if len(more) > 0 {
+		children = append(children, thing(redditclient.KindMore, redditclient.JsonMoreData{
+			Count:    len(more),
+			Id:       more[0],
+			ParentID: parent,
+			Children: more,
+		}))
+	}
+	return children, nil
+}
+
+// handleMoreChildren returns requested comments with all their replies as a flat list, parents first
+func (srv *Server) handleMoreChildren(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	query := r.URL.Query()
+	_, postID, _ := strings.Cut(query.Get("link_id"), "_")
+	ids := strings.Split(query.Get("children"), ",")
+
+	var resp redditclient.JsonMoreChildren
+	resp.Json.Errors = make([][]string, 0)
+	resp.Json.Data.Things = make([]redditclient.JsonPost[json.RawMessage], 0)
+
+	var add func(comment redditclient.JsonCommentData) error
+	add = func(comment redditclient.JsonCommentData) error {
+		data, err := json.Marshal(comment)
+		if err != nil {
+			return fmt.Errorf("encode comment: %w", err)
+		}
+		resp.Json.Data.Things = append(resp.Json.Data.Things, redditclient.JsonPost[json.RawMessage]{
+			Kind: redditclient.KindComment,
+			Data: data,
+		})
+
+		for _, reply := range srv.comments[postID] {
+			if reply.ParentID != comment.Name {
+				continue
+			}
+			if err := add(reply); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, comment := range srv.comments[postID] {
+		if !slices.Contains(ids, comment.Id) {
+			continue
+		}
+		if err := add(comment); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJson(w, resp)
+}
+
+func (srv *Server) handleWiki(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	page := strings.TrimSuffix(r.PathValue("page"), ".json")
+	data, ok := srv.wikis[wikiKey(r.PathValue("subreddit"), page)]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJson(w, thing(redditclient.KindWiki, data))
+}
+
+func (srv *Server) handleSubreddit(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	posts := srv.filterPosts(func(post redditclient.JsonPostData) bool {
+		return strings.EqualFold(post.Subreddit, r.PathValue("subreddit"))
+	})
+	sortPosts(posts, r.PathValue("sort"))
+	writeJson(w, pagedListing(posts, r.URL.Query()))
+}
+
+func (srv *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	query := r.URL.Query()
+	search := strings.ToLower(query.Get("q"))
+	subreddit := r.PathValue("subreddit")
+	posts := srv.filterPosts(func(post redditclient.JsonPostData) bool {
+		if subreddit != "" && query.Get("restrict_sr") == "1" && !strings.EqualFold(post.Subreddit, subreddit) {
+			return false
+		}
+		return strings.Contains(strings.ToLower(post.Title), search) || strings.Contains(strings.ToLower(post.Selftext), search)
+	})
+	sortPosts(posts, query.Get("sort"))
+	writeJson(w, pagedListing(posts, query))
+}
+
+func (srv *Server) handleUser(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	username := r.PathValue("username")
+	var posts []redditclient.JsonPostData
+	switch r.PathValue("tab") {
+	case "submitted":
+		posts = srv.filterPosts(func(post redditclient.JsonPostData) bool {
+			return strings.EqualFold(post.Author, username)
+		})
+		sortPosts(posts, "new")
+	case "saved", "upvoted":
+		// private lists are shown only to their account
+		if username != Username {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		ids := srv.saved
+		if r.PathValue("tab") == "upvoted" {
+			ids = srv.upvoted
+		}
+		for _, id := range ids {
+			if post, ok := srv.post(id); ok {
+				posts = append(posts, post)
+			}
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeJson(w, pagedListing(posts, r.URL.Query()))
+}
+
+func (srv *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	data, ok := srv.images[r.PathValue("name")]
+	srv.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Write(data)
+}
+
+func (srv *Server) post(id string) (redditclient.JsonPostData, bool) {
+	for _, post := range srv.posts {
+		if post.Id == id {
+			return post, true
+		}
+	}
+	return redditclient.JsonPostData{}, false
+}
+
+func (srv *Server) filterPosts(match func(post redditclient.JsonPostData) bool) []redditclient.JsonPostData {
+	posts := make([]redditclient.JsonPostData, 0)
+	for _, post := range srv.posts {
+		if match(post) {
+			posts = append(posts, post)
+		}
+	}
+	return posts
+}
+
+// sortPosts orders posts of a listing, unknown sorts keep the order posts were added in
+func sortPosts(posts []redditclient.JsonPostData, sort string) {
+	switch sort {
+	case "new":
+		slices.SortStableFunc(posts, func(a, b redditclient.JsonPostData) int {
+			return cmp.Compare(b.CreatedUtc, a.CreatedUtc)
+		})
+	case "hot", "top", "":
+		slices.SortStableFunc(posts, func(a, b redditclient.JsonPostData) int {
+			return cmp.Compare(b.Score, a.Score)
+		})
+	}
+}
+
+// pagedListing returns a page of posts after the "after" fullname, up to the "limit" query value
+func pagedListing(posts []redditclient.JsonPostData, query url.Values) redditclient.JsonListing[any] {
+	if after := query.Get("after"); after != "" {
+		i := slices.IndexFunc(posts, func(post redditclient.JsonPostData) bool {
+			return "t3_"+post.Id == after
+		})
+		posts = posts[i+1:]
+	}
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit <= 0 || limit > maxListingLimit {
+		limit = maxListingLimit
+	}
+	next := ""
+	if len(posts) > limit {
+		posts = posts[:limit]
+		next = "t3_" + posts[limit-1].Id
+	}
+
+	children := make([]redditclient.JsonPost[any], 0, len(posts))
+	for _, post := range posts {
+		children = append(children, thing(redditclient.KindPost, post))
+	}
+	return listing(children, next)
+}
+
+func thing(kind redditclient.JsonKind, data any) redditclient.JsonPost[any] {
+	return redditclient.JsonPost[any]{Kind: kind, Data: data}
+}
+
+func listing(children []redditclient.JsonPost[any], after string) redditclient.JsonListing[any] {
+	var list redditclient.JsonListing[any]
+	list.Kind = redditclient.KindListing
+	list.Data.After = after
+	list.Data.Children = children
+	return list
+}
+
+func wikiKey(subreddit, page string) string {
+	return strings.ToLower(subreddit + "/" + page)
+}
+
+func writeJson(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
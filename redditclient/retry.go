@@ -0,0 +1,145 @@
+package redditclient
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const headerRetryAfter = "Retry-After"
+
+// send sends a request and retries transient failures with backoff,
+// api requests are authorized, paced by the rate limit and authorized again once if the token is rejected
+func (cli *Client) send(ctx context.Context, client *http.Client, req *http.Request, api bool) (*http.Response, error) {
+	reauthorized := false
+	for attempt := 0; ; attempt++ {
+		if api {
+			token, err := cli.auth.Auth()
+			if err != nil {
+				return nil, fmt.Errorf("auth new token: %w", err)
+			}
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			delay := cli.limiter.delay(time.Now())
+			if delay > 0 {
+				cli.logf("waiting for reddit rate limit", slog.String("url", req.URL.String()), slog.Duration("delay", delay))
+				if err := sleep(ctx, delay); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		res, err := client.Do(req)
+		if err == nil && api {
+			cli.limiter.update(res.Header, time.Now())
+		}
+
+		if err == nil && api && res.StatusCode == http.StatusUnauthorized && !reauthorized {
+			res.Body.Close()
+			cli.logf("reddit rejected token, authenticating again", slog.String("url", req.URL.String()))
+			if _, err := cli.auth.ForceAuth(); err != nil {
+				return nil, fmt.Errorf("auth new token: %w", err)
+			}
+			reauthorized = true
+			// authorization is not a failure of the request, it does not use up a retry
+			attempt--
+			continue
+		}
+
+		delay, ok := cli.retryDelay(ctx, attempt, res, err)
+		if !ok {
+			return res, err
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = res.Status
+			res.Body.Close()
+		}
+		cli.logf("retrying reddit request",
+			slog.String("url", req.URL.String()),
+			slog.String("reason", reason),
+			slog.Int("retry", attempt+1),
+			slog.Duration("delay", delay),
+		)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retryDelay returns the time to wait before the next attempt, false if the request should not be retried
+func (cli *Client) retryDelay(ctx context.Context, attempt int, res *http.Response, err error) (time.Duration, bool) {
+	if attempt >= cli.opts.retries || ctx.Err() != nil {
+		return 0, false
+	}
+
+	// network errors are transient
+	if err != nil {
+		return cli.backoff(attempt), true
+	}
+
+	switch res.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+	default:
+		return 0, false
+	}
+
+	if delay, ok := retryAfter(res.Header, time.Now()); ok {
+		return delay, true
+	}
+	return cli.backoff(attempt), true
+}
+
+// backoff doubles retry delay with every attempt up to the max delay, with jitter for concurrent requests
+func (cli *Client) backoff(attempt int) time.Duration {
+	delay := cli.opts.retryDelay
+	for range attempt {
+		if delay >= cli.opts.maxRetryDelay {
+			break
+		}
+		delay *= 2
+	}
+	delay = min(delay, cli.opts.maxRetryDelay)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// retryAfter parses Retry-After header, it is either seconds or a date
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get(headerRetryAfter)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
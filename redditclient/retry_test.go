@@ -0,0 +1,97 @@
+package redditclient_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/awryme/reddit-exporter/redditclient"
+)
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		status   int
+		retries  int
+		revoke   bool
+		wantErr  bool
+	}{
+		{name: "rate limited", failures: 2, status: http.StatusTooManyRequests, retries: 3},
+		{name: "server error", failures: 1, status: http.StatusInternalServerError, retries: 3},
+		{name: "bad gateway", failures: 1, status: http.StatusBadGateway, retries: 3},
+		{name: "unavailable up to the last retry", failures: 3, status: http.StatusServiceUnavailable, retries: 3},
+		{name: "gateway timeout", failures: 1, status: http.StatusGatewayTimeout, retries: 3},
+		{name: "retries run out", failures: 4, status: http.StatusServiceUnavailable, retries: 3, wantErr: true},
+		{name: "retries disabled", failures: 1, status: http.StatusTooManyRequests, retries: 0, wantErr: true},
+		{name: "not found is not retried", failures: 1, status: http.StatusNotFound, retries: 3, wantErr: true},
+		{name: "revoked token is renewed", revoke: true, retries: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newServer(t)
+			srv.AddPost(redditclient.JsonPostData{Id: "abc", Title: "Post", Subreddit: "test"})
+			cli := newClient(srv, redditclient.WithRetries(test.retries))
+
+			// the first request gets the token
+			_, err := cli.GetPostByID(t.Context(), "test", "abc")
+			if err != nil {
+				t.Fatalf("get post before failures: %v", err)
+			}
+
+			srv.FailNext(test.failures, test.status, 0)
+			if test.revoke {
+				srv.RevokeToken()
+			}
+			_, err = cli.GetPostByID(t.Context(), "test", "abc")
+			if test.wantErr && err == nil {
+				t.Fatalf("got no error")
+			}
+			if !test.wantErr && err != nil {
+				t.Fatalf("get post: %v", err)
+			}
+
+			// every planned failure is used up by the retries, the next request succeeds right away
+			_, err = newClient(srv, redditclient.WithRetries(0)).GetPostByID(t.Context(), "test", "abc")
+			if err != nil {
+				t.Errorf("get post after failures: %v", err)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	srv := newServer(t)
+	srv.AddPost(redditclient.JsonPostData{Id: "abc", Title: "Post", Subreddit: "test"})
+	cli := newClient(srv, redditclient.WithRetries(1))
+
+	// Retry-After is waited instead of the short retry delay
+	srv.FailNext(1, http.StatusTooManyRequests, time.Second)
+	start := time.Now()
+	_, err := cli.GetPostByID(t.Context(), "test", "abc")
+	if err != nil {
+		t.Fatalf("get post: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least Retry-After of 1s", elapsed)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	srv := newServer(t)
+	srv.AddPost(redditclient.JsonPostData{Id: "abc", Title: "Post", Subreddit: "test"})
+	// requests over the limit fail without retries, the client must wait for the window reset
+	cli := newClient(srv, redditclient.WithRetries(0), redditclient.WithRateLimitReserve(0))
+
+	srv.SetRateLimit(2, time.Second)
+	start := time.Now()
+	for i := range 4 {
+		_, err := cli.GetPostByID(t.Context(), "test", "abc")
+		if err != nil {
+			t.Fatalf("get post #%d: %v", i+1, err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Errorf("requests took %v, want a wait for the rate limit reset", elapsed)
+	}
+}
@@ -0,0 +1,58 @@
+package redditclient
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// scopes requested for reddit accounts: reading posts, wikis and saved or upvoted lists
+var userAuthScopes = []string{"identity", "read", "history", "wikiread"}
+
+// UserAuth runs the authorization code flow for a reddit account,
+// the authorized refresh token is kept in the token store of the auth service
+type UserAuth struct {
+	auth *AuthService
+	// redirectUrl must be the redirect uri of the reddit app
+	redirectUrl string
+}
+
+func NewUserAuth(auth *AuthService, redirectUrl string) *UserAuth {
+	return &UserAuth{auth, redirectUrl}
+}
+
+// AuthorizeUrl returns the reddit page where the user allows access,
+// reddit redirects back to the redirect url with the state and a code for Authorize
+func (ua *UserAuth) AuthorizeUrl(state string) string {
+	query := url.Values{}
+	query.Set("client_id", ua.auth.clientID)
+	query.Set("response_type", "code")
+	query.Set("state", state)
+	query.Set("redirect_uri", ua.redirectUrl)
+	// permanent access comes with a refresh token
+	query.Set("duration", "permanent")
+	query.Set("scope", strings.Join(userAuthScopes, " "))
+	return ua.auth.opts.authUrl + "/api/v1/authorize?" + query.Encode()
+}
+
+// Authorize exchanges the code from the redirect for tokens and stores them
+func (ua *UserAuth) Authorize(code string) error {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", ua.redirectUrl)
+
+	res, err := ua.auth.httpAuth(form)
+	if err != nil {
+		return fmt.Errorf("http auth: %w", err)
+	}
+	if res.RefreshToken == "" {
+		return fmt.Errorf("no refresh token in auth response")
+	}
+
+	ua.auth.mu.Lock()
+	defer ua.auth.mu.Unlock()
+
+	_, err = ua.auth.storeToken(res, res.RefreshToken)
+	return err
+}
@@ -0,0 +1,143 @@
+package redditclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/awryme/reddit-exporter/pkg/mp4mux"
+)
+
+// videos are larger than images, give them more time than the default client timeout
+const videoTimeout = 5 * time.Minute
+
+// dashManifest is the part of a dash playlist that lists media files
+type dashManifest struct {
+	Periods []struct {
+		AdaptationSets []struct {
+			ContentType     string `xml:"contentType,attr"`
+			MimeType        string `xml:"mimeType,attr"`
+			Representations []struct {
+				MimeType  string `xml:"mimeType,attr"`
+				Bandwidth int    `xml:"bandwidth,attr"`
+				BaseURL   string
+			} `xml:"Representation"`
+		} `xml:"AdaptationSet"`
+	} `xml:"Period"`
+}
+
+// postVideo returns reddit hosted video of a post or its crosspost parent, nil if there is none
+func postVideo(data JsonPostData) *VideoInfo {
+	for _, media := range []*JsonPostMedia{data.SecureMedia, data.Media} {
+		if media == nil || media.RedditVideo == nil || media.RedditVideo.FallbackUrl == "" {
+			continue
+		}
+
+		video := media.RedditVideo
+		return &VideoInfo{
+			Name:     data.Id + ".mp4",
+			Url:      video.FallbackUrl,
+			DashUrl:  video.DashUrl,
+			Width:    video.Width,
+			Height:   video.Height,
+			Duration: time.Duration(video.Duration) * time.Second,
+			IsGif:    video.IsGif,
+		}
+	}
+
+	if len(data.CrosspostParentList) > 0 {
+		return postVideo(data.CrosspostParentList[0])
+	}
+	return nil
+}
+
+// DownloadVideo writes mp4 of a reddit video, reddit serves audio as a separate track,
+// it is muxed into the video if audio is set
+func (cli *Client) DownloadVideo(ctx context.Context, info VideoInfo, audio bool, buf io.Writer) error {
+	client := *cli.httpClient
+	client.Timeout = videoTimeout
+
+	if !audio || info.IsGif || info.DashUrl == "" {
+		err := cli.download(ctx, &client, info.Url, "video/*", buf)
+		if err != nil {
+			return fmt.Errorf("download reddit video: %w", err)
+		}
+		return nil
+	}
+
+	video := bytes.NewBuffer(nil)
+	err := cli.download(ctx, &client, info.Url, "video/*", video)
+	if err != nil {
+		return fmt.Errorf("download reddit video: %w", err)
+	}
+
+	audioUrl, err := cli.dashAudioUrl(ctx, &client, info.DashUrl)
+	if err != nil {
+		return fmt.Errorf("get reddit video audio url: %w", err)
+	}
+	// videos without sound have no audio track
+	if audioUrl == "" {
+		_, err := video.WriteTo(buf)
+		return err
+	}
+
+	audioData := bytes.NewBuffer(nil)
+	err = cli.download(ctx, &client, audioUrl, "audio/*", audioData)
+	if err != nil {
+		return fmt.Errorf("download reddit video audio: %w", err)
+	}
+
+	err = mp4mux.Mux(video.Bytes(), audioData.Bytes(), buf)
+	if err != nil {
+		return fmt.Errorf("mux reddit video audio: %w", err)
+	}
+	return nil
+}
+
+// dashAudioUrl returns url of the best audio track in dash playlist, empty if there is none
+func (cli *Client) dashAudioUrl(ctx context.Context, client *http.Client, dashUrl string) (string, error) {
+	data := bytes.NewBuffer(nil)
+	err := cli.download(ctx, client, dashUrl, "application/dash+xml", data)
+	if err != nil {
+		return "", fmt.Errorf("download dash playlist: %w", err)
+	}
+
+	var manifest dashManifest
+	err = xml.Unmarshal(data.Bytes(), &manifest)
+	if err != nil {
+		return "", fmt.Errorf("parse dash playlist: %w", err)
+	}
+
+	baseUrl, bandwidth := "", -1
+	for _, period := range manifest.Periods {
+		for _, set := range period.AdaptationSets {
+			setAudio := set.ContentType == "audio" || strings.HasPrefix(set.MimeType, "audio/")
+			for _, repr := range set.Representations {
+				audio := setAudio || strings.HasPrefix(repr.MimeType, "audio/")
+				if audio && repr.BaseURL != "" && repr.Bandwidth > bandwidth {
+					baseUrl, bandwidth = strings.TrimSpace(repr.BaseURL), repr.Bandwidth
+				}
+			}
+		}
+	}
+	if baseUrl == "" {
+		return "", nil
+	}
+
+	// base urls are relative to the playlist
+	playlist, err := url.Parse(dashUrl)
+	if err != nil {
+		return "", fmt.Errorf("parse dash playlist url: %w", err)
+	}
+	audioUrl, err := playlist.Parse(baseUrl)
+	if err != nil {
+		return "", fmt.Errorf("parse audio url: %w", err)
+	}
+	return audioUrl.String(), nil
+}
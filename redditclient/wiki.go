@@ -0,0 +1,45 @@
+package redditclient
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GetWikiPage returns a wiki page of a subreddit, page is the path after /wiki/: index, stories/part_1
+func (cli *Client) GetWikiPage(ctx context.Context, subreddit, page string) (*WikiPage, error) {
+	// nested pages keep their slashes
+	segments := strings.Split(page, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	path := fmt.Sprintf("/r/%s/wiki/%s", url.PathEscape(subreddit), strings.Join(segments, "/"))
+
+	var thing JsonPost[JsonWikiPageData]
+	err := jsonGet(ctx, cli, fmt.Sprintf("%s%s.json", cli.opts.apiUrl, path), &thing)
+	if err != nil {
+		return nil, fmt.Errorf("get json wiki page r/%s/wiki/%s: %w", subreddit, page, err)
+	}
+	if thing.Kind != KindWiki {
+		return nil, fmt.Errorf("wiki page kind is wrong (expected = %s, got = %s)", KindWiki, thing.Kind)
+	}
+
+	data := thing.Data
+	revisedBy := ""
+	if data.RevisionBy != nil && data.RevisionBy.Kind == KindUser {
+		revisedBy = data.RevisionBy.Data.Name
+	}
+
+	return &WikiPage{
+		Subreddit: subreddit,
+		Name:      page,
+		Url:       fmt.Sprintf("https://%s%s", domainRedditWWW, path),
+		Html:      html.UnescapeString(data.ContentHtml),
+		Markdown:  html.UnescapeString(data.ContentMd),
+		Revised:   time.Unix(int64(data.RevisionDate), 0).UTC(),
+		RevisedBy: revisedBy,
+	}, nil
+}
@@ -0,0 +1,66 @@
+package redditexporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"mime"
+	"strings"
+
+	"github.com/awryme/reddit-exporter/pkg/readability"
+)
+
+// linkHtml renders a link post as a header linking to the page and the reddit thread,
+// followed by the post text and the extracted article if articles are enabled
+func (ex *Exporter) linkHtml(ctx context.Context, post *Post) (string, error) {
+	if ex.pages == nil {
+		return linkHeader(post, nil) + post.Html, nil
+	}
+
+	page, err := ex.pages.FetchPage(ctx, post.LinkUrl)
+	if err != nil {
+		return "", fmt.Errorf("fetch linked page %s: %w", post.LinkUrl, err)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(page.ContentType)
+	// direct links to images are exported as illustrated posts
+	if strings.HasPrefix(mediaType, "image/") {
+		image := fmt.Sprintf(`<p><img src="%s" alt="%s"/></p>`, html.EscapeString(page.Url), html.EscapeString(post.Title))
+		return linkHeader(post, nil) + post.Html + image, nil
+	}
+	if mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", fmt.Errorf("linked page %s is not html (%s)", page.Url, mediaType)
+	}
+
+	article, err := readability.Extract(bytes.NewReader(page.Data), page.Url)
+	if err != nil {
+		return "", fmt.Errorf("extract article from %s: %w", page.Url, err)
+	}
+	return linkHeader(post, article) + post.Html + article.Html, nil
+}
+
+func linkHeader(post *Post, article *readability.Article) string {
+	var sb strings.Builder
+
+	title, details := post.LinkUrl, []string(nil)
+	if article != nil {
+		if article.Title != "" {
+			title = article.Title
+		}
+		if article.Byline != "" {
+			details = append(details, "by "+article.Byline)
+		}
+		if article.SiteName != "" {
+			details = append(details, article.SiteName)
+		}
+	}
+
+	fmt.Fprintf(&sb, `<p><a href="%s">%s</a>`, html.EscapeString(post.LinkUrl), html.EscapeString(title))
+	if len(details) > 0 {
+		fmt.Fprintf(&sb, ", %s", html.EscapeString(strings.Join(details, ", ")))
+	}
+	fmt.Fprintf(&sb, `</p><p><i>Shared by u/%s in <a href="%s">r/%s</a></i></p><hr/>`,
+		html.EscapeString(post.Author), html.EscapeString(post.Url), html.EscapeString(post.Subreddit))
+	return sb.String()
+}
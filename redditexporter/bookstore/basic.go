@@ -20,7 +20,7 @@ func NewBasicFS(dir string) (*BasicFS, error) {
 	return &BasicFS{dir: dir}, nil
 }
 
-func (store *BasicFS) SaveBook(id, title, format string, data io.Reader) error {
+func (store *BasicFS) SaveBook(id, title, format string, meta BookMeta, data io.Reader) error {
 	filename := fmt.Sprintf("%s.%s.%s", title, id, format)
 	filename = strings.ReplaceAll(filename, "/", "_")
 	fullname := filepath.Join(store.dir, filename)
@@ -36,3 +36,18 @@ func (store *BasicFS) SaveBook(id, title, format string, data io.Reader) error {
 	}
 	return nil
 }
+
+// DeleteBook deletes the book file, files are found by id as titles are not known
+func (store *BasicFS) DeleteBook(id string) error {
+	files, err := filepath.Glob(filepath.Join(store.dir, fmt.Sprintf("*.%s.*", id)))
+	if err != nil {
+		return fmt.Errorf("find files of book '%s': %w", id, err)
+	}
+	for _, file := range files {
+		err := os.Remove(file)
+		if err != nil {
+			return fmt.Errorf("remove book file: %w", err)
+		}
+	}
+	return nil
+}
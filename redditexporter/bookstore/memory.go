@@ -10,6 +10,7 @@ import (
 type MemoryStoredBook struct {
 	Title  string
 	Format string
+	Meta   BookMeta
 	Data   *bytes.Buffer
 }
 
@@ -24,7 +25,7 @@ func NewMemory() *Memory {
 	}
 }
 
-func (store *Memory) SaveBook(id, title, format string, data io.Reader) error {
+func (store *Memory) SaveBook(id, title, format string, meta BookMeta, data io.Reader) error {
 	buf := bytes.NewBuffer(nil)
 	_, err := io.Copy(buf, data)
 	if err != nil {
@@ -37,6 +38,7 @@ func (store *Memory) SaveBook(id, title, format string, data io.Reader) error {
 	store.books[id] = MemoryStoredBook{
 		Title:  title,
 		Format: format,
+		Meta:   meta,
 		Data:   buf,
 	}
 
@@ -4,11 +4,28 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"time"
 )
 
-type BookStore interface {
-	SaveBook(id, title, format string, data io.Reader) error
-}
+type (
+	BookMeta = struct {
+		Author    string
+		Subreddit string
+		Url       string
+		Created   time.Time
+		Edited    time.Time
+		Score     int
+		Flair     string
+	}
+
+	BookStore interface {
+		SaveBook(id, title, format string, meta BookMeta, data io.Reader) error
+	}
+
+	BookRemover interface {
+		DeleteBook(id string) error
+	}
+)
 
 type MultiStore struct {
 	stores map[string]BookStore
@@ -18,7 +35,7 @@ func NewMultiStore(stores map[string]BookStore) *MultiStore {
 	return &MultiStore{stores}
 }
 
-func (ms *MultiStore) SaveBook(id, title, format string, data io.Reader) error {
+func (ms *MultiStore) SaveBook(id, title, format string, meta BookMeta, data io.Reader) error {
 	byteBuf, err := io.ReadAll(data)
 	if err != nil {
 		return fmt.Errorf("read all data for multi-store: %w", err)
@@ -27,10 +44,25 @@ func (ms *MultiStore) SaveBook(id, title, format string, data io.Reader) error {
 	buf := bytes.NewReader(byteBuf)
 	for name, store := range ms.stores {
 		buf.Seek(0, io.SeekStart)
-		err := store.SaveBook(id, title, format, buf)
+		err := store.SaveBook(id, title, format, meta, buf)
 		if err != nil {
 			return fmt.Errorf("save book to store '%s': %w", name, err)
 		}
 	}
 	return nil
 }
+
+// DeleteBook deletes the book from every store that can delete books
+func (ms *MultiStore) DeleteBook(id string) error {
+	for name, store := range ms.stores {
+		remover, ok := store.(BookRemover)
+		if !ok {
+			continue
+		}
+		err := remover.DeleteBook(id)
+		if err != nil {
+			return fmt.Errorf("delete book from store '%s': %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package redditexporter
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// commentChapters renders a flattened comment tree as a "Comments" chapter
+// with a nested chapter per top level thread, replies are nested blockquotes
+func commentChapters(comments []PostComment) []Chapter {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	chapters := []Chapter{{
+		Title:    "Comments",
+		Html:     fmt.Sprintf("<h1>Comments</h1><p>%d comments</p>", len(comments)),
+		Markdown: fmt.Sprintf("%d comments", len(comments)),
+		Depth:    0,
+	}}
+
+	for start := 0; start < len(comments); {
+		end := start + 1
+		for end < len(comments) && comments[end].Depth > comments[start].Depth {
+			end++
+		}
+
+		thread := comments[start:end]
+		chapters = append(chapters, Chapter{
+			Title:    commentAuthor(thread[0]),
+			Html:     renderThread(thread),
+			Markdown: renderThreadMarkdown(thread),
+			Depth:    1,
+		})
+		start = end
+	}
+
+	return chapters
+}
+
+func renderThread(thread []PostComment) string {
+	var sb strings.Builder
+
+	baseDepth := thread[0].Depth
+	depth := baseDepth
+	for _, comment := range thread {
+		for ; depth > comment.Depth; depth-- {
+			sb.WriteString("</blockquote>")
+		}
+		for ; depth < comment.Depth; depth++ {
+			sb.WriteString("<blockquote>")
+		}
+
+		fmt.Fprintf(&sb, "<p><b>%s</b> (%d points)</p>", html.EscapeString(commentAuthor(comment)), comment.Score)
+		sb.WriteString(comment.Html)
+	}
+	for ; depth > baseDepth; depth-- {
+		sb.WriteString("</blockquote>")
+	}
+
+	return sb.String()
+}
+
+// renderThreadMarkdown is renderThread for markdown, replies are nested quotes
+func renderThreadMarkdown(thread []PostComment) string {
+	var sb strings.Builder
+
+	baseDepth := thread[0].Depth
+	for _, comment := range thread {
+		quote := strings.Repeat("> ", comment.Depth-baseDepth)
+		text := fmt.Sprintf("**%s** (%d points)\n\n%s", commentAuthor(comment), comment.Score, strings.TrimSpace(comment.Markdown))
+		for _, line := range strings.Split(text, "\n") {
+			sb.WriteString(strings.TrimRight(quote+line, " ") + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+func commentAuthor(comment PostComment) string {
+	return "u/" + comment.Author
+}
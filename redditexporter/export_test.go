@@ -0,0 +1,207 @@
+package redditexporter_test
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/awryme/reddit-exporter/bookencoding"
+	"github.com/awryme/reddit-exporter/redditclient"
+	"github.com/awryme/reddit-exporter/redditclient/reddittest"
+	"github.com/awryme/reddit-exporter/redditexporter"
+	"github.com/awryme/reddit-exporter/redditexporter/bookstore"
+	"github.com/awryme/reddit-exporter/redditexporter/imagestore"
+)
+
+// newServer is a fake reddit with text posts p1, p2 and p3 of r/test
+func newServer(t *testing.T) *reddittest.Server {
+	srv := reddittest.NewServer()
+	t.Cleanup(srv.Close)
+
+	for _, id := range []string{"p1", "p2", "p3"} {
+		srv.AddPost(redditclient.JsonPostData{
+			Id:        id,
+			Title:     "Post " + id,
+			Author:    "author",
+			Subreddit: "test",
+			Selfhtml:  html.EscapeString("<p>text of " + id + "</p>"),
+			IsSelf:    true,
+		})
+	}
+	return srv
+}
+
+func newExporter(srv *reddittest.Server, opts ...redditexporter.Option) *redditexporter.Exporter {
+	return newTokenExporter(srv, redditclient.NewMemoryTokenStore(), opts...)
+}
+
+// newTokenExporter uses tokens of an authorized account for saved and upvoted lists
+func newTokenExporter(srv *reddittest.Server, tokens redditclient.TokenStore, opts ...redditexporter.Option) *redditexporter.Exporter {
+	clientOpts := append(srv.Options(), redditclient.WithRetryDelay(time.Millisecond, 10*time.Millisecond))
+	client := redditclient.New(slog.DiscardHandler, "client", "secret", tokens, clientOpts...)
+	return redditexporter.New(client, bookencoding.NewMarkdown(), bookstore.NewMemory(), imagestore.NewMemory(), opts...)
+}
+
+func postUrl(id string) string {
+	return fmt.Sprintf("https://www.reddit.com/r/test/comments/%s/title/", id)
+}
+
+func TestExportResults(t *testing.T) {
+	tests := []struct {
+		name        string
+		urls        []string
+		concurrency int
+		stopOnError bool
+		// failures fail the next api requests, the first is the request of the first url
+		failures int
+		status   int
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "all exported",
+			urls: []string{postUrl("p1"), postUrl("p2"), postUrl("p3")},
+			want: []string{redditexporter.StatusExported, redditexporter.StatusExported, redditexporter.StatusExported},
+		},
+		{
+			name:        "concurrent exports keep url order",
+			urls:        []string{postUrl("p1"), postUrl("p2"), postUrl("p3")},
+			concurrency: 3,
+			want:        []string{redditexporter.StatusExported, redditexporter.StatusExported, redditexporter.StatusExported},
+		},
+		{
+			name:     "failed url does not stop others",
+			urls:     []string{postUrl("p1"), postUrl("p2"), postUrl("p3")},
+			failures: 1,
+			status:   http.StatusNotFound,
+			want:     []string{redditexporter.StatusFailed, redditexporter.StatusExported, redditexporter.StatusExported},
+		},
+		{
+			name: "missing post and bad url fail",
+			urls: []string{postUrl("p1"), postUrl("missing"), "https://example.com/post", postUrl("p3")},
+			want: []string{redditexporter.StatusExported, redditexporter.StatusFailed, redditexporter.StatusFailed, redditexporter.StatusExported},
+		},
+		{
+			name:     "retried failure exports",
+			urls:     []string{postUrl("p1"), postUrl("p2")},
+			failures: 2,
+			status:   http.StatusServiceUnavailable,
+			want:     []string{redditexporter.StatusExported, redditexporter.StatusExported},
+		},
+		{
+			name:        "stop on error skips the rest",
+			urls:        []string{postUrl("p1"), postUrl("p2"), postUrl("p3")},
+			stopOnError: true,
+			failures:    1,
+			status:      http.StatusNotFound,
+			want:        []string{redditexporter.StatusFailed, redditexporter.StatusSkipped, redditexporter.StatusSkipped},
+			wantErr:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newServer(t)
+			ex := newExporter(srv, redditexporter.WithConcurrency(test.concurrency))
+
+			srv.FailNext(test.failures, test.status, 0)
+			resp, err := ex.Export(t.Context(), redditexporter.Request{
+				Urls:        test.urls,
+				StopOnError: test.stopOnError,
+			})
+			if test.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want error: %v", err, test.wantErr)
+			}
+
+			got := make([]string, 0, len(resp.Sources))
+			for i, source := range resp.Sources {
+				got = append(got, source.Status)
+				if source.Url != test.urls[i] {
+					t.Errorf("source #%d has url %s, want %s", i+1, source.Url, test.urls[i])
+				}
+				if (source.Status == redditexporter.StatusExported) != (source.Err == nil) {
+					t.Errorf("source #%d is %s with error %v", i+1, source.Status, source.Err)
+				}
+				if source.Status == redditexporter.StatusExported && len(source.BookIds["md"]) != 1 {
+					t.Errorf("source #%d has books %v, want one md book", i+1, source.BookIds)
+				}
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got statuses %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExportListingFailures(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		stopOnError bool
+		want        string
+		// wantBooks are books of exported posts, the failed post has none
+		wantBooks int
+	}{
+		{
+			name:      "listing skips failed post",
+			url:       "https://www.reddit.com/r/test/new/",
+			want:      redditexporter.StatusExported,
+			wantBooks: 3,
+		},
+		{
+			name:      "user skips failed post",
+			url:       "https://www.reddit.com/user/author/submitted/",
+			want:      redditexporter.StatusExported,
+			wantBooks: 3,
+		},
+		{
+			name:        "listing stops on error",
+			url:         "https://www.reddit.com/r/test/new/",
+			stopOnError: true,
+			want:        redditexporter.StatusFailed,
+		},
+		{
+			name:        "user stops on error",
+			url:         "https://www.reddit.com/user/author/submitted/",
+			stopOnError: true,
+			want:        redditexporter.StatusFailed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newServer(t)
+			// the linked page of the post can't be fetched
+			srv.AddPost(redditclient.JsonPostData{
+				Id:        "link",
+				Title:     "Link",
+				Author:    "author",
+				Subreddit: "test",
+				Url:       "https://example.com/missing",
+			})
+			ex := newExporter(srv, redditexporter.WithArticles(pageFetcher{}))
+
+			resp, err := ex.Export(t.Context(), redditexporter.Request{
+				Urls:        []string{test.url},
+				StopOnError: test.stopOnError,
+			})
+			if test.stopOnError != (err != nil) {
+				t.Fatalf("got error %v, want error: %v", err, test.stopOnError)
+			}
+
+			source := resp.Sources[0]
+			if source.Status != test.want {
+				t.Fatalf("got status %s, want %s: %v", source.Status, test.want, source.Err)
+			}
+			if source.Err == nil || !strings.Contains(source.Err.Error(), "example.com/missing") {
+				t.Errorf("got error %v, want error of the failed post", source.Err)
+			}
+			if test.wantBooks > 0 && len(source.BookIds["md"]) != test.wantBooks {
+				t.Errorf("got books %v, want %d md books", source.BookIds, test.wantBooks)
+			}
+		})
+	}
+}
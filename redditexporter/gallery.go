@@ -0,0 +1,58 @@
+package redditexporter
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+)
+
+// sizedImages replaces image urls with previews if image width is limited
+func (ex *Exporter) sizedImages(images []ImageInfo) []ImageInfo {
+	if ex.imageWidth <= 0 {
+		return images
+	}
+
+	sized := slices.Clone(images)
+	for i, image := range sized {
+		if image.Width > 0 && image.Width <= ex.imageWidth {
+			continue
+		}
+		// previews are ordered by width
+		for _, preview := range image.Previews {
+			if preview.Width > ex.imageWidth {
+				break
+			}
+			sized[i].Url = preview.Url
+			sized[i].Width = preview.Width
+			sized[i].Height = preview.Height
+		}
+	}
+	return sized
+}
+
+// galleryHtml renders images as a page per image with its caption,
+// images are downloaded into the book when it is saved
+func galleryHtml(images []ImageInfo) string {
+	var sb strings.Builder
+	for _, image := range images {
+		fmt.Fprintf(&sb, `<p><img src="%s" alt="%s"/></p>`, html.EscapeString(image.Url), html.EscapeString(cmp.Or(image.Caption, image.Name)))
+		if image.Caption != "" {
+			fmt.Fprintf(&sb, "<p><i>%s</i></p>", html.EscapeString(image.Caption))
+		}
+	}
+	return sb.String()
+}
+
+// galleryMarkdown renders images as markdown links to the original images
+func galleryMarkdown(images []ImageInfo) string {
+	var sb strings.Builder
+	for _, image := range images {
+		fmt.Fprintf(&sb, "\n\n![%s](%s)", cmp.Or(image.Caption, image.Name), image.Url)
+		if image.Caption != "" {
+			fmt.Fprintf(&sb, "\n\n*%s*", image.Caption)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,182 @@
+package redditexporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// embedImages downloads images referenced by <img> tags in book html and adds them to book images,
+// src attributes are rewritten to image names, images that failed to download are replaced with alt text
+func (ex *Exporter) embedImages(ctx context.Context, book *Book) {
+	// same image can be used in several chapters, download it once
+	var urls []string
+	seen := make(map[string]bool)
+	for _, src := range append([]string{book.Html}, chapterHtmls(book.Chapters)...) {
+		for _, imageUrl := range imageUrls(src) {
+			if !seen[imageUrl] {
+				seen[imageUrl] = true
+				urls = append(urls, imageUrl)
+			}
+		}
+	}
+
+	images := make([]*BookImage, len(urls))
+	counter := newImageCounter(ctx, len(urls))
+	// failed images are left out, the error is never returned
+	_ = forEach(ctx, ex.concurrency, len(urls), func(ctx context.Context, i int) error {
+		_ = ex.download(ctx, func() error {
+			image, err := ex.downloadBookImage(ctx, urls[i])
+			images[i] = image
+			return err
+		})
+		counter.downloaded()
+		return nil
+	})
+
+	// images are named in order of appearance
+	names := make(map[string]string, len(urls))
+	for i, image := range images {
+		if image == nil {
+			continue
+		}
+		image.Name = fmt.Sprintf("image%03d.%s", len(book.Images)+1, imageExtensions[image.ContentType])
+		book.Images = append(book.Images, *image)
+		names[urls[i]] = image.Name
+	}
+
+	book.Html = rewriteImages(names, book.Html)
+	for i := range book.Chapters {
+		book.Chapters[i].Html = rewriteImages(names, book.Chapters[i].Html)
+	}
+}
+
+func chapterHtmls(chapters []Chapter) []string {
+	htmls := make([]string, 0, len(chapters))
+	for _, chapter := range chapters {
+		htmls = append(htmls, chapter.Html)
+	}
+	return htmls
+}
+
+// imageUrls returns src of <img> tags in html
+func imageUrls(src string) []string {
+	// fast path, most posts have no images
+	if !strings.Contains(src, "<img") {
+		return nil
+	}
+
+	var urls []string
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(src))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == xhtml.ErrorToken {
+			return urls
+		}
+		if tokenType != xhtml.StartTagToken && tokenType != xhtml.SelfClosingTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+		if imageUrl := attrValue(token, "src"); token.Data == "img" && imageUrl != "" {
+			urls = append(urls, imageUrl)
+		}
+	}
+}
+
+// rewriteImages points <img> tags to embedded images by names of their urls
+func rewriteImages(names map[string]string, src string) string {
+	// fast path, most posts have no images
+	if !strings.Contains(src, "<img") {
+		return src
+	}
+
+	var sb strings.Builder
+	tokenizer := xhtml.NewTokenizer(strings.NewReader(src))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == xhtml.ErrorToken {
+			return sb.String()
+		}
+
+		raw := tokenizer.Raw()
+		if tokenType != xhtml.StartTagToken && tokenType != xhtml.SelfClosingTagToken {
+			sb.Write(raw)
+			continue
+		}
+
+		token := tokenizer.Token()
+		if token.Data != "img" {
+			sb.Write(raw)
+			continue
+		}
+
+		imageUrl := attrValue(token, "src")
+		name := names[imageUrl]
+		if name == "" {
+			sb.WriteString(imageAltText(token, imageUrl))
+			continue
+		}
+
+		sb.WriteString("<img")
+		for _, attr := range token.Attr {
+			if attr.Key == "src" || attr.Key == "srcset" {
+				continue
+			}
+			fmt.Fprintf(&sb, ` %s="%s"`, attr.Key, html.EscapeString(attr.Val))
+		}
+		fmt.Fprintf(&sb, ` src="%s"/>`, html.EscapeString(name))
+	}
+}
+
+// downloadBookImage downloads an image of book html, it is named when added to the book
+func (ex *Exporter) downloadBookImage(ctx context.Context, imageUrl string) (*BookImage, error) {
+	buf := bytes.NewBuffer(nil)
+	err := ex.client.DownloadImage(ctx, ImageInfo{Url: imageUrl}, buf)
+	if err != nil {
+		return nil, fmt.Errorf("download image %s: %w", imageUrl, err)
+	}
+
+	contentType := http.DetectContentType(buf.Bytes())
+	if _, ok := imageExtensions[contentType]; !ok {
+		return nil, fmt.Errorf("download image %s: unsupported content type %s", imageUrl, contentType)
+	}
+
+	return &BookImage{
+		ContentType: contentType,
+		Data:        buf.Bytes(),
+	}, nil
+}
+
+var imageExtensions = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+func imageAltText(token xhtml.Token, imageUrl string) string {
+	alt := attrValue(token, "alt")
+	if alt == "" {
+		alt = "image"
+	}
+	text := html.EscapeString(fmt.Sprintf("[%s]", alt))
+	if imageUrl == "" {
+		return text
+	}
+	return fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(imageUrl), text)
+}
+
+func attrValue(token xhtml.Token, key string) string {
+	for _, attr := range token.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package redditexporter_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/awryme/reddit-exporter/redditclient"
+	"github.com/awryme/reddit-exporter/redditexporter"
+)
+
+// pageFetcher serves pages by url, like a public fetcher that already downloaded them
+type pageFetcher map[string]*redditexporter.Page
+
+func (fetcher pageFetcher) FetchPage(_ context.Context, url string) (*redditexporter.Page, error) {
+	page, ok := fetcher[url]
+	if !ok {
+		return nil, fmt.Errorf("page %s not found", url)
+	}
+	return page, nil
+}
+
+func articleHtml(imageUrl string) string {
+	paragraph := "<p>" + strings.Repeat("Text of the linked article, long enough to be extracted. ", 10) + "</p>"
+	return `<html><head><title>Article</title></head><body><article><h1>Article</h1>` +
+		paragraph + `<img src="` + imageUrl + `" alt="local"/>` + paragraph + paragraph + `</article></body></html>`
+}
+
+func TestExportPrivateImages(t *testing.T) {
+	// local is an image server on a private address, it must not be reachable through posts
+	var requests atomic.Int32
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(pngImage(t))
+	}))
+	t.Cleanup(local.Close)
+	localImage := local.URL + "/image.png"
+
+	tests := []struct {
+		name string
+		page *redditexporter.Page
+	}{
+		{
+			name: "image in an article",
+			page: &redditexporter.Page{ContentType: "text/html", Data: []byte(articleHtml(localImage))},
+		},
+		{
+			name: "direct image link",
+			page: &redditexporter.Page{Url: localImage, ContentType: "image/png", Data: pngImage(t)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests.Store(0)
+			srv := newServer(t)
+			linkUrl := "https://example.com/article"
+			srv.AddPost(redditclient.JsonPostData{
+				Id:        "link",
+				Title:     "Link",
+				Subreddit: "test",
+				Url:       linkUrl,
+			})
+			if test.page.Url == "" {
+				test.page.Url = linkUrl
+			}
+			ex := newExporter(srv, redditexporter.WithArticles(pageFetcher{linkUrl: test.page}))
+
+			resp, err := ex.ExportURLs(t.Context(), postUrl("link"))
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			if status := resp.Sources[0].Status; status != redditexporter.StatusExported {
+				t.Fatalf("got status %s, want %s: %v", status, redditexporter.StatusExported, resp.Sources[0].Err)
+			}
+			if n := requests.Load(); n != 0 {
+				t.Errorf("private image server got %d requests, want none", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package redditexporter
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+)
+
+// number of exported listing posts if request has no limit
+const defaultListingLimit = 25
+
+// exportListing exports posts of a subreddit listing or search url in listing order,
+// as one anthology book or as a book per post
+func (ex *Exporter) exportListing(ctx context.Context, req Request, encoders []BookEncoder, info *urlInfo, out *ExportedSource) error {
+	limit := cmp.Or(req.Listing.Limit, defaultListingLimit)
+	info.Period = listingPeriod(info, req.Listing)
+
+	var posts []Post
+	var err error
+	if info.Search != "" {
+		posts, err = ex.client.SearchPosts(ctx, info.Subreddit, info.Search, info.Sort, info.Period, limit)
+	} else {
+		posts, err = ex.client.GetSubredditPosts(ctx, info.Subreddit, info.Sort, info.Period, limit)
+	}
+	if err != nil {
+		return fmt.Errorf("list posts: %w", err)
+	}
+
+	posts = slices.DeleteFunc(posts, func(post Post) bool {
+		return req.Listing.MinScore != 0 && post.Score < req.Listing.MinScore
+	})
+	if len(posts) == 0 {
+		return fmt.Errorf("no listed posts match the filter")
+	}
+	progress(ctx, Progress{Stage: StageFetched, Total: len(posts)})
+
+	if req.Anthology {
+		book := &Book{
+			Title: listingTitle(info),
+			Meta: BookMeta{
+				Subreddit: info.Subreddit,
+				Url:       out.Url,
+				Created:   posts[0].Created,
+			},
+		}
+		return ex.exportAnthology(ctx, encoders, book, posts, out)
+	}
+
+	return ex.exportPosts(ctx, req, encoders, posts, out)
+}
+
+// listingPeriod is the time filter of the url, top, controversial and search urls without t use the filter period
+func listingPeriod(info *urlInfo, filter ListingFilter) string {
+	if info.Period != "" || (info.Search == "" && info.Sort != "top" && info.Sort != "controversial") {
+		return info.Period
+	}
+	return filter.Period
+}
+
+// listingTitle names listing anthologies: "r/WritingPrompts: top posts of the week", "Search 'dragons' in r/Fantasy"
+func listingTitle(info *urlInfo) string {
+	where := "reddit"
+	if info.Subreddit != "" {
+		where = "r/" + info.Subreddit
+	}
+	if info.Search != "" {
+		return fmt.Sprintf("Search '%s' in %s", info.Search, where)
+	}
+
+	title := fmt.Sprintf("%s: %s posts", where, info.Sort)
+	switch info.Period {
+	case "", "all":
+		if info.Sort == "top" {
+			title += " of all time"
+		}
+	default:
+		title += " of the " + info.Period
+	}
+	return title
+}
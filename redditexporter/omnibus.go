@@ -0,0 +1,238 @@
+package redditexporter
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/awryme/slogf"
+	"golang.org/x/net/html"
+)
+
+// upper bound of parts in one omnibus, protects from following link loops forever
+const maxOmnibusParts = 200
+
+// number of latest author submissions searched for parts with matching titles
+const omnibusSubmissionsLimit = 500
+
+var (
+	// matches part markers in titles: "Part 2", "pt. 3", "Chapter 10", "Ep 4"
+	partMarkerRe = regexp.MustCompile(`(?i)[\s\[\(\-:,|]*\b(?:part|pt|chapter|ch|episode|ep|book)\b\.?\s*#?(\d+)`)
+
+	// matches anchor texts of links to other parts
+	partLinkTextRe = regexp.MustCompile(`(?i)\b(?:next|prev|previous|part|pt|chapter|ch|first|last|continued|continue|episode|ep)\b|^\s*\d+\s*$`)
+
+	// matches reddit post links: /r/<sub>/comments/<id>
+	postLinkRe = regexp.MustCompile(`(?i)^(?:https?://(?:www\.|old\.|new\.)?reddit\.com)?/r/([a-z0-9_]+)/comments/([a-z0-9]+)`)
+)
+
+type postLink struct {
+	Subreddit string
+	PostID    string
+}
+
+// exportOmnibus exports all parts of a serialized story as one book with a chapter per part.
+// Parts are found by following part links in post bodies and by searching author submissions for matching titles.
+func (ex *Exporter) exportOmnibus(ctx context.Context, encoders []BookEncoder, subreddit, postID string, out *ExportedSource) error {
+	start, err := ex.client.GetPostByID(ctx, subreddit, postID)
+	if err != nil {
+		return fmt.Errorf("download reddit post r/%s/%s: %w", subreddit, postID, err)
+	}
+
+	parts, err := ex.findParts(ctx, start)
+	if err != nil {
+		return err
+	}
+	sortParts(parts)
+	progress(ctx, Progress{Stage: StageFetched, Title: start.Title, Total: len(parts)})
+
+	title := seriesTitle(start.Title)
+	if title == "" {
+		title = start.Title
+	}
+	book := &Book{
+		Title: title,
+		Meta:  postMeta(start),
+	}
+	// omnibus is as old as its first part
+	book.Meta.Created = parts[0].Created
+
+	for _, part := range parts {
+		book.Chapters = append(book.Chapters, Chapter{
+			Title:    part.Title,
+			Html:     part.Html + galleryHtml(ex.sizedImages(part.Gallery)),
+			Markdown: part.Markdown + galleryMarkdown(ex.sizedImages(part.Gallery)),
+			Depth:    0,
+		})
+
+		comments, err := ex.commentChapters(ctx, part.Subreddit, part.ID)
+		if err != nil {
+			return err
+		}
+		for _, chapter := range comments {
+			chapter.Depth++
+			book.Chapters = append(book.Chapters, chapter)
+		}
+	}
+
+	return ex.saveBook(ctx, encoders, book, out)
+}
+
+func (ex *Exporter) findParts(ctx context.Context, start *Post) ([]Post, error) {
+	parts := map[string]Post{start.ID: *start}
+
+	// follow links to other parts, breadth first
+	queue := []Post{*start}
+	for len(queue) > 0 && len(parts) < maxOmnibusParts {
+		post := queue[0]
+		queue = queue[1:]
+
+		for _, link := range findPartLinks(post.Html) {
+			if _, ok := parts[link.PostID]; ok {
+				continue
+			}
+
+			linked, err := ex.client.GetPostByID(ctx, link.Subreddit, link.PostID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				// series often link to deleted or removed parts, the omnibus is made of reachable parts
+				ex.logf("skip linked part", slog.String("post", fmt.Sprintf("r/%s/%s", link.Subreddit, link.PostID)), slogf.Error(err))
+				continue
+			}
+			if linked.Author != start.Author {
+				continue
+			}
+
+			parts[linked.ID] = *linked
+			queue = append(queue, *linked)
+		}
+	}
+
+	// deleted accounts have no submissions list,
+	// titles that are only a part marker ("Chapter 1") would match unrelated posts
+	title := normalizeTitle(seriesTitle(start.Title))
+	if start.Author != "" && start.Author != "[deleted]" && title != "" {
+		submissions, err := ex.client.GetUserPosts(ctx, start.Author, omnibusSubmissionsLimit)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// suspended or hidden accounts have no submissions list, the omnibus is made of linked parts
+			ex.logf("skip submissions of author", slog.String("author", "u/"+start.Author), slogf.Error(err))
+		}
+
+		for _, post := range submissions {
+			if len(parts) >= maxOmnibusParts {
+				break
+			}
+			if post.Subreddit != start.Subreddit {
+				continue
+			}
+			if normalizeTitle(seriesTitle(post.Title)) != title {
+				continue
+			}
+			parts[post.ID] = post
+		}
+	}
+
+	partList := make([]Post, 0, len(parts))
+	for _, post := range parts {
+		partList = append(partList, post)
+	}
+	return partList, nil
+}
+
+// findPartLinks returns links to reddit posts with anchor texts that look like part navigation
+func findPartLinks(postHtml string) []postLink {
+	links := make([]postLink, 0)
+
+	tokenizer := html.NewTokenizer(strings.NewReader(postHtml))
+	href := ""
+	text := strings.Builder{}
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken:
+			token := tokenizer.Token()
+			if token.Data != "a" {
+				continue
+			}
+			href = attrValue(token, "href")
+			text.Reset()
+		case html.TextToken:
+			if href != "" {
+				text.Write(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			token := tokenizer.Token()
+			if token.Data != "a" || href == "" {
+				continue
+			}
+
+			match := postLinkRe.FindStringSubmatch(href)
+			if match != nil && partLinkTextRe.MatchString(text.String()) {
+				links = append(links, postLink{
+					Subreddit: match[1],
+					PostID:    strings.ToLower(match[2]),
+				})
+			}
+			href = ""
+		}
+	}
+}
+
+// seriesTitle cuts the part marker and everything after it: "My Story, Part 2: The End" -> "My Story"
+func seriesTitle(title string) string {
+	loc := partMarkerRe.FindStringIndex(title)
+	if loc == nil {
+		return strings.TrimSpace(title)
+	}
+	return strings.TrimSpace(title[:loc[0]])
+}
+
+// partNumber returns the number from the title part marker, 0 if there is none
+func partNumber(title string) int {
+	match := partMarkerRe.FindStringSubmatch(title)
+	if match == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(match[1])
+	return n
+}
+
+func normalizeTitle(title string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, title)
+}
+
+// sortParts orders parts by creation time,
+// unless every part except maybe the first one has a part number in its title
+func sortParts(parts []Post) {
+	slices.SortFunc(parts, func(a, b Post) int {
+		return a.Created.Compare(b.Created)
+	})
+
+	for _, part := range parts[1:] {
+		if partNumber(part.Title) == 0 {
+			return
+		}
+	}
+
+	// first part often has no marker, treat it as part 1
+	slices.SortStableFunc(parts, func(a, b Post) int {
+		return cmp.Compare(max(partNumber(a.Title), 1), max(partNumber(b.Title), 1))
+	})
+}
@@ -0,0 +1,77 @@
+package redditexporter_test
+
+import (
+	"html"
+	"net/http"
+	"testing"
+
+	"github.com/awryme/reddit-exporter/redditclient"
+	"github.com/awryme/reddit-exporter/redditexporter"
+)
+
+func TestExportOmnibusSkipsMissingParts(t *testing.T) {
+	srv := newServer(t)
+	for _, part := range []struct{ id, html string }{
+		{"s1", `<p>one</p><a href="/r/test/comments/gone/">Part 2</a> <a href="/r/test/comments/s3/">Part 3</a>`},
+		{"s3", `<p>three</p>`},
+	} {
+		srv.AddPost(redditclient.JsonPostData{
+			Id:        part.id,
+			Title:     "Story Part " + part.id[1:],
+			Author:    "writer",
+			Subreddit: "test",
+			Selfhtml:  html.EscapeString(part.html),
+			IsSelf:    true,
+		})
+	}
+	ex := newExporter(srv)
+
+	resp, err := ex.Export(t.Context(), redditexporter.Request{
+		Urls:    []string{postUrl("s1")},
+		Omnibus: true,
+	})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	source := resp.Sources[0]
+	if source.Status != redditexporter.StatusExported {
+		t.Fatalf("omnibus is %s: %v", source.Status, source.Err)
+	}
+	if len(resp.BookIds) != 1 {
+		t.Errorf("got books %v, want one omnibus", resp.BookIds)
+	}
+}
+
+func TestExportOmnibusWithoutSubmissions(t *testing.T) {
+	srv := newServer(t)
+	for _, part := range []struct{ id, html string }{
+		{"s1", `<p>one</p><a href="/r/test/comments/s2/">Part 2</a>`},
+		{"s2", `<p>two</p>`},
+	} {
+		srv.AddPost(redditclient.JsonPostData{
+			Id:        part.id,
+			Title:     "Story Part " + part.id[1:],
+			Author:    "writer",
+			Subreddit: "test",
+			Selfhtml:  html.EscapeString(part.html),
+			IsSelf:    true,
+		})
+	}
+	srv.FailPath("/user/writer/submitted", http.StatusForbidden)
+	ex := newExporter(srv)
+
+	resp, err := ex.Export(t.Context(), redditexporter.Request{
+		Urls:    []string{postUrl("s1")},
+		Omnibus: true,
+	})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	source := resp.Sources[0]
+	if source.Status != redditexporter.StatusExported {
+		t.Fatalf("omnibus is %s: %v", source.Status, source.Err)
+	}
+	if len(resp.BookIds) != 1 {
+		t.Errorf("got books %v, want one omnibus", resp.BookIds)
+	}
+}
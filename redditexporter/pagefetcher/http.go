@@ -0,0 +1,60 @@
+package pagefetcher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type Page = struct {
+	Url         string
+	ContentType string
+	Data        []byte
+}
+
+// pages larger than this are cut, articles are much smaller
+const maxPageSize = 10 << 20
+
+// HTTP downloads pages with an http client, a client of a local test server can be used.
+// Links come from any post, servers should use a client that can't reach local networks, see xhttp.NewPublicClient.
+type HTTP struct {
+	client    *http.Client
+	userAgent string
+}
+
+func NewHTTP(client *http.Client, userAgent string) *HTTP {
+	return &HTTP{client: client, userAgent: userAgent}
+}
+
+func (fetcher *HTTP) FetchPage(ctx context.Context, url string) (*Page, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create http request for page: %w", err)
+	}
+
+	req.Header.Set("User-Agent", fetcher.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+
+	res, err := fetcher.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send http request for page: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("send http request for page: bad status %d (%s)", res.StatusCode, res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxPageSize))
+	if err != nil {
+		return nil, fmt.Errorf("read page body: %w", err)
+	}
+
+	return &Page{
+		// relative links are resolved against the page after redirects
+		Url:         res.Request.URL.String(),
+		ContentType: res.Header.Get("Content-Type"),
+		Data:        data,
+	}, nil
+}
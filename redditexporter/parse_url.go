@@ -1,9 +1,11 @@
 package redditexporter
 
 import (
+	"cmp"
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 )
@@ -12,9 +14,32 @@ type urlInfo struct {
 	Subreddit string
 	PostID    string
 	CommentID string
+	// Username is set for user profile urls with UserTab, other fields are empty then,
+	// "me" is the authorized account
+	Username string
+	// UserTab is the exported list of user profile urls: submitted (default), saved or upvoted
+	UserTab string
+	// Sort is set for subreddit listing and search urls: hot, new, top, rising, controversial,
+	// search results are sorted by relevance, top, new or comments
+	Sort string
+	// Period is the time filter of top, controversial and search urls: hour, day, week, month, year, all
+	Period string
+	// Search is set for search urls, Subreddit is empty for site wide search
+	Search string
+	// WikiPage is the page path of subreddit wiki urls, the wiki index for /r/<sub>/wiki
+	WikiPage string
 }
 
+// exported lists of user profiles, bare profile urls and other tabs export submitted posts
+var userTabs = []string{"submitted", "saved", "upvoted"}
+
+// sorts of subreddit listings, hot is the default of bare subreddit urls like the default tab of user urls
+var listingSorts = []string{"hot", "new", "top", "rising", "controversial"}
+
 func parseUrl(url string) (*urlInfo, error) {
+	// listing urls keep sort and period in the query
+	query := urlQuery(url)
+
 	// cleanup path
 	url = cleanUrl(url)
 
@@ -26,21 +51,74 @@ func parseUrl(url string) (*urlInfo, error) {
 		return fmt.Errorf("cannot parse url '%s': %s", url, s)
 	}
 
-	const reddiUrlPrefix = "https://www.reddit.com/r/"
+	// gallery links have only a post id: /gallery/<id>
+	const redditGalleryPrefix = redditSiteUrl + "/gallery/"
+	if postID, ok := strings.CutPrefix(url, redditGalleryPrefix); ok {
+		if postID == "" || strings.Contains(postID, "/") {
+			return nil, fmtErr("bad gallery id")
+		}
+		return &urlInfo{
+			PostID: postID,
+		}, nil
+	}
+
+	// user profiles: /user/<name> or /u/<name>, optionally with a tab like /submitted or /saved
+	for _, prefix := range []string{redditSiteUrl + "/user/", redditSiteUrl + "/u/"} {
+		path, ok := strings.CutPrefix(url, prefix)
+		if !ok {
+			continue
+		}
+		username, tab, _ := strings.Cut(path, "/")
+		if username == "" {
+			return nil, fmtErr("no username in url")
+		}
+		tab, _, _ = strings.Cut(tab, "/")
+		if !slices.Contains(userTabs, tab) {
+			tab = "submitted"
+		}
+		return &urlInfo{
+			Username: username,
+			UserTab:  tab,
+		}, nil
+	}
+
+	const redditSearchUrl = redditSiteUrl + "/search"
+	if url == redditSearchUrl {
+		return searchInfo("", query, fmtErr)
+	}
+
+	const reddiUrlPrefix = redditSiteUrl + "/r/"
 
 	_, path, ok := strings.Cut(url, reddiUrlPrefix)
 	if !ok {
 		return nil, fmtErr("no reddit url prefix (expected: %s)", reddiUrlPrefix)
 	}
 
-	subreddit, path, ok := strings.Cut(path, "/")
-	if !ok {
+	subreddit, path, _ := strings.Cut(path, "/")
+	if subreddit == "" {
 		return nil, fmtErr("no subreddit in url")
 	}
 
-	urlType, path, ok := strings.Cut(path, "/")
-	if !ok {
-		return nil, fmtErr("no url type (/r/<sub>/<type>/id)")
+	urlType, path, _ := strings.Cut(path, "/")
+
+	// subreddit listing: /r/<sub>, /r/<sub>/<sort>
+	if urlType == "" || slices.Contains(listingSorts, urlType) {
+		return &urlInfo{
+			Subreddit: subreddit,
+			Sort:      cmp.Or(urlType, "hot"),
+			Period:    query.Get("t"),
+		}, nil
+	}
+	if urlType == "search" {
+		return searchInfo(subreddit, query, fmtErr)
+	}
+
+	// wiki page: /r/<sub>/wiki, /r/<sub>/wiki/<page>, pages can be nested
+	if urlType == "wiki" {
+		return &urlInfo{
+			Subreddit: subreddit,
+			WikiPage:  wikiPageName(path),
+		}, nil
 	}
 
 	// short link, resolve and parse it
@@ -77,15 +155,62 @@ func parseUrl(url string) (*urlInfo, error) {
 	}, nil
 }
 
+func searchInfo(subreddit string, query url.Values, fmtErr func(s string, args ...any) error) (*urlInfo, error) {
+	search := strings.TrimSpace(query.Get("q"))
+	if search == "" {
+		return nil, fmtErr("no search query (?q=)")
+	}
+	// restrict_sr=0 searches all of reddit from a subreddit page
+	if query.Get("restrict_sr") == "0" {
+		subreddit = ""
+	}
+	return &urlInfo{
+		Subreddit: subreddit,
+		Sort:      cmp.Or(query.Get("sort"), "relevance"),
+		Period:    query.Get("t"),
+		Search:    search,
+	}, nil
+}
+
+func urlQuery(rawUrl string) url.Values {
+	_, rawQuery, _ := strings.Cut(strings.TrimSpace(rawUrl), "?")
+	rawQuery, _, _ = strings.Cut(rawQuery, "#")
+	query, _ := url.ParseQuery(rawQuery)
+	return query
+}
+
+// redditHosts are hosts of the same site, urls of them are matched as redditSiteUrl urls
+var redditHosts = []string{"reddit.com", "www.reddit.com", "old.reddit.com", "new.reddit.com"}
+
+const redditSiteUrl = "https://www.reddit.com"
+
 func cleanUrl(url string) string {
 	url = strings.TrimSpace(url)
 	url, _, _ = strings.Cut(url, "#")
 	url, _, _ = strings.Cut(url, "?")
+	url = normalizeHost(url)
 	url = strings.TrimSuffix(url, "/")
 
 	return url
 }
 
+// normalizeHost rewrites reddit urls with or without a scheme to redditSiteUrl: old.reddit.com/u/me -> https://www.reddit.com/u/me
+func normalizeHost(url string) string {
+	hostPath := url
+	for _, scheme := range []string{"https://", "http://"} {
+		if len(url) >= len(scheme) && strings.EqualFold(url[:len(scheme)], scheme) {
+			hostPath = url[len(scheme):]
+			break
+		}
+	}
+
+	host, path, _ := strings.Cut(hostPath, "/")
+	if !slices.Contains(redditHosts, strings.ToLower(host)) {
+		return url
+	}
+	return redditSiteUrl + "/" + path
+}
+
 func resolveShortUrl(url string) (*url.URL, error) {
 	cli := &http.Client{
 		Timeout: time.Second * 10,
@@ -0,0 +1,79 @@
+package redditexporter
+
+import (
+	"testing"
+)
+
+func TestParseUrl(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    urlInfo
+		wantErr bool
+	}{
+		{url: "https://www.reddit.com/r/test/comments/abc/title/", want: urlInfo{Subreddit: "test", PostID: "abc"}},
+		{url: "https://www.reddit.com/r/test/comments/abc/comment/def/", want: urlInfo{Subreddit: "test", PostID: "abc", CommentID: "def"}},
+		{url: "https://old.reddit.com/r/test/comments/abc/title/", want: urlInfo{Subreddit: "test", PostID: "abc"}},
+		{url: "reddit.com/r/test/comments/abc/title", want: urlInfo{Subreddit: "test", PostID: "abc"}},
+		{url: "https://www.reddit.com/gallery/abc", want: urlInfo{PostID: "abc"}},
+		{url: "new.reddit.com/gallery/abc", want: urlInfo{PostID: "abc"}},
+		{url: "https://www.reddit.com/user/me", want: urlInfo{Username: "me", UserTab: "submitted"}},
+		{url: "reddit.com/user/me/saved", want: urlInfo{Username: "me", UserTab: "saved"}},
+		{url: "http://old.reddit.com/u/me/upvoted/", want: urlInfo{Username: "me", UserTab: "upvoted"}},
+		{url: "www.reddit.com/u/me/comments", want: urlInfo{Username: "me", UserTab: "submitted"}},
+		{url: "https://www.reddit.com/r/test/top?t=week", want: urlInfo{Subreddit: "test", Sort: "top", Period: "week"}},
+		{url: "old.reddit.com/r/test/new/", want: urlInfo{Subreddit: "test", Sort: "new"}},
+		{url: "https://www.reddit.com/r/test", want: urlInfo{Subreddit: "test", Sort: "hot"}},
+		{url: "https://www.reddit.com/r/test/?t=day", want: urlInfo{Subreddit: "test", Sort: "hot", Period: "day"}},
+		{url: "https://www.reddit.com/r/test/search?q=story&sort=top", want: urlInfo{Subreddit: "test", Sort: "top", Search: "story"}},
+		{url: "reddit.com/search?q=story", want: urlInfo{Sort: "relevance", Search: "story"}},
+		{url: "https://www.reddit.com/r/test/wiki", want: urlInfo{Subreddit: "test", WikiPage: "index"}},
+		{url: "old.reddit.com/r/test/wiki/Stories/Part_1", want: urlInfo{Subreddit: "test", WikiPage: "stories/part_1"}},
+		{url: "https://www.reddit.com/user/", wantErr: true},
+		{url: "https://www.reddit.com/search", wantErr: true},
+		{url: "https://example.com/r/test/comments/abc/title/", wantErr: true},
+		{url: "https://www.reddit.com/r/test/about", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			info, err := parseUrl(test.url)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want error", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if *info != test.want {
+				t.Errorf("got %+v, want %+v", *info, test.want)
+			}
+		})
+	}
+}
+
+func TestListingPeriod(t *testing.T) {
+	tests := []struct {
+		url    string
+		period string
+		want   string
+	}{
+		{url: "https://www.reddit.com/r/test/top", period: "week", want: "week"},
+		{url: "https://www.reddit.com/r/test/top?t=day", period: "week", want: "day"},
+		{url: "https://www.reddit.com/r/test/search?q=story", period: "year", want: "year"},
+		{url: "https://www.reddit.com/r/test", period: "week", want: ""},
+		{url: "https://www.reddit.com/r/test/new", period: "week", want: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			info, err := parseUrl(test.url)
+			if err != nil {
+				t.Fatalf("parse url: %v", err)
+			}
+			got := listingPeriod(info, ListingFilter{Period: test.period})
+			if got != test.want {
+				t.Errorf("got period %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package redditexporter
+
+import (
+	"context"
+	"sync"
+)
+
+// forEach calls fn for indexes from 0 to n-1 on up to workers goroutines, indexes are started in order.
+// After the first error no more indexes are started and ctx of running calls is canceled,
+// the first error is returned.
+func forEach(ctx context.Context, workers, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(max(workers, 1), n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				// skip indexes sent while the pool was stopping
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := fn(ctx, i); err != nil {
+					cancel(err)
+				}
+			}
+		}()
+	}
+
+	for i := range n {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	// cause is the error of fn or the error of the parent ctx
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// download runs fn holding a slot of the download pool shared by all exports
+func (ex *Exporter) download(ctx context.Context, fn func() error) error {
+	select {
+	case ex.downloads <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-ex.downloads }()
+
+	return fn()
+}
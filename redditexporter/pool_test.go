@@ -0,0 +1,93 @@
+package redditexporter
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestForEach(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		workers int
+		n       int
+		// fail is the failing index, -1 for none
+		fail int
+		// want are indexes that ran, nil for all
+		want    []int
+		wantErr error
+	}{
+		{name: "one worker", workers: 1, n: 5, fail: -1},
+		{name: "several workers", workers: 3, n: 10, fail: -1},
+		{name: "more workers than indexes", workers: 8, n: 3, fail: -1},
+		{name: "no indexes", workers: 2, n: 0, fail: -1, want: []int{}},
+		{name: "no workers run one by one", workers: 0, n: 3, fail: -1},
+		{name: "error stops later indexes", workers: 1, n: 5, fail: 2, want: []int{0, 1, 2}, wantErr: errFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mu sync.Mutex
+			ran := make([]int, 0, test.n)
+			running, maxRunning := 0, 0
+
+			err := forEach(t.Context(), test.workers, test.n, func(ctx context.Context, i int) error {
+				mu.Lock()
+				ran = append(ran, i)
+				running++
+				maxRunning = max(maxRunning, running)
+				mu.Unlock()
+
+				// give other workers time to start
+				time.Sleep(time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				if i == test.fail {
+					return errFailed
+				}
+				return nil
+			})
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+
+			want := test.want
+			if want == nil {
+				want = make([]int, test.n)
+				for i := range want {
+					want[i] = i
+				}
+			}
+			slices.Sort(ran)
+			if !slices.Equal(ran, want) {
+				t.Errorf("ran indexes %v, want %v", ran, want)
+			}
+			if limit := max(test.workers, 1); maxRunning > limit {
+				t.Errorf("ran %d at once, want at most %d", maxRunning, limit)
+			}
+		})
+	}
+}
+
+func TestForEachCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	calls := 0
+	err := forEach(ctx, 2, 5, func(ctx context.Context, i int) error {
+		calls++
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context canceled", err)
+	}
+	if calls != 0 {
+		t.Errorf("got %d calls after cancel, want none", calls)
+	}
+}
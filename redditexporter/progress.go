@@ -0,0 +1,152 @@
+package redditexporter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// stages of progress events, every url goes through resolved and done, other stages depend on the url
+const (
+	// StageResolved url is parsed, Target is set
+	StageResolved = "resolved"
+	// StageFetched post, comment or wiki page is downloaded, lists set Total to the number of posts
+	StageFetched = "fetched"
+	// StagePost is a post of a user, listing or search url, Done of Total posts are exported before it
+	StagePost = "post"
+	// StageImages reports Done of Total images downloaded
+	StageImages = "images"
+	// StageEncoding book Title is encoded to Format
+	StageEncoding = "encoding"
+	// StageStored book or image with Id is stored, Format is empty for images and videos
+	StageStored = "stored"
+	// StageDone url is finished with Status, Err is set if it failed
+	StageDone = "done"
+)
+
+type (
+	// Progress is an event of an url export
+	Progress = struct {
+		// Index is the position of the url in the request urls without empty lines
+		Index int
+		// Count is the number of urls in the request
+		Count  int
+		Url    string
+		Target string
+		Stage  string
+		// Title of the fetched or exported post, the encoded book or the stored image
+		Title string
+		Done  int
+		Total int
+		// Format of the encoded or stored book
+		Format string
+		// Id of the stored book or image
+		Id     string
+		Status string
+		Err    error
+		// Text is the event described by ProgressText
+		Text string
+	}
+
+	// ProgressFunc receives progress events of an export,
+	// it is called concurrently by exports of different urls and should not block
+	ProgressFunc = func(event Progress)
+)
+
+type progressKey struct{}
+
+// progressReporter fills url fields of events of one url
+type progressReporter struct {
+	fn     ProgressFunc
+	index  int
+	count  int
+	url    string
+	target string
+}
+
+// withProgress makes progress events of ctx go to fn, nil fn disables them
+func withProgress(ctx context.Context, fn ProgressFunc, index, count int, source *ExportedSource) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressKey{}, &progressReporter{
+		fn:     fn,
+		index:  index,
+		count:  count,
+		url:    source.Url,
+		target: source.Target,
+	})
+}
+
+// progress sends an event to the progress func of ctx if there is one
+func progress(ctx context.Context, event Progress) {
+	reporter, ok := ctx.Value(progressKey{}).(*progressReporter)
+	if !ok {
+		return
+	}
+	event.Index = reporter.index
+	event.Count = reporter.count
+	event.Url = reporter.url
+	event.Target = reporter.target
+	event.Text = ProgressText(event)
+	reporter.fn(event)
+}
+
+// ProgressText describes an event for people: "post r/sub/abc: encoding epub"
+func ProgressText(event Progress) string {
+	what := event.Target
+	if what == "" {
+		what = event.Url
+	}
+
+	switch event.Stage {
+	case StageResolved:
+		return what + ": started"
+	case StageFetched:
+		if event.Total > 0 {
+			return fmt.Sprintf("%s: found %d posts", what, event.Total)
+		}
+		return fmt.Sprintf("%s: fetched '%s'", what, event.Title)
+	case StagePost:
+		return fmt.Sprintf("%s: exporting post %d/%d '%s'", what, event.Done+1, event.Total, event.Title)
+	case StageImages:
+		return fmt.Sprintf("%s: downloaded images %d/%d", what, event.Done, event.Total)
+	case StageEncoding:
+		return fmt.Sprintf("%s: encoding %s", what, event.Format)
+	case StageStored:
+		if event.Format == "" {
+			return fmt.Sprintf("%s: stored %s", what, event.Title)
+		}
+		return fmt.Sprintf("%s: stored %s", what, event.Format)
+	case StageDone:
+		if event.Err != nil {
+			return fmt.Sprintf("%s: %s: %v", what, event.Status, event.Err)
+		}
+		return fmt.Sprintf("%s: %s", what, event.Status)
+	}
+	return fmt.Sprintf("%s: %s", what, event.Stage)
+}
+
+// imageCounter reports downloaded images of a batch
+type imageCounter struct {
+	ctx   context.Context
+	total int
+
+	mu   sync.Mutex
+	done int
+}
+
+func newImageCounter(ctx context.Context, total int) *imageCounter {
+	if total > 0 {
+		progress(ctx, Progress{Stage: StageImages, Total: total})
+	}
+	return &imageCounter{ctx: ctx, total: total}
+}
+
+func (counter *imageCounter) downloaded() {
+	counter.mu.Lock()
+	defer counter.mu.Unlock()
+
+	counter.done++
+	progress(counter.ctx, Progress{Stage: StageImages, Done: counter.done, Total: counter.total})
+}
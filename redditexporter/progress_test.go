@@ -0,0 +1,101 @@
+package redditexporter_test
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/awryme/reddit-exporter/redditclient"
+	"github.com/awryme/reddit-exporter/redditexporter"
+)
+
+func pngImage(t *testing.T) []byte {
+	buf := bytes.NewBuffer(nil)
+	err := png.Encode(buf, image.NewGray(image.Rect(0, 0, 2, 2)))
+	if err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExportProgress(t *testing.T) {
+	tests := []struct {
+		name string
+		urls []string
+		// want are events as index:stage
+		want []string
+	}{
+		{
+			name: "text post",
+			urls: []string{postUrl("p1")},
+			want: []string{"0:resolved", "0:fetched", "0:encoding", "0:stored", "0:done"},
+		},
+		{
+			name: "post with an image",
+			urls: []string{postUrl("img")},
+			want: []string{"0:resolved", "0:fetched", "0:images", "0:images", "0:encoding", "0:stored", "0:done"},
+		},
+		{
+			name: "failed url",
+			urls: []string{postUrl("missing")},
+			want: []string{"0:resolved", "0:done"},
+		},
+		{
+			name: "urls one by one",
+			urls: []string{postUrl("p1"), postUrl("p2")},
+			want: []string{
+				"0:resolved", "0:fetched", "0:encoding", "0:stored", "0:done",
+				"1:resolved", "1:fetched", "1:encoding", "1:stored", "1:done",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := newServer(t)
+			imageUrl := srv.AddImage("image.png", pngImage(t))
+			srv.AddPost(redditclient.JsonPostData{
+				Id:        "img",
+				Title:     "Post with an image",
+				Subreddit: "test",
+				Selfhtml:  html.EscapeString(fmt.Sprintf(`<p><img src="%s"></p>`, imageUrl)),
+				IsSelf:    true,
+			})
+			ex := newExporter(srv)
+
+			var mu sync.Mutex
+			events := make([]redditexporter.Progress, 0)
+			_, err := ex.Export(t.Context(), redditexporter.Request{
+				Urls: test.urls,
+				OnProgress: func(event redditexporter.Progress) {
+					mu.Lock()
+					defer mu.Unlock()
+					events = append(events, event)
+				},
+			})
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+
+			got := make([]string, 0, len(events))
+			for _, event := range events {
+				got = append(got, fmt.Sprintf("%d:%s", event.Index, event.Stage))
+				if event.Count != len(test.urls) || event.Url != test.urls[event.Index] || event.Text == "" {
+					t.Errorf("event %+v has wrong url fields", event)
+				}
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("got events %v, want %v", got, test.want)
+			}
+
+			last := events[len(events)-1]
+			if last.Status == "" {
+				t.Errorf("done event has no status: %+v", last)
+			}
+		})
+	}
+}
@@ -2,41 +2,155 @@ package redditexporter
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/awryme/reddit-exporter/pkg/bufpool"
+	"github.com/awryme/reddit-exporter/pkg/cover"
+	"github.com/awryme/slogf"
 	"github.com/oklog/ulid/v2"
 )
 
 type (
 	Post = struct {
-		Title string
-		Html  string
+		ID        string
+		Title     string
+		Author    string
+		Subreddit string
+		Url       string
+		Created   time.Time
+		Edited    time.Time
+		Score     int
+		Flair     string
+		Html      string
+		Markdown  string
+		Gallery   []ImageInfo
+		Video     *VideoInfo
+		LinkUrl   string
+	}
+
+	VideoInfo = struct {
+		Name     string
+		Url      string
+		DashUrl  string
+		Width    int
+		Height   int
+		Duration time.Duration
+		IsGif    bool
 	}
 
 	ImageInfo = struct {
-		Name string
-		Url  string
+		Name     string
+		Url      string
+		Caption  string
+		Width    int
+		Height   int
+		Previews []ImageVariant
+	}
+
+	ImageVariant = struct {
+		Url    string
+		Width  int
+		Height int
+	}
+
+	WikiPage = struct {
+		Subreddit string
+		Name      string
+		Url       string
+		Html      string
+		Markdown  string
+		Revised   time.Time
+		RevisedBy string
 	}
 
 	Comment = struct {
-		Images []ImageInfo
+		ID        string
+		Author    string
+		Subreddit string
+		Title     string
+		Url       string
+		Created   time.Time
+		Score     int
+		Images    []ImageInfo
+	}
+
+	PostComment = struct {
+		ID       string
+		Author   string
+		Html     string
+		Markdown string
+		Score    int
+		Depth    int
+	}
+
+	CommentOptions = struct {
+		Depth    int
+		MinScore int
+		Limit    int
 	}
 
 	RedditClient interface {
 		GetPostByID(ctx context.Context, subreddit, id string) (*Post, error)
 		GetCommentByID(ctx context.Context, subreddit, id string) (*Comment, error)
+		GetPostComments(ctx context.Context, subreddit, postID string, opts CommentOptions) ([]PostComment, error)
+		GetUserPosts(ctx context.Context, username string, limit int) ([]Post, error)
+		GetSavedPosts(ctx context.Context, username string, limit int) ([]Post, error)
+		GetUpvotedPosts(ctx context.Context, username string, limit int) ([]Post, error)
+		GetSubredditPosts(ctx context.Context, subreddit, sort, period string, limit int) ([]Post, error)
+		SearchPosts(ctx context.Context, subreddit, search, sort, period string, limit int) ([]Post, error)
+		GetWikiPage(ctx context.Context, subreddit, page string) (*WikiPage, error)
 		DownloadImage(ctx context.Context, info ImageInfo, buf io.Writer) error
+		DownloadVideo(ctx context.Context, info VideoInfo, audio bool, buf io.Writer) error
 	}
 )
 
 type (
+	// Chapter is an additional book section after the main Html,
+	// chapters with Depth > 0 are nested into the closest previous chapter with lower depth.
+	// Markdown is the source of Html if it is known, text formats prefer it.
+	Chapter = struct {
+		Title    string
+		Html     string
+		Markdown string
+		Depth    int
+	}
+
+	// BookImage is an image embedded into the book, <img> tags reference it by Name in src
+	BookImage = struct {
+		Name        string
+		ContentType string
+		Data        []byte
+	}
+
+	// BookMeta describes the source post of a book
+	BookMeta = struct {
+		Author    string
+		Subreddit string
+		Url       string
+		Created   time.Time
+		// Edited is zero if post was never edited
+		Edited time.Time
+		Score  int
+		Flair  string
+	}
+
 	Book = struct {
 		Title string
+		Meta  BookMeta
 		Html  string
+		// Markdown is the source of Html, empty if unknown
+		Markdown string
+		Chapters []Chapter
+		Images   []BookImage
+		// Cover is empty (no Name) if covers are disabled
+		Cover BookImage
 	}
 
 	BookEncoder interface {
@@ -47,95 +161,520 @@ type (
 
 type (
 	BookStore interface {
-		SaveBook(id, title, format string, data io.Reader) error
+		SaveBook(id, title, format string, meta BookMeta, data io.Reader) error
 	}
 
+	// ImageStore stores downloaded images and videos
 	ImageStore interface {
 		SaveImage(id, name string, data io.Reader) error
 	}
 )
 
+type (
+	// Page is a downloaded web page, Url is the page url after redirects
+	Page = struct {
+		Url         string
+		ContentType string
+		Data        []byte
+	}
+
+	// PageFetcher downloads linked pages of link posts
+	PageFetcher interface {
+		FetchPage(ctx context.Context, url string) (*Page, error)
+	}
+)
+
 type Exporter struct {
 	client      RedditClient
 	bookEncoder BookEncoder
-	bookstore   BookStore
-	imagestore  ImageStore
+	// encoders are all available encoders by format, including bookEncoder
+	encoders   map[string]BookEncoder
+	bookstore  BookStore
+	imagestore ImageStore
+
+	comments   *CommentOptions
+	covers     bool
+	imageWidth int
+	videoAudio bool
+	pages      PageFetcher
+
+	// concurrency is the number of urls exported at once and the size of downloads pool
+	concurrency int
+	downloads   chan struct{}
+
+	// logf reports problems that don't fail exports, discards them by default
+	logf slogf.Logf
+}
+
+type Option func(ex *Exporter)
+
+// WithComments enables exporting the comment tree of posts as book chapters
+func WithComments(opts CommentOptions) Option {
+	return func(ex *Exporter) {
+		ex.comments = &opts
+	}
+}
+
+// WithCovers enables generated cover images for books
+func WithCovers() Option {
+	return func(ex *Exporter) {
+		ex.covers = true
+	}
+}
+
+// WithImageWidth makes gallery and comment images download as the largest preview
+// not wider than width, originals are used for images without such preview
+func WithImageWidth(width int) Option {
+	return func(ex *Exporter) {
+		ex.imageWidth = width
+	}
 }
 
-func New(client RedditClient, encoder BookEncoder, bookstore BookStore, imagestore ImageStore) *Exporter {
-	return &Exporter{client, encoder, bookstore, imagestore}
+// WithVideoAudio muxes the separate audio track into downloaded reddit videos
+func WithVideoAudio() Option {
+	return func(ex *Exporter) {
+		ex.videoAudio = true
+	}
+}
+
+// WithArticles enables fetching linked pages of link posts with fetcher,
+// their main content is exported as the book, external sites are never requested without it
+func WithArticles(fetcher PageFetcher) Option {
+	return func(ex *Exporter) {
+		ex.pages = fetcher
+	}
 }
 
-type Response = struct {
-	BookIds  []string
-	ImageIds []string
+// WithConcurrency exports up to n urls at once and downloads up to n images at once across all exports,
+// stores and encoders are used concurrently. Urls are exported one by one by default.
+func WithConcurrency(n int) Option {
+	return func(ex *Exporter) {
+		ex.concurrency = max(n, 1)
+	}
 }
 
+// WithLog logs parts of exports that are left out instead of failing them
+func WithLog(log slog.Handler) Option {
+	return func(ex *Exporter) {
+		ex.logf = slogf.New(log)
+	}
+}
+
+// WithEncoders adds book formats that can be requested in addition to the default encoder
+func WithEncoders(encoders ...BookEncoder) Option {
+	return func(ex *Exporter) {
+		for _, encoder := range encoders {
+			ex.encoders[encoder.Format()] = encoder
+		}
+	}
+}
+
+func New(client RedditClient, encoder BookEncoder, bookstore BookStore, imagestore ImageStore, opts ...Option) *Exporter {
+	ex := &Exporter{
+		client:      client,
+		bookEncoder: encoder,
+		encoders:    map[string]BookEncoder{encoder.Format(): encoder},
+		bookstore:   bookstore,
+		imagestore:  imagestore,
+		concurrency: 1,
+		logf:        func(string, ...slog.Attr) {},
+	}
+	for _, opt := range opts {
+		opt(ex)
+	}
+	ex.downloads = make(chan struct{}, ex.concurrency)
+	return ex
+}
+
+type Request = struct {
+	Urls []string
+	// Omnibus follows a serialized story from every post url and exports all its parts as one book
+	Omnibus bool
+	// Formats of exported books, every book is stored once per format.
+	// Default encoder is used if empty.
+	Formats []string
+	// Comic bundles images of a comment into one book instead of separate images,
+	// with cbz format the book is a comic archive
+	Comic bool
+	// Images exports images of gallery posts as separate images instead of an illustrated book
+	Images bool
+	// Anthology exports posts of a user, listing or search url as one book instead of a book per post
+	Anthology bool
+	// User selects exported posts of user urls, including saved and upvoted lists
+	User UserFilter
+	// Listing limits exported posts of subreddit listing and search urls
+	Listing ListingFilter
+	// WikiLinks follows links of wiki urls to other pages of the same wiki and exports them as chapters
+	WikiLinks bool
+	// StopOnError skips urls after the first failed one, failed urls don't stop the export by default
+	StopOnError bool
+	// OnProgress receives progress events of urls, can be nil
+	OnProgress ProgressFunc
+}
+
+// UserFilter selects posts of user urls, zero fields don't filter
+type UserFilter = struct {
+	// Subreddits are names without the r/ prefix, matched case insensitively
+	Subreddits []string
+	After      time.Time
+	Before     time.Time
+	MinScore   int
+}
+
+// ListingFilter limits posts of listing urls, zero fields use defaults
+type ListingFilter = struct {
+	// Limit is the number of listed posts, filtered posts are not replaced
+	Limit    int
+	MinScore int
+	// Period is the time filter of top, controversial and search urls without t: hour, day, week, month, year or all
+	Period string
+}
+
+// statuses of exported sources
+const (
+	StatusExported = "exported"
+	StatusFailed   = "failed"
+	// StatusSkipped urls were not started because the export stopped
+	StatusSkipped = "skipped"
+)
+
+type (
+	// ExportedSource is the result of one source url
+	ExportedSource = struct {
+		Url string
+		// Target is what the url exports: "post r/sub/abc", "saved posts of u/me", empty if the url can't be parsed
+		Target string
+		// Status is StatusExported, StatusFailed or StatusSkipped
+		Status string
+		// BookIds are ids of stored books by format
+		BookIds  map[string][]string
+		ImageIds []string
+		// Err is why the url failed or was skipped, ids stored before a failure are kept.
+		// Exported listings and user urls keep errors of their failed posts
+		Err error
+		// Duration is the time spent on the url, zero for skipped urls
+		Duration time.Duration
+	}
+
+	Response = struct {
+		BookIds  []string
+		ImageIds []string
+		// Sources are results of every url, in request order
+		Sources []ExportedSource
+	}
+)
+
 func (ex *Exporter) ExportURLs(ctx context.Context, urls ...string) (*Response, error) {
-	// todo: add logs for exporting: found image/post id, downloading url...
+	return ex.Export(ctx, Request{Urls: urls})
+}
 
-	resp := &Response{
-		BookIds:  make([]string, 0, len(urls)),
-		ImageIds: make([]string, 0, len(urls)),
+// Formats returns available book formats, default format is first
+func (ex *Exporter) Formats() []string {
+	formats := make([]string, 0, len(ex.encoders))
+	formats = append(formats, ex.bookEncoder.Format())
+	for format := range ex.encoders {
+		if format != ex.bookEncoder.Format() {
+			formats = append(formats, format)
+		}
+	}
+	slices.Sort(formats[1:])
+	return formats
+}
+
+// Export exports every url of the request, results of urls are in Sources of the response.
+// Failed urls are not an error of the export, it returns an error only if the request is bad,
+// ctx is canceled or a url failed with StopOnError, the response lists results of all urls then too.
+func (ex *Exporter) Export(ctx context.Context, req Request) (*Response, error) {
+	encoders, err := ex.requestEncoders(req.Formats)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, url := range urls {
+	urls := make([]string, 0, len(req.Urls))
+	for _, url := range req.Urls {
 		url = strings.TrimSpace(url)
 		// ignore empty lines
-		if url == "" {
-			continue
+		if url != "" {
+			urls = append(urls, url)
 		}
+	}
+
+	// urls are skipped until started, urls that can't be parsed fail when started
+	sources := make([]ExportedSource, len(urls))
+	for i, url := range urls {
+		sources[i] = ExportedSource{
+			Url:     url,
+			Status:  StatusSkipped,
+			BookIds: make(map[string][]string, len(encoders)),
+		}
+		if info, err := parseUrl(url); err == nil {
+			sources[i].Target = urlTarget(info, req)
+		}
+	}
 
-		err := ex.exportURL(ctx, url, resp)
+	err = forEach(ctx, ex.concurrency, len(urls), func(ctx context.Context, i int) error {
+		source := &sources[i]
+		ctx = withProgress(ctx, req.OnProgress, i, len(urls), source)
+		progress(ctx, Progress{Stage: StageResolved})
+
+		start := time.Now()
+		err := ex.exportURL(ctx, req, encoders, source.Url, source)
+		source.Duration = time.Since(start)
 		if err != nil {
-			return resp, fmt.Errorf("export url '%v': %w", url, err)
+			source.Status = StatusFailed
+			source.Err = fmt.Errorf("export url '%v': %w", source.Url, err)
+		} else {
+			source.Status = StatusExported
+		}
+		progress(ctx, Progress{Stage: StageDone, Status: source.Status, Err: source.Err})
+
+		if req.StopOnError {
+			return source.Err
+		}
+		return nil
+	})
+
+	resp := &Response{
+		BookIds:  make([]string, 0, len(urls)*len(encoders)),
+		ImageIds: make([]string, 0, len(urls)),
+		Sources:  make([]ExportedSource, 0, len(urls)),
+	}
+	for _, source := range sources {
+		if source.Status == StatusSkipped {
+			source.Err = fmt.Errorf("export stopped: %w", err)
+		}
+		addSource(resp, source, encoders)
+	}
+	return resp, err
+}
+
+// requestEncoders returns encoders of requested formats, the default encoder if there are none
+func (ex *Exporter) requestEncoders(formats []string) ([]BookEncoder, error) {
+	if len(formats) == 0 {
+		return []BookEncoder{ex.bookEncoder}, nil
+	}
+
+	encoders := make([]BookEncoder, 0, len(formats))
+	seen := make(map[string]bool, len(formats))
+	for _, format := range formats {
+		if seen[format] {
+			continue
+		}
+		seen[format] = true
+
+		encoder, ok := ex.encoders[format]
+		if !ok {
+			return nil, fmt.Errorf("unknown book format '%s', available: %s", format, strings.Join(ex.Formats(), ", "))
 		}
+		encoders = append(encoders, encoder)
 	}
+	return encoders, nil
+}
 
-	return resp, nil
+// addSource adds ids exported from source to the flat id lists, books are ordered by requested format
+func addSource(resp *Response, source ExportedSource, encoders []BookEncoder) {
+	for _, encoder := range encoders {
+		resp.BookIds = append(resp.BookIds, source.BookIds[encoder.Format()]...)
+	}
+	resp.ImageIds = append(resp.ImageIds, source.ImageIds...)
+	resp.Sources = append(resp.Sources, source)
 }
 
-func (ex *Exporter) exportURL(ctx context.Context, url string, resp *Response) error {
+func (ex *Exporter) exportURL(ctx context.Context, req Request, encoders []BookEncoder, url string, out *ExportedSource) error {
 	urlInfo, err := parseUrl(url)
 	if err != nil {
 		return err
 	}
 
+	if urlInfo.Username != "" {
+		return ex.exportUser(ctx, req, encoders, urlInfo.Username, urlInfo.UserTab, out)
+	}
+	if urlInfo.Sort != "" {
+		return ex.exportListing(ctx, req, encoders, urlInfo, out)
+	}
+	if urlInfo.WikiPage != "" {
+		return ex.exportWiki(ctx, req, encoders, urlInfo.Subreddit, urlInfo.WikiPage, out)
+	}
+
+	if urlInfo.CommentID != "" && req.Comic {
+		return ex.exportCommentBook(ctx, encoders, urlInfo.Subreddit, urlInfo.CommentID, out)
+	}
 	if urlInfo.CommentID != "" {
-		return ex.exportComment(ctx, urlInfo.Subreddit, urlInfo.CommentID, resp)
+		return ex.exportComment(ctx, urlInfo.Subreddit, urlInfo.CommentID, out)
+	}
+
+	if req.Omnibus {
+		return ex.exportOmnibus(ctx, encoders, urlInfo.Subreddit, urlInfo.PostID, out)
 	}
 
-	return ex.exportPost(ctx, urlInfo.Subreddit, urlInfo.PostID, resp)
+	return ex.exportPost(ctx, req, encoders, urlInfo.Subreddit, urlInfo.PostID, out)
+}
+
+// urlTarget describes what the url exports with the request
+func urlTarget(info *urlInfo, req Request) string {
+	switch {
+	case info.Username != "":
+		return fmt.Sprintf("%s posts of u/%s", info.UserTab, info.Username)
+	case info.Sort != "":
+		listing := *info
+		listing.Period = listingPeriod(info, req.Listing)
+		return listingTitle(&listing)
+	case info.WikiPage != "":
+		return fmt.Sprintf("wiki r/%s/wiki/%s", info.Subreddit, info.WikiPage)
+	case info.CommentID != "":
+		return fmt.Sprintf("comment r/%s/%s", info.Subreddit, info.CommentID)
+	case info.Subreddit == "":
+		return "gallery " + info.PostID
+	case req.Omnibus:
+		return fmt.Sprintf("omnibus of post r/%s/%s", info.Subreddit, info.PostID)
+	}
+	return fmt.Sprintf("post r/%s/%s", info.Subreddit, info.PostID)
 }
 
-func (ex *Exporter) exportPost(ctx context.Context, subreddit, postID string, resp *Response) error {
+func (ex *Exporter) exportPost(ctx context.Context, req Request, encoders []BookEncoder, subreddit, postID string, out *ExportedSource) error {
 	post, err := ex.client.GetPostByID(ctx, subreddit, postID)
 	if err != nil {
 		return fmt.Errorf("download reddit post r/%s/%s: %w", subreddit, postID, err)
 	}
+	progress(ctx, Progress{Stage: StageFetched, Title: post.Title})
+	return ex.exportPostBook(ctx, req, encoders, post, out)
+}
+
+// exportPostBook exports a downloaded post as a book, or as images and videos for media posts
+func (ex *Exporter) exportPostBook(ctx context.Context, req Request, encoders []BookEncoder, post *Post, out *ExportedSource) error {
+	// videos are stored with images
+	if post.Video != nil {
+		return ex.saveVideo(ctx, *post.Video, out)
+	}
+
+	gallery := ex.sizedImages(post.Gallery)
+	if len(gallery) > 0 && req.Images {
+		return ex.saveImages(ctx, gallery, out)
+	}
 
+	html, markdown, err := ex.postContent(ctx, post)
+	if err != nil {
+		return err
+	}
+	book := &Book{
+		Title:    post.Title,
+		Meta:     postMeta(post),
+		Html:     html,
+		Markdown: markdown,
+	}
+
+	// gallery urls have no subreddit
+	chapters, err := ex.commentChapters(ctx, post.Subreddit, post.ID)
+	if err != nil {
+		return err
+	}
+	book.Chapters = append(book.Chapters, chapters...)
+
+	return ex.saveBook(ctx, encoders, book, out)
+}
+
+// postContent returns post body html and markdown with gallery images or the linked article,
+// markdown is empty if there is no markdown source
+func (ex *Exporter) postContent(ctx context.Context, post *Post) (string, string, error) {
+	if post.LinkUrl != "" {
+		content, err := ex.linkHtml(ctx, post)
+		if err != nil {
+			return "", "", err
+		}
+		return content, "", nil
+	}
+
+	gallery := ex.sizedImages(post.Gallery)
+	return post.Html + galleryHtml(gallery), post.Markdown + galleryMarkdown(gallery), nil
+}
+
+// commentChapters downloads post comments as book chapters if comments are enabled
+func (ex *Exporter) commentChapters(ctx context.Context, subreddit, postID string) ([]Chapter, error) {
+	if ex.comments == nil {
+		return nil, nil
+	}
+
+	comments, err := ex.client.GetPostComments(ctx, subreddit, postID, *ex.comments)
+	if err != nil {
+		return nil, fmt.Errorf("download comments of reddit post r/%s/%s: %w", subreddit, postID, err)
+	}
+	return commentChapters(comments), nil
+}
+
+func postMeta(post *Post) BookMeta {
+	return BookMeta{
+		Author:    post.Author,
+		Subreddit: post.Subreddit,
+		Url:       post.Url,
+		Created:   post.Created,
+		Edited:    post.Edited,
+		Score:     post.Score,
+		Flair:     post.Flair,
+	}
+}
+
+func addCover(book *Book) error {
+	data, err := cover.PNG(cover.Info{
+		Title:     book.Title,
+		Author:    book.Meta.Author,
+		Subreddit: book.Meta.Subreddit,
+	})
+	if err != nil {
+		return fmt.Errorf("generate cover: %w", err)
+	}
+
+	book.Cover = BookImage{
+		Name:        "cover.png",
+		ContentType: "image/png",
+		Data:        data,
+	}
+	return nil
+}
+
+// saveBook encodes and stores the book once per encoder, images and cover are prepared once
+func (ex *Exporter) saveBook(ctx context.Context, encoders []BookEncoder, book *Book, out *ExportedSource) error {
+	ex.embedImages(ctx, book)
+	if ex.covers {
+		err := addCover(book)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, encoder := range encoders {
+		err := ex.encodeBook(ctx, encoder, book, out)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ex *Exporter) encodeBook(ctx context.Context, encoder BookEncoder, book *Book, out *ExportedSource) error {
 	buf := bufpool.Get()
 	defer buf.Close()
 
-	err = ex.bookEncoder.Encode(post, buf)
+	format := encoder.Format()
+	progress(ctx, Progress{Stage: StageEncoding, Title: book.Title, Format: format})
+	err := encoder.Encode(book, buf)
 	if err != nil {
-		return fmt.Errorf("encode post: %w", err)
+		return fmt.Errorf("encode post as %s: %w", format, err)
 	}
 
 	id := ulid.Make().String()
-	title := post.Title
-	format := ex.bookEncoder.Format()
+	title := book.Title
 
-	err = ex.bookstore.SaveBook(id, title, format, buf)
+	err = ex.bookstore.SaveBook(id, title, format, book.Meta, buf)
 	if err != nil {
 		return fmt.Errorf("save book: %w", err)
 	}
 
-	resp.BookIds = append(resp.BookIds, id)
+	out.BookIds[format] = append(out.BookIds[format], id)
+	progress(ctx, Progress{Stage: StageStored, Title: title, Format: format, Id: id})
 	return nil
 }
 
-func (ex *Exporter) exportComment(ctx context.Context, subreddit, commentID string, resp *Response) error {
+func (ex *Exporter) exportComment(ctx context.Context, subreddit, commentID string, out *ExportedSource) error {
 	comment, err := ex.client.GetCommentByID(ctx, subreddit, commentID)
 	if err != nil {
 		return fmt.Errorf("get comment by id: %w", err)
@@ -143,23 +682,90 @@ func (ex *Exporter) exportComment(ctx context.Context, subreddit, commentID stri
 	if len(comment.Images) == 0 {
 		return fmt.Errorf("no images url in comment")
 	}
+	progress(ctx, Progress{Stage: StageFetched, Title: comment.Title})
+
+	return ex.saveImages(ctx, ex.sizedImages(comment.Images), out)
+}
 
-	for _, info := range comment.Images {
+// saveImages downloads images concurrently and stores them to image store, ids keep the order of images
+func (ex *Exporter) saveImages(ctx context.Context, images []ImageInfo, out *ExportedSource) error {
+	ids := make([]string, len(images))
+	counter := newImageCounter(ctx, len(images))
+	err := forEach(ctx, ex.concurrency, len(images), func(ctx context.Context, i int) error {
+		info := images[i]
 		// todo: add image.String func, log info, use in errorf
 		buf := bytes.NewBuffer(nil)
-		err := ex.client.DownloadImage(ctx, info, buf)
+		err := ex.download(ctx, func() error {
+			return ex.client.DownloadImage(ctx, info, buf)
+		})
 		if err != nil {
 			return fmt.Errorf("download image (name = %s, url = %s): %w", info.Name, info.Url, err)
 		}
 
+		counter.downloaded()
+
 		id := ulid.Make().String()
 		err = ex.imagestore.SaveImage(id, info.Name, buf)
 		if err != nil {
 			return fmt.Errorf("save image: %w", err)
 		}
 
-		resp.ImageIds = append(resp.ImageIds, id)
+		ids[i] = id
+		progress(ctx, Progress{Stage: StageStored, Title: info.Name, Id: id})
+		return nil
+	})
+
+	// stored images are listed even if others failed
+	for _, id := range ids {
+		if id != "" {
+			out.ImageIds = append(out.ImageIds, id)
+		}
+	}
+	return err
+}
+
+func (ex *Exporter) saveVideo(ctx context.Context, info VideoInfo, out *ExportedSource) error {
+	buf := bytes.NewBuffer(nil)
+	// video and audio streams are the largest downloads, they share the pool with images
+	err := ex.download(ctx, func() error {
+		return ex.client.DownloadVideo(ctx, info, ex.videoAudio, buf)
+	})
+	if err != nil {
+		return fmt.Errorf("download video (name = %s, url = %s): %w", info.Name, info.Url, err)
+	}
+
+	id := ulid.Make().String()
+	err = ex.imagestore.SaveImage(id, info.Name, buf)
+	if err != nil {
+		return fmt.Errorf("save video: %w", err)
 	}
 
+	out.ImageIds = append(out.ImageIds, id)
+	progress(ctx, Progress{Stage: StageStored, Title: info.Name, Id: id})
 	return nil
 }
+
+// exportCommentBook exports comment images as one book with an image per page
+func (ex *Exporter) exportCommentBook(ctx context.Context, encoders []BookEncoder, subreddit, commentID string, out *ExportedSource) error {
+	comment, err := ex.client.GetCommentByID(ctx, subreddit, commentID)
+	if err != nil {
+		return fmt.Errorf("get comment by id: %w", err)
+	}
+	if len(comment.Images) == 0 {
+		return fmt.Errorf("no images url in comment")
+	}
+	progress(ctx, Progress{Stage: StageFetched, Title: comment.Title})
+
+	book := &Book{
+		Title: cmp.Or(comment.Title, "Comment "+comment.ID),
+		Meta: BookMeta{
+			Author:    comment.Author,
+			Subreddit: comment.Subreddit,
+			Url:       comment.Url,
+			Created:   comment.Created,
+			Score:     comment.Score,
+		},
+		Html: galleryHtml(ex.sizedImages(comment.Images)),
+	}
+	return ex.saveBook(ctx, encoders, book, out)
+}
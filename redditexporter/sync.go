@@ -0,0 +1,186 @@
+package redditexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type (
+	// SyncState remembers posts exported from a synced list by reddit fullname (t3_<id>)
+	SyncState = struct {
+		Posts map[string]SyncedPost
+	}
+
+	// SyncedPost lists ids exported from a post of a synced list
+	SyncedPost = struct {
+		Url      string
+		BookIds  []string
+		ImageIds []string
+	}
+
+	// SyncStore keeps sync states between syncs, by synced list url
+	SyncStore interface {
+		GetState(list string) (SyncState, bool, error)
+		SaveState(list string, state SyncState) error
+	}
+
+	// BookRemover is implemented by book stores that can delete books of posts removed from synced lists
+	BookRemover interface {
+		DeleteBook(id string) error
+	}
+
+	// ImageRemover is implemented by image stores that can delete images of posts removed from synced lists
+	ImageRemover interface {
+		DeleteImage(id string) error
+	}
+)
+
+type (
+	SyncRequest = struct {
+		// Url is the synced list, a user url like https://www.reddit.com/user/me/saved
+		Url string
+		// Formats of exported books, default encoder is used if empty
+		Formats []string
+		// Remove deletes books and images of posts that left the list since the last sync,
+		// files are kept in stores that can't delete them
+		Remove bool
+	}
+
+	SyncResponse = struct {
+		// Exported are sources of posts that are new in the list, oldest first,
+		// failed posts have StatusFailed and are retried by the next sync
+		Exported []ExportedSource
+		// Removed are urls of posts that left the list
+		Removed []string
+	}
+)
+
+// Sync exports posts of a user list that were not exported by previous syncs,
+// the state is saved after every post so a failed sync continues where it stopped.
+// Posts that fail are reported in the response and skipped, they don't stop newer posts from syncing
+func (ex *Exporter) Sync(ctx context.Context, store SyncStore, req SyncRequest) (*SyncResponse, error) {
+	encoders, err := ex.requestEncoders(req.Formats)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := parseUrl(req.Url)
+	if err != nil {
+		return nil, err
+	}
+	if info.Username == "" {
+		return nil, fmt.Errorf("sync '%s': only user lists can be synced, like https://www.reddit.com/user/me/saved", req.Url)
+	}
+
+	state, ok, err := store.GetState(req.Url)
+	if err != nil {
+		return nil, fmt.Errorf("get sync state: %w", err)
+	}
+	if !ok || state.Posts == nil {
+		state.Posts = make(map[string]SyncedPost)
+	}
+
+	posts, err := ex.userPosts(ctx, info.Username, info.UserTab)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &SyncResponse{}
+	// lists are newest first, new posts are exported in the order they were added
+	for _, post := range slices.Backward(posts) {
+		fullname := postFullname(post.ID)
+		if _, ok := state.Posts[fullname]; ok {
+			continue
+		}
+
+		start := time.Now()
+		source := ExportedSource{
+			Url:     post.Url,
+			Target:  fmt.Sprintf("post r/%s/%s", post.Subreddit, post.ID),
+			BookIds: make(map[string][]string, len(encoders)),
+		}
+		err := ex.exportPostBook(ctx, Request{Formats: req.Formats}, encoders, &post, &source)
+		source.Duration = time.Since(start)
+		if err != nil {
+			if ctx.Err() != nil {
+				return resp, fmt.Errorf("sync post %s: %w", post.Url, err)
+			}
+			// failed posts are not saved to the state, the next sync retries them
+			source.Status = StatusFailed
+			source.Err = fmt.Errorf("sync post %s: %w", post.Url, err)
+			resp.Exported = append(resp.Exported, source)
+			continue
+		}
+		source.Status = StatusExported
+		resp.Exported = append(resp.Exported, source)
+
+		state.Posts[fullname] = syncedPost(source, encoders)
+		err = store.SaveState(req.Url, state)
+		if err != nil {
+			return resp, fmt.Errorf("save sync state: %w", err)
+		}
+	}
+
+	// posts past the listing limit are missing from the list but not removed from it
+	if !req.Remove || len(posts) >= userPostsLimit {
+		return resp, nil
+	}
+
+	listed := make(map[string]bool, len(posts))
+	for _, post := range posts {
+		listed[postFullname(post.ID)] = true
+	}
+	for fullname, synced := range state.Posts {
+		if listed[fullname] {
+			continue
+		}
+
+		err := ex.removeSynced(synced)
+		if err != nil {
+			return resp, fmt.Errorf("remove unlisted post %s: %w", synced.Url, err)
+		}
+		delete(state.Posts, fullname)
+		resp.Removed = append(resp.Removed, synced.Url)
+	}
+
+	err = store.SaveState(req.Url, state)
+	if err != nil {
+		return resp, fmt.Errorf("save sync state: %w", err)
+	}
+	return resp, nil
+}
+
+// removeSynced deletes exported books and images of a post from stores that can delete them
+func (ex *Exporter) removeSynced(synced SyncedPost) error {
+	var errs error
+	if remover, ok := ex.bookstore.(BookRemover); ok {
+		for _, id := range synced.BookIds {
+			errs = errors.Join(errs, remover.DeleteBook(id))
+		}
+	}
+	if remover, ok := ex.imagestore.(ImageRemover); ok {
+		for _, id := range synced.ImageIds {
+			errs = errors.Join(errs, remover.DeleteImage(id))
+		}
+	}
+	return errs
+}
+
+func syncedPost(source ExportedSource, encoders []BookEncoder) SyncedPost {
+	synced := SyncedPost{
+		Url:      source.Url,
+		ImageIds: source.ImageIds,
+	}
+	for _, encoder := range encoders {
+		synced.BookIds = append(synced.BookIds, source.BookIds[encoder.Format()]...)
+	}
+	return synced
+}
+
+// postFullname is the reddit type prefixed id of a post
+func postFullname(id string) string {
+	return "t3_" + id
+}
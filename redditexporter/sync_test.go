@@ -0,0 +1,80 @@
+package redditexporter_test
+
+import (
+	"log/slog"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/awryme/reddit-exporter/redditclient"
+	"github.com/awryme/reddit-exporter/redditclient/reddittest"
+	"github.com/awryme/reddit-exporter/redditexporter"
+	"github.com/awryme/reddit-exporter/redditexporter/syncstore"
+)
+
+func TestSync(t *testing.T) {
+	srv := newServer(t)
+	srv.Save("p1", "p2", "p3")
+	// comments of p2 fail like comments of a removed post
+	srv.FailPath("/r/test/comments/p2", http.StatusNotFound)
+
+	tokens := redditclient.NewMemoryTokenStore()
+	auth := redditclient.NewAuth(slog.DiscardHandler, "client", "secret", tokens, srv.Options()...)
+	err := redditclient.NewUserAuth(auth, srv.URL+"/callback").Authorize(reddittest.Code)
+	if err != nil {
+		t.Fatalf("authorize account: %v", err)
+	}
+
+	ex := newTokenExporter(srv, tokens, redditexporter.WithComments(redditexporter.CommentOptions{}))
+	store := syncstore.NewFile(filepath.Join(t.TempDir(), "sync.json"))
+	req := redditexporter.SyncRequest{Url: "https://www.reddit.com/user/" + reddittest.Username + "/saved"}
+
+	syncs := []struct {
+		name   string
+		before func()
+		// want are synced posts as id: status
+		want []string
+	}{
+		{
+			name: "failed post is skipped",
+			want: []string{"p1: exported", "p2: failed", "p3: exported"},
+		},
+		{
+			name: "failed post is retried",
+			want: []string{"p2: failed"},
+		},
+		{
+			name:   "fixed post is synced",
+			before: func() { srv.FailPath("/r/test/comments/p2", 0) },
+			want:   []string{"p2: exported"},
+		},
+		{
+			name: "nothing new",
+			want: []string{},
+		},
+	}
+	for _, run := range syncs {
+		if run.before != nil {
+			run.before()
+		}
+
+		resp, err := ex.Sync(t.Context(), store, req)
+		if err != nil {
+			t.Fatalf("%s: sync: %v", run.name, err)
+		}
+
+		got := make([]string, 0, len(resp.Exported))
+		for _, source := range resp.Exported {
+			got = append(got, path.Base(strings.TrimSuffix(source.Url, "/"))+": "+source.Status)
+			if source.Status == redditexporter.StatusFailed && source.Err == nil {
+				t.Errorf("%s: failed post %s has no error", run.name, source.Url)
+			}
+		}
+		if !slices.Equal(got, run.want) {
+			t.Errorf("%s: got synced posts %v, want %v", run.name, got, run.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package syncstore
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/awryme/reddit-exporter/pkg/jsonfile"
+)
+
+type (
+	SyncState = struct {
+		Posts map[string]SyncedPost
+	}
+
+	SyncedPost = struct {
+		Url      string
+		BookIds  []string
+		ImageIds []string
+	}
+)
+
+// File keeps sync states of all synced lists in one json file
+type File struct {
+	mu       sync.Mutex
+	filename string
+}
+
+func NewFile(filename string) *File {
+	return &File{filename: filename}
+}
+
+func (store *File) GetState(list string) (SyncState, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	states, err := store.read()
+	if err != nil {
+		return SyncState{}, false, err
+	}
+	state, ok := states[list]
+	return state, ok, nil
+}
+
+func (store *File) SaveState(list string, state SyncState) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	states, err := store.read()
+	if err != nil {
+		return err
+	}
+	states[list] = state
+	return jsonfile.Write(store.filename, states)
+}
+
+func (store *File) read() (map[string]SyncState, error) {
+	states, err := jsonfile.Read[map[string]SyncState](store.filename)
+	if errors.Is(err, jsonfile.ErrFileNotFound) {
+		return make(map[string]SyncState), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read sync state from file: %w", err)
+	}
+	if states == nil {
+		states = make(map[string]SyncState)
+	}
+	return states, nil
+}
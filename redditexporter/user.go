@@ -0,0 +1,182 @@
+package redditexporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/awryme/slogf"
+)
+
+// reddit listings end after about 1000 items
+const userPostsLimit = 1000
+
+// exportUser exports submitted, saved or upvoted posts of a user that pass the request filter,
+// as one anthology book or as a book per post, oldest posts first
+func (ex *Exporter) exportUser(ctx context.Context, req Request, encoders []BookEncoder, username, tab string, out *ExportedSource) error {
+	posts, err := ex.userPosts(ctx, username, tab)
+	if err != nil {
+		return err
+	}
+
+	posts = slices.DeleteFunc(posts, func(post Post) bool {
+		return !matchUserFilter(post, req.User)
+	})
+	if len(posts) == 0 {
+		return fmt.Errorf("no %s posts of u/%s match the filter", tab, username)
+	}
+	slices.SortFunc(posts, func(a, b Post) int {
+		return a.Created.Compare(b.Created)
+	})
+	progress(ctx, Progress{Stage: StageFetched, Total: len(posts)})
+
+	if req.Anthology {
+		book := &Book{
+			Title: userAnthologyTitle(username, tab, req.User),
+			Meta: BookMeta{
+				Url: fmt.Sprintf("https://www.reddit.com/user/%s/%s", username, tab),
+				// anthology is as old as its first post
+				Created: posts[0].Created,
+			},
+		}
+		// saved and upvoted posts are written by others
+		if tab == "submitted" {
+			book.Meta.Author = username
+		}
+		return ex.exportAnthology(ctx, encoders, book, posts, out)
+	}
+
+	return ex.exportPosts(ctx, req, encoders, posts, out)
+}
+
+// userPosts lists a tab of a user profile: submitted, saved or upvoted posts, newest first
+func (ex *Exporter) userPosts(ctx context.Context, username, tab string) ([]Post, error) {
+	var posts []Post
+	var err error
+	switch tab {
+	case "saved":
+		posts, err = ex.client.GetSavedPosts(ctx, username, userPostsLimit)
+	case "upvoted":
+		posts, err = ex.client.GetUpvotedPosts(ctx, username, userPostsLimit)
+	default:
+		posts, err = ex.client.GetUserPosts(ctx, username, userPostsLimit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list %s posts of u/%s: %w", tab, username, err)
+	}
+	return posts, nil
+}
+
+func matchUserFilter(post Post, filter UserFilter) bool {
+	if len(filter.Subreddits) > 0 && !slices.ContainsFunc(filter.Subreddits, func(subreddit string) bool {
+		return strings.EqualFold(strings.TrimPrefix(subreddit, "r/"), post.Subreddit)
+	}) {
+		return false
+	}
+	if !filter.After.IsZero() && post.Created.Before(filter.After) {
+		return false
+	}
+	if !filter.Before.IsZero() && !post.Created.Before(filter.Before) {
+		return false
+	}
+	if filter.MinScore != 0 && post.Score < filter.MinScore {
+		return false
+	}
+	return true
+}
+
+// userAnthologyTitle names user anthologies: "Posts of u/name", "Saved posts of u/name in r/sub"
+func userAnthologyTitle(username, tab string, filter UserFilter) string {
+	title := "Posts of u/" + username
+	switch {
+	case tab != "submitted" && username == "me":
+		title = fmt.Sprintf("My %s posts", tab)
+	case tab == "saved":
+		title = "Saved posts of u/" + username
+	case tab == "upvoted":
+		title = "Upvoted posts of u/" + username
+	}
+	if len(filter.Subreddits) > 0 {
+		subreddits := make([]string, 0, len(filter.Subreddits))
+		for _, subreddit := range filter.Subreddits {
+			subreddits = append(subreddits, "r/"+strings.TrimPrefix(subreddit, "r/"))
+		}
+		title += " in " + strings.Join(subreddits, ", ")
+	}
+	return title
+}
+
+// exportPosts exports a book per post, failed posts don't stop the rest unless the request stops on errors.
+// The url is exported if any post is, errors of failed posts are kept in out.Err
+func (ex *Exporter) exportPosts(ctx context.Context, req Request, encoders []BookEncoder, posts []Post, out *ExportedSource) error {
+	var errs []error
+	for i, post := range posts {
+		progress(ctx, Progress{Stage: StagePost, Title: post.Title, Done: i, Total: len(posts)})
+		err := ex.exportPostBook(ctx, req, encoders, &post, out)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("export post %s: %w", post.Url, err)
+		if req.StopOnError || ctx.Err() != nil {
+			return err
+		}
+		ex.logf("skip failed post", slog.String("url", post.Url), slogf.Error(err))
+		errs = append(errs, err)
+	}
+
+	if len(errs) == len(posts) {
+		return fmt.Errorf("all %d posts failed: %w", len(posts), errors.Join(errs...))
+	}
+	if len(errs) > 0 {
+		out.Err = fmt.Errorf("%d of %d posts failed: %w", len(errs), len(posts), errors.Join(errs...))
+	}
+	return nil
+}
+
+// exportAnthology adds posts to the book as chapters, in the given order
+func (ex *Exporter) exportAnthology(ctx context.Context, encoders []BookEncoder, book *Book, posts []Post, out *ExportedSource) error {
+	for i, post := range posts {
+		progress(ctx, Progress{Stage: StagePost, Title: post.Title, Done: i, Total: len(posts)})
+		content, markdown, err := ex.postContent(ctx, &post)
+		if err != nil {
+			return fmt.Errorf("export post %s: %w", post.Url, err)
+		}
+		// videos can't be embedded into books
+		if post.Video != nil {
+			content += fmt.Sprintf(`<p><a href="%s">Video</a></p>`, html.EscapeString(post.Url))
+			markdown += fmt.Sprintf("[Video](%s)", post.Url)
+		}
+
+		info := anthologyPostInfo(post)
+		if markdown != "" {
+			markdown = fmt.Sprintf("*%s*\n\n%s", info, markdown)
+		}
+		book.Chapters = append(book.Chapters, Chapter{
+			Title:    post.Title,
+			Html:     fmt.Sprintf("<p><i>%s</i></p>", html.EscapeString(info)) + content,
+			Markdown: markdown,
+			Depth:    0,
+		})
+
+		comments, err := ex.commentChapters(ctx, post.Subreddit, post.ID)
+		if err != nil {
+			return err
+		}
+		for _, chapter := range comments {
+			chapter.Depth++
+			book.Chapters = append(book.Chapters, chapter)
+		}
+	}
+
+	return ex.saveBook(ctx, encoders, book, out)
+}
+
+// anthologyPostInfo describes a post in its chapter: "r/sub · 2024-01-02 · 15 points"
+func anthologyPostInfo(post Post) string {
+	return fmt.Sprintf("r/%s · %s · %d points", post.Subreddit, post.Created.Format(time.DateOnly), post.Score)
+}
@@ -0,0 +1,192 @@
+package redditexporter
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/awryme/slogf"
+	"golang.org/x/net/html"
+)
+
+// upper bound of pages in one wiki book, wikis link to their index from every page
+const maxWikiPages = 100
+
+// matches reddit wiki link paths: /r/<sub>/wiki/<page>, links are resolved against the page before matching
+var wikiLinkRe = regexp.MustCompile(`(?i)^/r/([a-z0-9_]+)/wiki(?:/(.*))?$`)
+
+// wiki pages that list wiki metadata instead of content
+var wikiServicePages = []string{"pages", "revisions", "settings", "discussions", "edit"}
+
+// exportWiki exports a wiki page as a book, linked pages of the same wiki are added as chapters if requested
+func (ex *Exporter) exportWiki(ctx context.Context, req Request, encoders []BookEncoder, subreddit, pageName string, out *ExportedSource) error {
+	page, err := ex.client.GetWikiPage(ctx, subreddit, pageName)
+	if err != nil {
+		return fmt.Errorf("download wiki page r/%s/wiki/%s: %w", subreddit, pageName, err)
+	}
+	progress(ctx, Progress{Stage: StageFetched, Title: wikiTitle(page)})
+
+	book := &Book{
+		Title: wikiTitle(page),
+		Meta: BookMeta{
+			Author:    page.RevisedBy,
+			Subreddit: page.Subreddit,
+			Url:       page.Url,
+			Created:   page.Revised,
+		},
+		Html:     page.Html,
+		Markdown: page.Markdown,
+	}
+
+	if req.WikiLinks {
+		chapters, err := ex.wikiChapters(ctx, page)
+		if err != nil {
+			return err
+		}
+		book.Chapters = chapters
+	}
+
+	return ex.saveBook(ctx, encoders, book, out)
+}
+
+// wikiChapters follows wiki links breadth first, chapters are nested by link distance from the start page
+func (ex *Exporter) wikiChapters(ctx context.Context, start *WikiPage) ([]Chapter, error) {
+	type queued struct {
+		page  *WikiPage
+		depth int
+	}
+
+	chapters := make([]Chapter, 0)
+	seen := map[string]bool{start.Name: true}
+	queue := []queued{{start, 0}}
+	for len(queue) > 0 && len(seen) < maxWikiPages {
+		item := queue[0]
+		queue = queue[1:]
+
+		for _, name := range findWikiLinks(item.page, start.Subreddit) {
+			if seen[name] || len(seen) >= maxWikiPages {
+				continue
+			}
+			seen[name] = true
+
+			linked, err := ex.client.GetWikiPage(ctx, start.Subreddit, name)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				// links to missing or private pages are common, they are left out
+				ex.logf("skip linked wiki page", slog.String("page", fmt.Sprintf("r/%s/wiki/%s", start.Subreddit, name)), slogf.Error(err))
+				continue
+			}
+
+			chapters = append(chapters, Chapter{
+				Title:    wikiTitle(linked),
+				Html:     linked.Html,
+				Markdown: linked.Markdown,
+				Depth:    item.depth,
+			})
+			queue = append(queue, queued{linked, item.depth + 1})
+		}
+	}
+	return chapters, nil
+}
+
+// findWikiLinks returns names of pages linked from a wiki page in the wiki of subreddit, in link order
+func findWikiLinks(page *WikiPage, subreddit string) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+
+	base, err := url.Parse(page.Url)
+	if err != nil {
+		return names
+	}
+
+	tokenizer := html.NewTokenizer(strings.NewReader(page.Html))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return names
+		case html.StartTagToken:
+			token := tokenizer.Token()
+			if token.Data != "a" {
+				continue
+			}
+
+			match := wikiLinkRe.FindStringSubmatch(wikiLinkPath(base, attrValue(token, "href")))
+			if match == nil || !strings.EqualFold(match[1], subreddit) {
+				continue
+			}
+			name := wikiPageName(match[2])
+			// anchors of the page resolve to the page itself
+			if isWikiServicePage(name) || seen[name] || name == wikiPageName(page.Name) {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+}
+
+// wikiLinkPath resolves relative links like "chapter-2" or "./chapter-2" against the page url,
+// links to other sites have no path
+func wikiLinkPath(base *url.URL, href string) string {
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return ""
+	}
+	link := base.ResolveReference(ref)
+	if !strings.EqualFold(link.Host, base.Host) && !slices.Contains(redditHosts, strings.ToLower(link.Host)) {
+		return ""
+	}
+	return link.Path
+}
+
+// wikiPageName normalizes the page path of a wiki url, reddit page names are lower case
+func wikiPageName(path string) string {
+	path = strings.Trim(strings.ToLower(path), "/")
+	return cmp.Or(path, "index")
+}
+
+func isWikiServicePage(name string) bool {
+	first, _, _ := strings.Cut(name, "/")
+	return slices.Contains(wikiServicePages, first)
+}
+
+// wikiTitle is the first heading of the page, or the page name if the page has no headings
+func wikiTitle(page *WikiPage) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(page.Html))
+	heading := ""
+	text := strings.Builder{}
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return fmt.Sprintf("r/%s wiki: %s", page.Subreddit, page.Name)
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "h1", "h2", "h3":
+				heading = string(name)
+				text.Reset()
+			}
+		case html.TextToken:
+			if heading != "" {
+				text.Write(tokenizer.Text())
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			if heading == "" || string(name) != heading {
+				continue
+			}
+			title := strings.Join(strings.Fields(text.String()), " ")
+			if title != "" {
+				return title
+			}
+			heading = ""
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package redditexporter
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFindWikiLinks(t *testing.T) {
+	tests := []struct {
+		name string
+		href string
+		// want is the linked page name, empty if the link is not followed
+		want string
+	}{
+		{name: "absolute", href: "https://www.reddit.com/r/test/wiki/faq", want: "faq"},
+		{name: "old reddit", href: "https://old.reddit.com/r/test/wiki/faq?v=1", want: "faq"},
+		{name: "root relative", href: "/r/test/wiki/Stories/Part_2#top", want: "stories/part_2"},
+		{name: "relative", href: "part_3", want: "stories/part_3"},
+		{name: "dot relative", href: "./part_3", want: "stories/part_3"},
+		{name: "parent relative", href: "../faq", want: "faq"},
+		{name: "wiki index", href: "/r/test/wiki/", want: "index"},
+		{name: "anchor of the page", href: "#chapter-1"},
+		{name: "other subreddit", href: "/r/other/wiki/faq"},
+		{name: "other site", href: "https://example.com/r/test/wiki/faq"},
+		{name: "service page", href: "/r/test/wiki/revisions"},
+		{name: "post", href: "/r/test/comments/abc/title/"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			page := &WikiPage{
+				Subreddit: "test",
+				Name:      "stories/part_1",
+				Url:       "https://www.reddit.com/r/test/wiki/stories/part_1",
+				Html:      `<p><a href="` + test.href + `">link</a></p>`,
+			}
+
+			want := []string{}
+			if test.want != "" {
+				want = []string{test.want}
+			}
+			got := findWikiLinks(page, "test")
+			if !slices.Equal(got, want) {
+				t.Errorf("got pages %v, want %v", got, want)
+			}
+		})
+	}
+}